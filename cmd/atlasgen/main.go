@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/leterax/go-voxels/pkg/atlas"
+)
+
+func main() {
+	// Parse command line flags
+	inputDir := flag.String("in", "assets/textures/blocks", "Directory of PNG block tiles")
+	outputDir := flag.String("out", "assets/textures", "Directory to write the atlas to")
+	name := flag.String("name", "atlas", "Base file name of the atlas images and index")
+	layoutName := flag.String("layout", "grid", "Atlas layout: grid or array")
+	padding := flag.Int("padding", 4, "Edge extrusion around grid tiles, in pixels, rounded up to halve evenly at every mip level")
+	mipLevels := flag.Int("mips", 0, "Number of mip levels (0 for a full chain)")
+	flag.Parse()
+
+	layout, err := atlas.ParseLayout(*layoutName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	a, err := atlas.Build(*inputDir, atlas.Options{
+		Layout:    layout,
+		Padding:   *padding,
+		MipLevels: *mipLevels,
+	})
+	if err != nil {
+		log.Fatalf("Failed to build atlas: %v", err)
+	}
+
+	if err := a.Write(*outputDir, *name); err != nil {
+		log.Fatalf("Failed to write atlas: %v", err)
+	}
+
+	fmt.Printf("Packed %d tiles for %d blocks into %s/%s.png (%d mip levels, %d px padding)\n",
+		len(a.Tiles), len(a.Blocks), *outputDir, *name, len(a.Levels), a.Padding)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/leterax/go-voxels/pkg/network"
+	"github.com/leterax/go-voxels/pkg/network/packet"
+	"github.com/leterax/go-voxels/pkg/voxel"
+)
+
+// chunkStats accumulates payload sizes of full chunk packets under each encoding
+type chunkStats struct {
+	chunks     int
+	monoChunks int
+	raw        int // As Send Chunk or Send Chunk States
+	received   int // As actually recorded, compressed or not
+	rle        int
+	deflate    int
+	best       int // Smallest of raw, RLE and DEFLATE per chunk
+}
+
+func (s *chunkStats) add(blocks []voxel.BlockType, props []uint8, received int) {
+	raw := len(packet.Encode(&packet.SendChunk{Blocks: blocks}))
+	if props != nil {
+		raw = len(packet.Encode(&packet.SendChunkStates{Blocks: blocks, Props: props}))
+	}
+
+	data := packet.ChunkData(blocks, props)
+	size := func(encoding uint8) int {
+		return len(packet.Encode(&packet.SendCompressedChunk{
+			Encoding: encoding,
+			HasProps: props != nil,
+			Data:     packet.Compress(data, encoding),
+		}))
+	}
+	rle, deflate := size(packet.EncodingRLE), size(packet.EncodingDeflate)
+
+	s.chunks++
+	s.raw += raw
+	s.received += received
+	s.rle += rle
+	s.deflate += deflate
+	s.best += min(raw, rle, deflate)
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s capture.vxcp...\n", os.Args[0])
+		fmt.Fprintln(flag.CommandLine.Output(), "Prints how well the chunks of recorded sessions compress.")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	for _, path := range flag.Args() {
+		stats, err := analyze(path)
+		if err != nil {
+			log.Fatalf("%s: %v", path, err)
+		}
+		report(path, stats)
+	}
+}
+
+// analyze reads every chunk packet of a capture file
+func analyze(path string) (*chunkStats, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader, err := network.NewCaptureReader(file)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &chunkStats{}
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return stats, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		size := len(packet.Encode(record.Packet))
+		switch p := record.Packet.(type) {
+		case *packet.SendChunk:
+			stats.add(p.Blocks, nil, size)
+		case *packet.SendChunkStates:
+			stats.add(p.Blocks, p.Props, size)
+		case *packet.SendCompressedChunk:
+			blocks, props, err := p.Decompress()
+			if err != nil {
+				return nil, err
+			}
+			stats.add(blocks, props, size)
+		case *packet.SendMonoTypeChunk:
+			stats.monoChunks++
+		}
+	}
+}
+
+func report(path string, s *chunkStats) {
+	fmt.Printf("%s: %d full chunks, %d mono chunks\n", path, s.chunks, s.monoChunks)
+	if s.chunks == 0 {
+		return
+	}
+	ratio := func(size int) string {
+		return fmt.Sprintf("%10d bytes  %6.2fx", size, float64(s.raw)/float64(size))
+	}
+	fmt.Printf("  uncompressed %s\n", ratio(s.raw))
+	fmt.Printf("  recorded     %s\n", ratio(s.received))
+	fmt.Printf("  RLE          %s\n", ratio(s.rle))
+	fmt.Printf("  DEFLATE      %s\n", ratio(s.deflate))
+	fmt.Printf("  best of all  %s\n", ratio(s.best))
+}
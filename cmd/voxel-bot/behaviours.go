@@ -0,0 +1,158 @@
+package main
+
+import (
+	"log"
+	"math"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/leterax/go-voxels/pkg/pathfind"
+)
+
+// wanderBehaviour walks to random spots around the spawn point, pausing in between
+type wanderBehaviour struct {
+	radius  int32
+	home    pathfind.Pos
+	homeSet bool
+	next    time.Time // When to pick the next spot
+}
+
+func newWanderBehaviour(radius int32) *wanderBehaviour {
+	return &wanderBehaviour{radius: radius}
+}
+
+func (w *wanderBehaviour) tick(b *bot, now time.Time) {
+	if !w.homeSet {
+		w.home, w.homeSet = b.footCell(), true
+	}
+	if !b.idle() || now.Before(w.next) {
+		return
+	}
+	w.next = now.Add(time.Second + rand.N(3*time.Second))
+
+	// Terrain may be far above or below the spawn point, so search a tall column
+	spot := pathfind.Pos{
+		X: w.home.X + rand.Int32N(2*w.radius+1) - w.radius,
+		Y: w.home.Y,
+		Z: w.home.Z + rand.Int32N(2*w.radius+1) - w.radius,
+	}
+	goal, ok := b.groundNear(spot, 16)
+	if !ok {
+		return
+	}
+	if err := b.moveTo(goal); err != nil && b.verbose {
+		log.Printf("No path to (%d, %d, %d): %v", goal.X, goal.Y, goal.Z, err)
+	}
+}
+
+// followDistance is how close the bot stays to the entity it follows, in blocks
+const followDistance = 2.5
+
+// followInterval is how often the path to the followed entity is recomputed
+const followInterval = 500 * time.Millisecond
+
+// followBehaviour walks after the entity set with bot.followEntity
+type followBehaviour struct {
+	next    time.Time
+	missing bool // Whether the entity's absence was already logged
+}
+
+func (f *followBehaviour) tick(b *bot, now time.Time) {
+	if !b.following || now.Before(f.next) {
+		return
+	}
+	f.next = now.Add(followInterval)
+
+	target, ok := b.entities.At(b.followID, now)
+	if !ok {
+		if !f.missing {
+			log.Printf("Entity %d to follow is not in range", b.followID)
+			f.missing = true
+		}
+		b.path = nil
+		return
+	}
+	f.missing = false
+
+	dx, dy, dz := target.X-b.pos.X, target.Y-b.pos.Y, target.Z-b.pos.Z
+	if math.Sqrt(float64(dx*dx+dy*dy+dz*dz)) <= followDistance {
+		b.path = nil
+		return
+	}
+	cell := pathfind.Pos{
+		X: int32(math.Floor(float64(target.X))),
+		Y: int32(math.Floor(float64(target.Y))),
+		Z: int32(math.Floor(float64(target.Z))),
+	}
+	// Players often fly or jump, aim for the ground below them
+	goal, ok := b.groundNear(cell, 8)
+	if !ok {
+		return
+	}
+	if err := b.moveTo(goal); err != nil && b.verbose {
+		log.Printf("No path to entity %d: %v", b.followID, err)
+	}
+}
+
+// chatResponder answers chat commands starting with an exclamation mark
+type chatResponder struct{}
+
+func (chatResponder) tick(*bot, time.Time) {}
+
+func (chatResponder) onChat(b *bot, sender, message string) {
+	command, _, _ := strings.Cut(strings.TrimSpace(message), " ")
+	switch command {
+	case "!help":
+		b.say("Commands: !ping !pos !come !follow !stop !build")
+	case "!ping":
+		b.say("pong")
+	case "!pos":
+		b.say("I'm at %.1f %.1f %.1f", b.pos.X, b.pos.Y, b.pos.Z)
+	case "!come", "!follow":
+		entityID, ok := b.entityNamed(sender)
+		if !ok {
+			b.say("I can't see you, %s", sender)
+			return
+		}
+		if command == "!follow" {
+			b.followEntity(entityID)
+			b.say("Following %s", sender)
+			return
+		}
+		target, _ := b.entities.At(entityID, time.Now())
+		goal, ok := b.groundNear(pathfind.Pos{
+			X: int32(math.Floor(float64(target.X))),
+			Y: int32(math.Floor(float64(target.Y))),
+			Z: int32(math.Floor(float64(target.Z))),
+		}, 8)
+		if ok {
+			b.stop()
+			if b.moveTo(goal) == nil {
+				b.say("On my way")
+				return
+			}
+		}
+		b.say("I can't find a way to you")
+	case "!stop":
+		b.stop()
+		b.say("Stopped")
+	case "!build":
+		if b.script == nil {
+			b.say("I have no script")
+			return
+		}
+		b.script.restart()
+		b.say("Building")
+	}
+}
+
+// entityNamed returns the ID of the entity with the given name
+func (b *bot) entityNamed(name string) (uint32, bool) {
+	for _, entity := range b.entities.All(time.Now()) {
+		if entity.Name == name {
+			return entity.EntityID, true
+		}
+	}
+	return 0, false
+}
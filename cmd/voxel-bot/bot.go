@@ -0,0 +1,366 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/leterax/go-voxels/pkg/chat"
+	"github.com/leterax/go-voxels/pkg/network"
+	"github.com/leterax/go-voxels/pkg/network/packet"
+	"github.com/leterax/go-voxels/pkg/pathfind"
+)
+
+// Timing of the bot loop
+const (
+	tickInterval   = 50 * time.Millisecond
+	pingInterval   = time.Second
+	reportInterval = 10 * time.Second
+)
+
+// behaviour is something the bot does on its own, run on every tick once spawned
+type behaviour interface {
+	tick(b *bot, now time.Time)
+}
+
+// chatListener is a behaviour reacting to chat messages of other players
+type chatListener interface {
+	onChat(b *bot, sender, message string)
+}
+
+// bot is a headless player. Everything but the client's reader runs on the goroutine calling run,
+// so its state needs no locking.
+type bot struct {
+	client    *network.Client
+	predictor *network.Predictor
+	entities  *network.EntityStore
+	finder    *pathfind.Finder
+	name      string
+	speed     float64 // Blocks per second
+	verbose   bool
+
+	behaviours []behaviour
+	script     *buildBehaviour // Also in behaviours, nil without a script
+	commands   *chat.Dispatcher
+	console    <-chan string // Lines typed on standard input, nil without a console
+
+	spawned   bool
+	pos       packet.Position
+	sentPos   packet.Position
+	path      []pathfind.Pos // Remaining waypoints, nearest first
+	following bool
+	followID  uint32
+
+	started  time.Time
+	received map[string]int // Events received per type
+}
+
+func newBot(client *network.Client, name string, speed float64, verbose bool) *bot {
+	predictor := network.NewPredictor(client, nil, 0)
+	return &bot{
+		client:    client,
+		predictor: predictor,
+		entities:  network.NewEntityStore(network.EntityStoreOptions{}),
+		finder:    pathfind.NewFinder(predictor.World(), pathfind.DefaultOptions()),
+		name:      name,
+		speed:     speed,
+		verbose:   verbose,
+		received:  make(map[string]int),
+	}
+}
+
+// enableConsole handles lines from console as chat, running slash commands on the bot
+func (b *bot) enableConsole(console <-chan string) {
+	b.console = console
+	b.commands = chat.NewDispatcher(b.client)
+	b.commands.Position = func() chat.Coords {
+		return chat.Coords{X: float64(b.pos.X), Y: float64(b.pos.Y), Z: float64(b.pos.Z)}
+	}
+	b.commands.OnOutput = func(message string) {
+		log.Print(message)
+	}
+	b.commands.MustRegister(chat.Teleport(func(to chat.Coords) error {
+		if !b.spawned {
+			return fmt.Errorf("not spawned yet")
+		}
+		b.stop()
+		b.pos.X, b.pos.Y, b.pos.Z = float32(to.X), float32(to.Y), float32(to.Z)
+		return nil
+	}))
+	b.commands.MustRegister(chat.RenderDistance(b.client))
+	b.commands.MustRegister(chat.Fill(b.client, 0))
+	b.commands.MustRegister(chat.Who(chat.PlayerNames(b.entities, func() string { return b.name })))
+}
+
+// run reads the client's events and ticks the behaviours until the client stops
+func (b *bot) run(ctx context.Context) error {
+	sub := b.client.Subscribe(network.SubscribeOptions{Backpressure: network.BackpressureBlock})
+	done := make(chan error, 1)
+	go func() { done <- b.client.Run(ctx) }()
+
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+	ping := time.NewTicker(pingInterval)
+	defer ping.Stop()
+	report := time.NewTicker(reportInterval)
+	defer report.Stop()
+
+	b.started = time.Now()
+	last := b.started
+	for {
+		select {
+		case ev, ok := <-sub.Events():
+			if !ok {
+				return <-done
+			}
+			b.handleEvent(ev)
+		case now := <-ticker.C:
+			b.tick(now, now.Sub(last))
+			last = now
+		case <-ping.C:
+			// Without pings the round trip is only sampled from the spawn and block edits
+			if b.client.Capabilities()&packet.CapabilityPing != 0 {
+				b.client.Ping()
+			}
+		case <-report.C:
+			b.report()
+		case line, ok := <-b.console:
+			if !ok {
+				b.console = nil
+				break
+			}
+			if err := b.commands.Handle(line); err != nil {
+				log.Print(err)
+			}
+		}
+	}
+}
+
+// handleEvent updates the bot's view of the world and logs what arrived
+func (b *bot) handleEvent(ev network.Event) {
+	b.received[eventName(ev)]++
+	if b.verbose {
+		log.Printf("Received %s", describeEvent(ev))
+	}
+	b.predictor.Apply(ev)
+	b.entities.Apply(ev)
+
+	switch ev := ev.(type) {
+	case network.IdentifiedEvent:
+		log.Printf("Identified as entity %d", ev.EntityID)
+	case network.EntityMovedEvent:
+		if ev.EntityID != b.client.EntityID() {
+			break
+		}
+		// The server placed us, at spawn or elsewhere
+		b.pos = packet.Position{X: ev.X, Y: ev.Y, Z: ev.Z, Yaw: ev.Yaw, Pitch: ev.Pitch}
+		b.sentPos = b.pos
+		b.path = nil
+		if !b.spawned {
+			log.Printf("Spawned at (%.1f, %.1f, %.1f)", ev.X, ev.Y, ev.Z)
+		}
+		b.spawned = true
+	case network.EntityAddedEvent:
+		log.Printf("Entity %d joined as %q", ev.EntityID, ev.Name)
+	case network.EntityRemovedEvent:
+		log.Printf("Entity %d left", ev.EntityID)
+	case network.EntityMetadataEvent:
+		log.Printf("Entity %d renamed to %q", ev.EntityID, ev.Name)
+	case network.ChatEvent:
+		log.Printf("Chat: %s", ev.Message)
+		sender, message, ok := splitChat(ev.Message)
+		if !ok || sender == b.name {
+			break
+		}
+		for _, behaviour := range b.behaviours {
+			if listener, ok := behaviour.(chatListener); ok {
+				listener.onChat(b, sender, message)
+			}
+		}
+	}
+}
+
+// tick runs the behaviours and moves the bot along its path
+func (b *bot) tick(now time.Time, elapsed time.Duration) {
+	b.predictor.Expire()
+	if !b.spawned {
+		return
+	}
+	for _, behaviour := range b.behaviours {
+		behaviour.tick(b, now)
+	}
+	b.walk(elapsed)
+
+	if b.pos != b.sentPos {
+		if err := b.client.SendUpdateEntity(b.pos.X, b.pos.Y, b.pos.Z, b.pos.Yaw, b.pos.Pitch); err != nil {
+			log.Printf("Failed to send position: %v", err)
+			return
+		}
+		b.sentPos = b.pos
+	}
+}
+
+// walk moves towards the next waypoint, turning to face it
+func (b *bot) walk(elapsed time.Duration) {
+	step := b.speed * elapsed.Seconds()
+	for len(b.path) > 0 && step > 0 {
+		next := b.path[0]
+		dx := float64(next.X) + 0.5 - float64(b.pos.X)
+		dy := float64(next.Y) - float64(b.pos.Y)
+		dz := float64(next.Z) + 0.5 - float64(b.pos.Z)
+		distance := math.Sqrt(dx*dx + dy*dy + dz*dz)
+		if dx != 0 || dz != 0 {
+			b.pos.Yaw = float32(math.Atan2(-dx, dz) * 180 / math.Pi)
+		}
+
+		if distance <= step {
+			b.pos.X, b.pos.Y, b.pos.Z = float32(next.X)+0.5, float32(next.Y), float32(next.Z)+0.5
+			b.path = b.path[1:]
+			step -= distance
+			continue
+		}
+		fraction := step / distance
+		b.pos.X += float32(dx * fraction)
+		b.pos.Y += float32(dy * fraction)
+		b.pos.Z += float32(dz * fraction)
+		return
+	}
+}
+
+// moveTo plans a path to goal, keeping the current one if there is none
+func (b *bot) moveTo(goal pathfind.Pos) error {
+	path, err := b.finder.FindPath(b.footCell(), goal)
+	if err != nil {
+		return err
+	}
+	b.path = path[1:]
+	return nil
+}
+
+// stop drops the current path and stops following
+func (b *bot) stop() {
+	b.path = nil
+	b.following = false
+}
+
+// followEntity makes the bot walk after an entity until stopped
+func (b *bot) followEntity(entityID uint32) {
+	b.following = true
+	b.followID = entityID
+}
+
+// idle reports whether the bot is free for behaviours that keep it busy on their own
+func (b *bot) idle() bool {
+	return !b.following && len(b.path) == 0
+}
+
+// footCell returns the walkable cell the bot stands in, or the nearest one below or above it.
+// The spawn point is usually a little above the ground.
+func (b *bot) footCell() pathfind.Pos {
+	cell := pathfind.Pos{
+		X: int32(math.Floor(float64(b.pos.X))),
+		Y: int32(math.Floor(float64(b.pos.Y))),
+		Z: int32(math.Floor(float64(b.pos.Z))),
+	}
+	if ground, ok := b.groundNear(cell, 4); ok {
+		return ground
+	}
+	return cell
+}
+
+// groundNear returns the walkable cell closest to p vertically within reach blocks
+func (b *bot) groundNear(p pathfind.Pos, reach int32) (pathfind.Pos, bool) {
+	for offset := int32(0); offset <= reach; offset++ {
+		if below := (pathfind.Pos{X: p.X, Y: p.Y - offset, Z: p.Z}); b.finder.Walkable(below) {
+			return below, true
+		}
+		if above := (pathfind.Pos{X: p.X, Y: p.Y + offset, Z: p.Z}); b.finder.Walkable(above) {
+			return above, true
+		}
+	}
+	return p, false
+}
+
+// say sends a chat message, logging failures
+func (b *bot) say(format string, args ...any) {
+	if err := b.client.SendChat(fmt.Sprintf(format, args...)); err != nil {
+		log.Printf("Failed to send chat: %v", err)
+	}
+}
+
+// report logs what the bot received so far
+func (b *bot) report() {
+	names := make([]string, 0, len(b.received))
+	for name := range b.received {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	summary := ""
+	for _, name := range names {
+		summary += fmt.Sprintf(" %s=%d", name, b.received[name])
+	}
+	log.Printf("After %s: %d chunks loaded, %d entities, %d edits pending, received%s",
+		time.Since(b.started).Round(time.Second), len(b.client.LoadedChunks()),
+		b.entities.Len(), b.predictor.Pending(), summary)
+
+	stats := b.client.Stats()
+	log.Printf("Traffic: %d packets (%.1f KiB) in, %d packets (%.1f KiB) out, %.1f chunks/s, "+
+		"decoding %s, round trip %s over %d samples",
+		stats.PacketsReceived, float64(stats.BytesReceived)/1024, stats.PacketsSent, float64(stats.BytesSent)/1024,
+		stats.ChunkRate, stats.DecodeTime.Round(time.Microsecond), stats.RTT.Round(time.Microsecond), stats.RTTSamples)
+}
+
+// splitChat separates a relayed chat message into the sender's name and the message
+func splitChat(text string) (sender, message string, ok bool) {
+	for i := 0; i+1 < len(text); i++ {
+		if text[i] == ':' && text[i+1] == ' ' {
+			return text[:i], text[i+2:], true
+		}
+	}
+	return "", "", false
+}
+
+// eventName returns a short name for an event's type
+func eventName(ev network.Event) string {
+	switch ev.(type) {
+	case network.IdentifiedEvent:
+		return "identified"
+	case network.EntityAddedEvent:
+		return "entity_added"
+	case network.EntityRemovedEvent:
+		return "entity_removed"
+	case network.EntityMovedEvent:
+		return "entity_moved"
+	case network.EntityMetadataEvent:
+		return "entity_metadata"
+	case network.ChunkEvent:
+		return "chunk"
+	case network.MonoChunkEvent:
+		return "mono_chunk"
+	case network.BlocksChangedEvent:
+		return "blocks_changed"
+	case network.ChunkUnloadedEvent:
+		return "chunk_unloaded"
+	case network.ChatEvent:
+		return "chat"
+	}
+	return fmt.Sprintf("%T", ev)
+}
+
+// describeEvent formats an event for the log, without the contents of chunks
+func describeEvent(ev network.Event) string {
+	switch ev := ev.(type) {
+	case network.ChunkEvent:
+		return fmt.Sprintf("chunk (%d, %d, %d), block states %t", ev.X, ev.Y, ev.Z, ev.Props != nil)
+	case network.MonoChunkEvent:
+		return fmt.Sprintf("mono chunk (%d, %d, %d) of %s", ev.X, ev.Y, ev.Z, ev.BlockType)
+	case network.BlocksChangedEvent:
+		return fmt.Sprintf("%d block changes in chunk (%d, %d, %d)",
+			len(ev.Changes), ev.Chunk.X, ev.Chunk.Y, ev.Chunk.Z)
+	}
+	return fmt.Sprintf("%s %+v", eventName(ev), ev)
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/leterax/go-voxels/pkg/network"
+)
+
+// options holds the command line flags
+type options struct {
+	addr           string
+	name           string
+	renderDistance int
+	wander         int
+	scriptPath     string
+	rate           float64
+	follow         int64
+	respond        bool
+	speed          float64
+	verbose        bool
+	duration       time.Duration
+	recordPath     string
+	console        bool
+}
+
+func main() {
+	// Parse command line flags
+	var opts options
+	flag.StringVar(&opts.addr, "server", "localhost:20000", "Server address, ws:// or wss:// for WebSocket")
+	flag.StringVar(&opts.name, "name", "bot", "Player name")
+	flag.IntVar(&opts.renderDistance, "renderdist", 4, "Render distance in chunks")
+	flag.IntVar(&opts.wander, "wander", 0, "Wander within this many blocks of the spawn point (disabled if zero)")
+	flag.StringVar(&opts.scriptPath, "script", "", "Build script to run once spawned")
+	flag.Float64Var(&opts.rate, "rate", 20, "Blocks placed per second by the build script")
+	flag.Int64Var(&opts.follow, "follow", -1, "Entity ID of a player to follow (disabled if negative)")
+	flag.BoolVar(&opts.respond, "respond", true, "Answer chat commands such as !ping, !come and !follow")
+	flag.Float64Var(&opts.speed, "speed", 4.3, "Walking speed in blocks per second")
+	flag.BoolVar(&opts.verbose, "v", false, "Log every event received")
+	flag.DurationVar(&opts.duration, "duration", 0, "Disconnect after this long (run until interrupted if zero)")
+	flag.StringVar(&opts.recordPath, "record", "", "Record received packets to this capture file")
+	flag.BoolVar(&opts.console, "console", false, "Send lines typed on standard input as chat, running slash commands like /tp and /fill")
+	flag.Parse()
+
+	log.SetFlags(log.LstdFlags | log.Lmicroseconds)
+	if err := run(opts); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run connects the bot and drives it until the connection ends, it is interrupted or the duration is over
+func run(opts options) error {
+	if opts.renderDistance < 0 || opts.renderDistance > 255 {
+		return fmt.Errorf("render distance must be between 0 and 255")
+	}
+
+	var script []scriptStep
+	if opts.scriptPath != "" {
+		var err error
+		if script, err = loadScript(opts.scriptPath); err != nil {
+			return fmt.Errorf("failed to load script: %w", err)
+		}
+	}
+
+	client, err := network.NewClient(opts.addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer client.Close()
+	log.Printf("Connected to %s, protocol version %d, capabilities %#x",
+		opts.addr, client.ProtocolVersion(), client.Capabilities())
+
+	if opts.recordPath != "" {
+		file, err := os.Create(opts.recordPath)
+		if err != nil {
+			return fmt.Errorf("failed to create capture: %w", err)
+		}
+		defer file.Close()
+		recorder, err := network.NewRecorder(file)
+		if err != nil {
+			return fmt.Errorf("failed to start capture: %w", err)
+		}
+		// Run flushes the recorder when it returns
+		client.SetRecorder(recorder)
+	}
+
+	bot := newBot(client, opts.name, opts.speed, opts.verbose)
+	bot.behaviours = append(bot.behaviours, &followBehaviour{})
+	if opts.follow >= 0 {
+		bot.followEntity(uint32(opts.follow))
+	}
+	if opts.wander > 0 {
+		bot.behaviours = append(bot.behaviours, newWanderBehaviour(int32(opts.wander)))
+	}
+	if script != nil {
+		bot.script = newBuildBehaviour(script, opts.rate)
+		bot.behaviours = append(bot.behaviours, bot.script)
+	}
+	if opts.respond {
+		bot.behaviours = append(bot.behaviours, &chatResponder{})
+	}
+	if opts.console {
+		bot.enableConsole(readLines(os.Stdin))
+	}
+
+	client.SetEntityName(opts.name)
+	client.SetRenderDistance(uint8(opts.renderDistance))
+	if err := client.SendClientMetadata(); err != nil {
+		return fmt.Errorf("failed to send client metadata: %w", err)
+	}
+
+	// Stop cleanly on Ctrl+C or once the duration is over
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if opts.duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.duration)
+		defer cancel()
+	}
+
+	err = bot.run(ctx)
+	bot.report()
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return nil
+	}
+	return fmt.Errorf("connection lost: %w", err)
+}
+
+// readLines returns a channel receiving the lines of r, closed at the end of r
+func readLines(r io.Reader) <-chan string {
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+	return lines
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/leterax/go-voxels/pkg/pathfind"
+	"github.com/leterax/go-voxels/pkg/voxel"
+)
+
+// maxStepBlocks is the most blocks a single script command may place
+const maxStepBlocks = 1 << 16
+
+// A build script has one command per line, blank lines and # comments are ignored:
+//
+//	block  <block> x y z
+//	fill   <block> x1 y1 z1 x2 y2 z2   solid box
+//	hollow <block> x1 y1 z1 x2 y2 z2   walls, floor and ceiling of a box
+//	line   <block> x1 y1 z1 x2 y2 z2
+//	sphere <block> x y z radius
+//	wait   <duration>
+//	chat   <message>
+//
+// Blocks are names or IDs, optionally followed by :properties. Coordinates are absolute,
+// or relative to where the bot stands when the script starts if prefixed with ~.
+
+// scriptCoord is a coordinate of a script command
+type scriptCoord struct {
+	value    int32
+	relative bool
+}
+
+// scriptStep is a single command of a build script
+type scriptStep struct {
+	line    int
+	command string
+	state   voxel.BlockState
+	coords  []scriptCoord // Three per point
+	radius  int32
+	wait    time.Duration
+	message string
+}
+
+// placement is a block the script places, in world coordinates
+type placement struct {
+	X, Y, Z int32
+	State   voxel.BlockState
+}
+
+// loadScript reads and parses a build script
+func loadScript(path string) ([]scriptStep, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var steps []scriptStep
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		step, err := parseStep(fields)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		step.line = line
+		steps = append(steps, step)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return steps, nil
+}
+
+// parseStep parses the fields of a script line
+func parseStep(fields []string) (scriptStep, error) {
+	step := scriptStep{command: fields[0]}
+	args := fields[1:]
+
+	switch step.command {
+	case "wait":
+		if len(args) != 1 {
+			return step, fmt.Errorf("wait takes a duration")
+		}
+		wait, err := time.ParseDuration(args[0])
+		if err != nil {
+			return step, err
+		}
+		step.wait = wait
+		return step, nil
+	case "chat":
+		if len(args) == 0 {
+			return step, fmt.Errorf("chat takes a message")
+		}
+		step.message = strings.Join(args, " ")
+		return step, nil
+	}
+
+	var coords int
+	switch step.command {
+	case "block":
+		coords = 3
+	case "fill", "hollow", "line":
+		coords = 6
+	case "sphere":
+		coords = 4
+	default:
+		return step, fmt.Errorf("unknown command %q", step.command)
+	}
+	if len(args) != 1+coords {
+		return step, fmt.Errorf("%s takes a block and %d numbers", step.command, coords)
+	}
+
+	state, err := voxel.ParseBlockState(args[0])
+	if err != nil {
+		return step, err
+	}
+	step.state = state
+
+	if step.command == "sphere" {
+		radius, err := strconv.ParseInt(args[4], 10, 32)
+		if err != nil || radius < 0 || radius > 32 {
+			return step, fmt.Errorf("invalid sphere radius %q, must be between 0 and 32", args[4])
+		}
+		step.radius = int32(radius)
+		coords = 3
+	}
+	for _, arg := range args[1 : 1+coords] {
+		coord, err := parseCoord(arg)
+		if err != nil {
+			return step, err
+		}
+		step.coords = append(step.coords, coord)
+	}
+	return step, nil
+}
+
+// parseCoord parses an absolute coordinate or a ~ relative one
+func parseCoord(text string) (scriptCoord, error) {
+	coord := scriptCoord{}
+	if rest, ok := strings.CutPrefix(text, "~"); ok {
+		coord.relative = true
+		if text = rest; text == "" {
+			return coord, nil
+		}
+	}
+	value, err := strconv.ParseInt(text, 10, 32)
+	if err != nil {
+		return coord, fmt.Errorf("invalid coordinate %q", text)
+	}
+	coord.value = int32(value)
+	return coord, nil
+}
+
+// point resolves the i-th point of the step against the script's origin
+func (s *scriptStep) point(i int, origin pathfind.Pos) pathfind.Pos {
+	resolve := func(coord scriptCoord, base int32) int32 {
+		if coord.relative {
+			return base + coord.value
+		}
+		return coord.value
+	}
+	c := s.coords[i*3 : i*3+3]
+	return pathfind.Pos{X: resolve(c[0], origin.X), Y: resolve(c[1], origin.Y), Z: resolve(c[2], origin.Z)}
+}
+
+// blocks returns the blocks placed by the step, relative coordinates resolved against origin
+func (s *scriptStep) blocks(origin pathfind.Pos) ([]placement, error) {
+	at := func(p pathfind.Pos) placement {
+		return placement{X: p.X, Y: p.Y, Z: p.Z, State: s.state}
+	}
+
+	switch s.command {
+	case "block":
+		return []placement{at(s.point(0, origin))}, nil
+	case "fill", "hollow":
+		from, to := s.point(0, origin), s.point(1, origin)
+		lo := pathfind.Pos{X: min(from.X, to.X), Y: min(from.Y, to.Y), Z: min(from.Z, to.Z)}
+		hi := pathfind.Pos{X: max(from.X, to.X), Y: max(from.Y, to.Y), Z: max(from.Z, to.Z)}
+		volume := int64(hi.X-lo.X+1) * int64(hi.Y-lo.Y+1) * int64(hi.Z-lo.Z+1)
+		if volume > maxStepBlocks {
+			return nil, fmt.Errorf("box of %d blocks exceeds the limit of %d", volume, maxStepBlocks)
+		}
+
+		var blocks []placement
+		for x := lo.X; x <= hi.X; x++ {
+			for y := lo.Y; y <= hi.Y; y++ {
+				for z := lo.Z; z <= hi.Z; z++ {
+					inside := x > lo.X && x < hi.X && y > lo.Y && y < hi.Y && z > lo.Z && z < hi.Z
+					if s.command == "fill" || !inside {
+						blocks = append(blocks, at(pathfind.Pos{X: x, Y: y, Z: z}))
+					}
+				}
+			}
+		}
+		return blocks, nil
+	case "line":
+		from, to := s.point(0, origin), s.point(1, origin)
+		dx, dy, dz := int64(to.X-from.X), int64(to.Y-from.Y), int64(to.Z-from.Z)
+		steps := max(abs64(dx), abs64(dy), abs64(dz))
+		if steps >= maxStepBlocks {
+			return nil, fmt.Errorf("line of %d blocks exceeds the limit of %d", steps+1, maxStepBlocks)
+		}
+
+		blocks := []placement{at(from)}
+		for i := int64(1); i <= steps; i++ {
+			step := func(start int32, delta int64) int32 {
+				return start + int32(math.Round(float64(delta*i)/float64(steps)))
+			}
+			blocks = append(blocks, at(pathfind.Pos{X: step(from.X, dx), Y: step(from.Y, dy), Z: step(from.Z, dz)}))
+		}
+		return blocks, nil
+	case "sphere":
+		center := s.point(0, origin)
+		limit := (float64(s.radius) + 0.5) * (float64(s.radius) + 0.5)
+		var blocks []placement
+		for x := -s.radius; x <= s.radius; x++ {
+			for y := -s.radius; y <= s.radius; y++ {
+				for z := -s.radius; z <= s.radius; z++ {
+					if float64(x*x+y*y+z*z) <= limit {
+						blocks = append(blocks, at(center.Add(pathfind.Pos{X: x, Y: y, Z: z})))
+					}
+				}
+			}
+		}
+		return blocks, nil
+	}
+	return nil, nil
+}
+
+func abs64(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// buildBehaviour runs a build script once spawned, placing blocks at a steady rate
+type buildBehaviour struct {
+	script []scriptStep
+	rate   float64 // Blocks per second
+
+	running   bool
+	originSet bool
+	origin    pathfind.Pos
+	step      int
+	queue     []placement
+	budget    float64 // Blocks that may be placed right now
+	last      time.Time
+	waitUntil time.Time
+}
+
+func newBuildBehaviour(script []scriptStep, rate float64) *buildBehaviour {
+	return &buildBehaviour{script: script, rate: max(rate, 1), running: true}
+}
+
+// restart runs the script again from the start, around where the bot stands then
+func (s *buildBehaviour) restart() {
+	*s = buildBehaviour{script: s.script, rate: s.rate, running: true}
+}
+
+func (s *buildBehaviour) tick(b *bot, now time.Time) {
+	if !s.running {
+		return
+	}
+	if !s.originSet {
+		s.origin, s.originSet = b.footCell(), true
+		s.last = now
+		log.Printf("Running build script at (%d, %d, %d)", s.origin.X, s.origin.Y, s.origin.Z)
+	}
+	// Allow at most a second's worth of blocks in a burst
+	s.budget = min(s.budget+s.rate*now.Sub(s.last).Seconds(), s.rate)
+	s.last = now
+	if now.Before(s.waitUntil) {
+		return
+	}
+
+	world := b.predictor.World()
+	for {
+		for len(s.queue) > 0 && s.budget >= 1 {
+			p := s.queue[0]
+			s.queue = s.queue[1:]
+			if world.GetState(p.X, p.Y, p.Z) == p.State {
+				continue
+			}
+			if err := b.predictor.SetState(p.X, p.Y, p.Z, p.State); err != nil {
+				log.Printf("Build script stopped: %v", err)
+				s.running = false
+				return
+			}
+			s.budget--
+		}
+		if len(s.queue) > 0 {
+			return
+		}
+
+		if s.step >= len(s.script) {
+			log.Printf("Build script finished")
+			s.running = false
+			return
+		}
+		step := &s.script[s.step]
+		s.step++
+		switch step.command {
+		case "wait":
+			s.waitUntil = now.Add(step.wait)
+			return
+		case "chat":
+			b.say("%s", step.message)
+		default:
+			blocks, err := step.blocks(s.origin)
+			if err != nil {
+				log.Printf("Skipping script line %d: %v", step.line, err)
+			}
+			s.queue = blocks
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/leterax/go-voxels/pkg/server"
+)
+
+// options holds the command line flags
+type options struct {
+	addr           string
+	clients        int
+	ramp           time.Duration
+	duration       time.Duration
+	renderDistance int
+	speed          float64 // Blocks per second, 0 to stand still
+	radius         float64 // Of the circle each client walks
+	spread         float64 // How far from spawn the circles are centered
+	editRate       float64 // Block edits per second per client
+	jsonPath       string
+	seed           int64
+	serverLog      bool
+}
+
+func main() {
+	// Parse command line flags
+	var opts options
+	flag.StringVar(&opts.addr, "server", "", "Server address, ws:// or wss:// for WebSocket (an in-process server if empty)")
+	flag.IntVar(&opts.clients, "clients", 50, "Number of simulated clients")
+	flag.DurationVar(&opts.ramp, "ramp", 5*time.Second, "Time over which clients connect")
+	flag.DurationVar(&opts.duration, "duration", 30*time.Second, "Length of the test, including the ramp")
+	flag.IntVar(&opts.renderDistance, "renderdist", 4, "Render distance of every client in chunks")
+	flag.Float64Var(&opts.speed, "speed", 4.3, "Walking speed in blocks per second (0 to stand still)")
+	flag.Float64Var(&opts.radius, "radius", 24, "Radius of the circle each client walks, in blocks")
+	flag.Float64Var(&opts.spread, "spread", 64, "Largest distance of circle centers from the spawn point, in blocks")
+	flag.Float64Var(&opts.editRate, "edits", 1, "Block edits per second per client (0 to disable)")
+	flag.StringVar(&opts.jsonPath, "json", "", "Write the results as JSON to this file")
+	flag.Int64Var(&opts.seed, "seed", 0, "Terrain seed of the in-process server")
+	flag.BoolVar(&opts.serverLog, "serverlog", false, "Show the in-process server's log")
+	flag.Parse()
+
+	if err := run(opts); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run starts the clients, waits for the test to end and reports the results
+func run(opts options) error {
+	if opts.clients <= 0 {
+		return fmt.Errorf("need at least one client")
+	}
+	if opts.renderDistance < 0 || opts.renderDistance > 255 {
+		return fmt.Errorf("render distance must be between 0 and 255")
+	}
+	if opts.radius <= 0 {
+		return fmt.Errorf("radius must be positive")
+	}
+
+	target := opts.addr
+	if opts.addr == "" {
+		srv, addr, err := startServer(opts)
+		if err != nil {
+			return err
+		}
+		defer srv.Close()
+		opts.addr = addr
+		target = "in-process server"
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, opts.duration)
+	defer cancel()
+
+	log.Printf("Starting %d clients against %s over %s", opts.clients, opts.addr, opts.ramp)
+	start := time.Now()
+	sims := make([]*simClient, opts.clients)
+	var wg sync.WaitGroup
+	for i := range sims {
+		// Spread connections evenly over the ramp
+		delay := time.Duration(int64(opts.ramp) * int64(i) / int64(opts.clients))
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Until(start.Add(delay))):
+		}
+		if ctx.Err() != nil {
+			sims = sims[:i]
+			break
+		}
+
+		sim := newSimClient(i, &opts)
+		sims[i] = sim
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sim.run(ctx)
+		}()
+	}
+	wg.Wait()
+
+	results := collect(sims, target, time.Since(start))
+	results.print(os.Stdout)
+	if opts.jsonPath != "" {
+		if err := results.writeJSON(opts.jsonPath); err != nil {
+			return fmt.Errorf("failed to write results: %w", err)
+		}
+	}
+	return nil
+}
+
+// startServer runs a server on a local port, as a stand-in when no address was given
+func startServer(opts options) (*server.Server, string, error) {
+	logger := log.New(io.Discard, "", 0)
+	if opts.serverLog {
+		logger = log.Default()
+	}
+	srv := server.New(server.Config{
+		Generator:         server.NewTerrainGenerator(opts.seed),
+		MaxRenderDistance: max(opts.renderDistance, server.DefaultMaxRenderDistance),
+		Logger:            logger,
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to listen: %w", err)
+	}
+	go func() {
+		if err := srv.Serve(listener); err != nil && !errors.Is(err, server.ErrServerClosed) {
+			log.Printf("Server error: %v", err)
+		}
+	}()
+	return srv, listener.Addr().String(), nil
+}
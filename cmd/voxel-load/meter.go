@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/leterax/go-voxels/pkg/network/packet"
+)
+
+// packetCount is the traffic of a single packet type
+type packetCount struct {
+	Packets int64 `json:"packets"`
+	Bytes   int64 `json:"bytes"`
+}
+
+// packetMeter counts the bytes of every packet in one direction of a connection,
+// parsing the stream as it passes: the unframed Hello, then length-prefixed packets.
+// Streams that aren't framed after the Hello are only counted as a whole.
+type packetMeter struct {
+	registry  *packet.Registry
+	helloSize int // ID and payload of the Hello opening the stream
+
+	mu        sync.Mutex
+	header    []byte // Partial Hello or frame header
+	remaining int    // Bytes left of the current packet
+	helloDone bool
+	framed    bool
+	total     int64
+	counts    map[uint8]*packetCount
+}
+
+func newPacketMeter(registry *packet.Registry) *packetMeter {
+	var hello packet.Packet = &packet.ServerHello{}
+	if registry.Direction == packet.ServerBoundDirection {
+		hello = &packet.ClientHello{}
+	}
+	return &packetMeter{
+		registry:  registry,
+		helloSize: len(packet.Encode(hello)),
+		counts:    make(map[uint8]*packetCount),
+	}
+}
+
+// frameHeaderSize is the length prefix followed by the packet ID
+const frameHeaderSize = 4 + 1
+
+// record feeds bytes read from or written to the connection
+func (m *packetMeter) record(data []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.total += int64(len(data))
+
+	for len(data) > 0 {
+		if m.helloDone && !m.framed {
+			return
+		}
+		if m.remaining > 0 {
+			n := min(m.remaining, len(data))
+			m.remaining -= n
+			data = data[n:]
+			continue
+		}
+
+		need := frameHeaderSize
+		if !m.helloDone {
+			need = m.helloSize
+		}
+		n := min(need-len(m.header), len(data))
+		m.header = append(m.header, data[:n]...)
+		data = data[n:]
+		if len(m.header) < need {
+			return
+		}
+
+		if !m.helloDone {
+			// Flags are the last field of both Hello packets
+			flags := binary.BigEndian.Uint32(m.header[len(m.header)-4:])
+			m.framed = flags&packet.CapabilityFramed != 0
+			m.helloDone = true
+			m.add(m.header[0], len(m.header))
+		} else {
+			length := int(binary.BigEndian.Uint32(m.header))
+			if length == 0 {
+				// Not a valid frame, stop attributing
+				m.framed = false
+				return
+			}
+			m.add(m.header[4], 4+length)
+			m.remaining = length - 1
+		}
+		m.header = m.header[:0]
+	}
+}
+
+// add counts a packet. The caller must hold m.mu.
+func (m *packetMeter) add(id uint8, size int) {
+	count := m.counts[id]
+	if count == nil {
+		count = &packetCount{}
+		m.counts[id] = count
+	}
+	count.Packets++
+	count.Bytes += int64(size)
+}
+
+// snapshot returns the total bytes and the traffic per packet name
+func (m *packetMeter) snapshot() (int64, map[string]packetCount) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	counts := make(map[string]packetCount, len(m.counts))
+	for id, count := range m.counts {
+		counts[packetName(m.registry, id)] = *count
+	}
+	return m.total, counts
+}
+
+// packetName returns the type name of a packet ID, or the ID in hex if it is unknown
+func packetName(registry *packet.Registry, id uint8) string {
+	p, ok := registry.New(id)
+	if !ok {
+		return fmt.Sprintf("0x%02x", id)
+	}
+	return strings.TrimPrefix(fmt.Sprintf("%T", p), "*packet.")
+}
+
+// meteredConn feeds everything read and written through a connection to meters
+type meteredConn struct {
+	net.Conn
+	in, out *packetMeter
+}
+
+func (c *meteredConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.in.record(b[:n])
+	return n, err
+}
+
+func (c *meteredConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.out.record(b[:n])
+	return n, err
+}
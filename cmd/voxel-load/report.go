@@ -0,0 +1,189 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"time"
+)
+
+// latencySummary describes a series of latency samples in milliseconds
+type latencySummary struct {
+	Samples int     `json:"samples"`
+	Mean    float64 `json:"mean_ms"`
+	P50     float64 `json:"p50_ms"`
+	P90     float64 `json:"p90_ms"`
+	P99     float64 `json:"p99_ms"`
+	Max     float64 `json:"max_ms"`
+}
+
+func summarize(samples []time.Duration) latencySummary {
+	if len(samples) == 0 {
+		return latencySummary{}
+	}
+	sorted := slices.Clone(samples)
+	slices.Sort(sorted)
+
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+	percentile := func(p float64) float64 {
+		return ms(sorted[min(int(p*float64(len(sorted))), len(sorted)-1)])
+	}
+	var total time.Duration
+	for _, sample := range sorted {
+		total += sample
+	}
+	return latencySummary{
+		Samples: len(sorted),
+		Mean:    ms(total / time.Duration(len(sorted))),
+		P50:     percentile(0.50),
+		P90:     percentile(0.90),
+		P99:     percentile(0.99),
+		Max:     ms(sorted[len(sorted)-1]),
+	}
+}
+
+// results is the outcome of a load test, as written to the JSON file
+type results struct {
+	Server         string   `json:"server"`
+	Clients        int      `json:"clients"`
+	Duration       float64  `json:"duration_s"`
+	Connected      int      `json:"connected"`
+	FailedConnects int      `json:"failed_connects"`
+	Dropped        int      `json:"dropped"`
+	Errors         []string `json:"errors,omitempty"`
+
+	Connect        latencySummary `json:"connect"`
+	FirstChunk     latencySummary `json:"first_chunk"`
+	ChunkAfterMove latencySummary `json:"chunk_after_move"`
+	EditRoundTrip  latencySummary `json:"edit_round_trip"`
+
+	Chunks       int `json:"chunks"`
+	EditsSent    int `json:"edits_sent"`
+	EditsLost    int `json:"edits_lost"`
+	EditsPending int `json:"edits_pending"`
+
+	BytesIn    int64                  `json:"bytes_in"`
+	BytesOut   int64                  `json:"bytes_out"`
+	PacketsIn  map[string]packetCount `json:"packets_in"`
+	PacketsOut map[string]packetCount `json:"packets_out"`
+}
+
+// maxErrors is how many distinct error messages are kept in the results
+const maxErrors = 10
+
+// collect merges the measurements of every simulated client
+func collect(sims []*simClient, server string, elapsed time.Duration) *results {
+	r := &results{
+		Server:     server,
+		Clients:    len(sims),
+		Duration:   elapsed.Seconds(),
+		PacketsIn:  make(map[string]packetCount),
+		PacketsOut: make(map[string]packetCount),
+	}
+
+	var connect, firstChunk, chunkAfterMove, editRoundTrip []time.Duration
+	seen := make(map[string]bool)
+	addError := func(err error) {
+		if message := err.Error(); !seen[message] && len(r.Errors) < maxErrors {
+			seen[message] = true
+			r.Errors = append(r.Errors, message)
+		}
+	}
+	for _, sim := range sims {
+		stats := &sim.stats
+		if !stats.connected {
+			if stats.connectErr != nil {
+				r.FailedConnects++
+				addError(stats.connectErr)
+			}
+			continue
+		}
+		r.Connected++
+		if stats.dropErr != nil {
+			r.Dropped++
+			addError(stats.dropErr)
+		}
+
+		connect = append(connect, stats.connect)
+		firstChunk = append(firstChunk, stats.firstChunk...)
+		chunkAfterMove = append(chunkAfterMove, stats.chunkAfterMove...)
+		editRoundTrip = append(editRoundTrip, stats.editRoundTrip...)
+		r.Chunks += stats.chunks
+		r.EditsSent += stats.editsSent
+		r.EditsLost += stats.editsLost
+		r.EditsPending += stats.editsPending
+
+		merge := func(total *int64, counts map[string]packetCount, meter *packetMeter) {
+			bytes, meterCounts := meter.snapshot()
+			*total += bytes
+			for name, count := range meterCounts {
+				sum := counts[name]
+				sum.Packets += count.Packets
+				sum.Bytes += count.Bytes
+				counts[name] = sum
+			}
+		}
+		merge(&r.BytesIn, r.PacketsIn, sim.in)
+		merge(&r.BytesOut, r.PacketsOut, sim.out)
+	}
+
+	r.Connect = summarize(connect)
+	r.FirstChunk = summarize(firstChunk)
+	r.ChunkAfterMove = summarize(chunkAfterMove)
+	r.EditRoundTrip = summarize(editRoundTrip)
+	return r
+}
+
+// print writes a human readable summary
+func (r *results) print(w io.Writer) {
+	fmt.Fprintf(w, "%d clients against %s for %.1fs\n", r.Clients, r.Server, r.Duration)
+	fmt.Fprintf(w, "  connected %d, failed to connect %d, dropped %d\n", r.Connected, r.FailedConnects, r.Dropped)
+	for _, message := range r.Errors {
+		fmt.Fprintf(w, "    %s\n", message)
+	}
+
+	fmt.Fprintf(w, "\n  %-18s %8s %9s %9s %9s %9s %9s\n", "latency", "samples", "mean", "p50", "p90", "p99", "max")
+	for _, row := range []struct {
+		name    string
+		summary latencySummary
+	}{
+		{"connect", r.Connect},
+		{"first chunk", r.FirstChunk},
+		{"chunk after move", r.ChunkAfterMove},
+		{"edit round trip", r.EditRoundTrip},
+	} {
+		s := row.summary
+		fmt.Fprintf(w, "  %-18s %8d %7.1fms %7.1fms %7.1fms %7.1fms %7.1fms\n",
+			row.name, s.Samples, s.Mean, s.P50, s.P90, s.P99, s.Max)
+	}
+	fmt.Fprintf(w, "\n  chunks received %d, edits sent %d, lost %d, pending at the end %d\n",
+		r.Chunks, r.EditsSent, r.EditsLost, r.EditsPending)
+
+	printTraffic := func(direction string, total int64, counts map[string]packetCount) {
+		fmt.Fprintf(w, "\n  %s: %d bytes, %.1f KiB/s\n", direction, total, float64(total)/1024/max(r.Duration, 1e-9))
+		names := slices.Collect(maps.Keys(counts))
+		slices.SortFunc(names, func(a, b string) int {
+			return cmp.Compare(counts[b].Bytes, counts[a].Bytes)
+		})
+		for _, name := range names {
+			count := counts[name]
+			fmt.Fprintf(w, "    %-22s %10d packets %12d bytes %8.1f bytes/packet\n",
+				name, count.Packets, count.Bytes, float64(count.Bytes)/float64(count.Packets))
+		}
+	}
+	printTraffic("received", r.BytesIn, r.PacketsIn)
+	printTraffic("sent", r.BytesOut, r.PacketsOut)
+}
+
+// writeJSON writes the results to path
+func (r *results) writeJSON(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"github.com/leterax/go-voxels/pkg/network"
+	"github.com/leterax/go-voxels/pkg/network/packet"
+	"github.com/leterax/go-voxels/pkg/voxel"
+)
+
+// Simulation timing
+const (
+	moveInterval = 50 * time.Millisecond
+	editTimeout  = 10 * time.Second // Edits unanswered for this long count as lost
+)
+
+// simStats is what a simulated client measured
+type simStats struct {
+	connected      bool
+	connectErr     error
+	dropErr        error // Why the connection ended before the test, nil if it didn't
+	connect        time.Duration
+	firstChunk     []time.Duration // Metadata sent to first chunk, at most one sample
+	chunkAfterMove []time.Duration // Entering another chunk to the first chunk that came into range
+	editRoundTrip  []time.Duration // Block edit sent to the server's change received
+	chunks         int
+	editsSent      int
+	editsLost      int
+	editsPending   int // Still unanswered when the test ended
+}
+
+// pendingEdit is an edit waiting for the server to echo it
+type pendingEdit struct {
+	state voxel.BlockState
+	sent  time.Time
+}
+
+// simClient is one simulated player, moving in a circle and editing blocks around it
+type simClient struct {
+	id    int
+	opts  *options
+	in    *packetMeter
+	out   *packetMeter
+	stats simStats
+
+	client     *network.Client
+	spawned    bool
+	pos        packet.Position
+	center     packet.Position // Of the circle walked
+	angle      float64
+	chunk      voxel.ChunkCoord
+	movedAt    time.Time        // When the client left movedFrom, zero once a chunk that came into range arrived
+	movedFrom  voxel.ChunkCoord // Chunk the client was in before movedAt
+	edits      map[[3]int32]pendingEdit
+	placed     map[[3]int32]bool // Blocks this client set to stone, removed by its next edit there
+	metadataAt time.Time
+}
+
+func newSimClient(id int, opts *options) *simClient {
+	return &simClient{
+		id:     id,
+		opts:   opts,
+		in:     newPacketMeter(packet.ClientBound),
+		out:    newPacketMeter(packet.ServerBound),
+		edits:  make(map[[3]int32]pendingEdit),
+		placed: make(map[[3]int32]bool),
+	}
+}
+
+// run connects and plays until ctx is cancelled or the connection drops
+func (s *simClient) run(ctx context.Context) {
+	start := time.Now()
+	conn, err := network.Dial(s.opts.addr)
+	if err != nil {
+		s.stats.connectErr = err
+		return
+	}
+	client, err := network.NewClientConn(&meteredConn{Conn: conn, in: s.in, out: s.out}, packet.SupportedCapabilities)
+	if err != nil {
+		s.stats.connectErr = err
+		return
+	}
+	s.stats.connect = time.Since(start)
+	s.stats.connected = true
+	s.client = client
+
+	client.SetEntityName(fmt.Sprintf("load-%d", s.id))
+	client.SetRenderDistance(uint8(s.opts.renderDistance))
+	sub := client.Subscribe(network.SubscribeOptions{Buffer: 4096, Backpressure: network.BackpressureBlock})
+	done := make(chan error, 1)
+	go func() { done <- client.Run(ctx) }()
+
+	s.metadataAt = time.Now()
+	if err := client.SendClientMetadata(); err != nil {
+		client.Close()
+	}
+
+	move := time.NewTicker(moveInterval)
+	defer move.Stop()
+	var edit <-chan time.Time
+	if s.opts.editRate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / s.opts.editRate))
+		defer ticker.Stop()
+		edit = ticker.C
+	}
+
+	for {
+		select {
+		case ev, ok := <-sub.Events():
+			if !ok {
+				err := <-done
+				if ctx.Err() == nil && !errors.Is(err, network.ErrClientClosed) {
+					s.stats.dropErr = err
+				}
+				s.stats.editsPending = len(s.edits)
+				return
+			}
+			s.handleEvent(ev)
+		case now := <-move.C:
+			s.move(now)
+			s.expireEdits(now)
+		case <-edit:
+			s.edit()
+		}
+	}
+}
+
+// handleEvent takes the measurements covered by an event
+func (s *simClient) handleEvent(ev network.Event) {
+	now := time.Now()
+	switch ev := ev.(type) {
+	case network.EntityMovedEvent:
+		if ev.EntityID != s.client.EntityID() {
+			return
+		}
+		// Spawn point, spread the clients around it
+		pos := packet.Position{X: ev.X, Y: ev.Y, Z: ev.Z}
+		s.pos, s.center = pos, pos
+		s.center.X += float32((rand.Float64()*2 - 1) * s.opts.spread)
+		s.center.Z += float32((rand.Float64()*2 - 1) * s.opts.spread)
+		s.angle = rand.Float64() * 2 * math.Pi
+		s.chunk = chunkAt(pos)
+		s.spawned = true
+	case network.ChunkEvent:
+		s.chunkReceived(voxel.ChunkCoord{X: ev.X, Y: ev.Y, Z: ev.Z}, now)
+		if len(s.edits) == 0 {
+			return
+		}
+		chunk := voxel.NewChunkFromStates(ev.X, ev.Y, ev.Z, network.ChunkSize, ev.Blocks, ev.Props)
+		s.confirmChunk(voxel.ChunkCoord{X: ev.X, Y: ev.Y, Z: ev.Z}, now, func(x, y, z int) voxel.BlockState {
+			return chunk.GetState(x, y, z)
+		})
+	case network.MonoChunkEvent:
+		s.chunkReceived(voxel.ChunkCoord{X: ev.X, Y: ev.Y, Z: ev.Z}, now)
+		s.confirmChunk(voxel.ChunkCoord{X: ev.X, Y: ev.Y, Z: ev.Z}, now, func(int, int, int) voxel.BlockState {
+			return voxel.BlockState(ev.BlockType)
+		})
+	case network.BlocksChangedEvent:
+		for _, change := range ev.Changes {
+			s.confirm([3]int32{change.X, change.Y, change.Z}, change.State, now)
+		}
+	}
+}
+
+// chunkReceived records chunk delivery latencies
+func (s *simClient) chunkReceived(coord voxel.ChunkCoord, now time.Time) {
+	s.stats.chunks++
+	if s.stats.chunks == 1 {
+		s.stats.firstChunk = append(s.stats.firstChunk, now.Sub(s.metadataAt))
+	}
+	// Only chunks streamed because of the move, not the rest of an earlier stream or edits
+	distance := int32(s.opts.renderDistance)
+	if !s.movedAt.IsZero() && chunkDistance(coord, s.chunk) <= distance && chunkDistance(coord, s.movedFrom) > distance {
+		s.stats.chunkAfterMove = append(s.stats.chunkAfterMove, now.Sub(s.movedAt))
+		s.movedAt = time.Time{}
+	}
+}
+
+// confirmChunk checks the pending edits within a received chunk
+func (s *simClient) confirmChunk(coord voxel.ChunkCoord, now time.Time, state func(x, y, z int) voxel.BlockState) {
+	for pos := range s.edits {
+		if voxel.WorldToChunkCoord(pos[0], pos[1], pos[2], network.ChunkSize) != coord {
+			continue
+		}
+		x, y, z := voxel.WorldToLocalCoord(pos[0], pos[1], pos[2], network.ChunkSize)
+		s.confirm(pos, state(x, y, z), now)
+	}
+}
+
+// confirm records the round trip of a pending edit if the server now shows it
+func (s *simClient) confirm(pos [3]int32, state voxel.BlockState, now time.Time) {
+	edit, ok := s.edits[pos]
+	if !ok || edit.state != state {
+		return
+	}
+	s.stats.editRoundTrip = append(s.stats.editRoundTrip, now.Sub(edit.sent))
+	delete(s.edits, pos)
+}
+
+// expireEdits gives up on edits the server never echoed
+func (s *simClient) expireEdits(now time.Time) {
+	for pos, edit := range s.edits {
+		if now.Sub(edit.sent) > editTimeout {
+			s.stats.editsLost++
+			delete(s.edits, pos)
+		}
+	}
+}
+
+// move advances along the circle and sends the new position
+func (s *simClient) move(now time.Time) {
+	if !s.spawned || s.opts.speed <= 0 {
+		return
+	}
+	s.angle += s.opts.speed * moveInterval.Seconds() / s.opts.radius
+	pos := packet.Position{
+		X:   s.center.X + float32(math.Cos(s.angle)*s.opts.radius),
+		Y:   s.center.Y,
+		Z:   s.center.Z + float32(math.Sin(s.angle)*s.opts.radius),
+		Yaw: float32(math.Mod(s.angle*180/math.Pi+90, 360)),
+	}
+	s.pos = pos
+	if chunk := chunkAt(pos); chunk != s.chunk {
+		if s.movedAt.IsZero() {
+			s.movedAt, s.movedFrom = now, s.chunk
+		}
+		s.chunk = chunk
+	}
+	s.client.SendUpdateEntity(pos.X, pos.Y, pos.Z, pos.Yaw, pos.Pitch)
+}
+
+// edit toggles a block near the client between stone and air
+func (s *simClient) edit() {
+	if !s.spawned {
+		return
+	}
+	pos := [3]int32{
+		int32(math.Floor(float64(s.pos.X))) + rand.Int32N(17) - 8,
+		int32(math.Floor(float64(s.pos.Y))) + rand.Int32N(4),
+		int32(math.Floor(float64(s.pos.Z))) + rand.Int32N(17) - 8,
+	}
+	if _, waiting := s.edits[pos]; waiting {
+		return
+	}
+
+	// Air in a chunk that was never sent isn't echoed, so only remove blocks placed before
+	blockType := voxel.Stone
+	if s.placed[pos] {
+		blockType = voxel.Air
+		delete(s.placed, pos)
+	} else {
+		s.placed[pos] = true
+	}
+	if err := s.client.SendUpdateBlock(blockType, pos[0], pos[1], pos[2]); err != nil {
+		return
+	}
+	s.edits[pos] = pendingEdit{state: voxel.BlockState(blockType), sent: time.Now()}
+	s.stats.editsSent++
+}
+
+// chunkAt returns the chunk containing a position
+func chunkAt(pos packet.Position) voxel.ChunkCoord {
+	return voxel.WorldToChunkCoord(
+		int32(math.Floor(float64(pos.X))),
+		int32(math.Floor(float64(pos.Y))),
+		int32(math.Floor(float64(pos.Z))),
+		network.ChunkSize,
+	)
+}
+
+// chunkDistance returns the largest distance along an axis between two chunks,
+// the measure render distances use
+func chunkDistance(a, b voxel.ChunkCoord) int32 {
+	return max(abs32(a.X-b.X), abs32(a.Y-b.Y), abs32(a.Z-b.Z))
+}
+
+func abs32(v int32) int32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/leterax/go-voxels/pkg/network/packet"
+)
+
+// faultAction is what a fault rule does to a matching packet
+type faultAction uint8
+
+const (
+	faultDrop    faultAction = iota // Not forwarded
+	faultDelay                      // Forwarded late, holding back the packets behind it
+	faultCorrupt                    // A random payload bit flipped, the frame left intact
+)
+
+// String returns the action's name as shown in the log
+func (a faultAction) String() string {
+	switch a {
+	case faultDrop:
+		return "dropped"
+	case faultDelay:
+		return "delayed"
+	case faultCorrupt:
+		return "corrupted"
+	}
+	return "unknown"
+}
+
+// faultRule applies an action to packets of one type
+type faultRule struct {
+	action      faultAction
+	packet      string  // Lowercase packet name
+	probability float64 // Of applying to a matching packet
+	delay       time.Duration
+}
+
+// String describes the rule for the log
+func (r faultRule) String() string {
+	s := fmt.Sprintf("%s %s", packetNames[r.packet], r.action)
+	if r.action == faultDelay {
+		s += " by " + r.delay.String()
+	}
+	if r.probability < 1 {
+		s += fmt.Sprintf(" with probability %g", r.probability)
+	}
+	return s
+}
+
+// faultFlag collects repeated -drop, -delay and -corrupt flags into rules
+type faultFlag struct {
+	action faultAction
+	rules  *[]faultRule
+}
+
+func (f faultFlag) String() string {
+	return ""
+}
+
+// Set parses Name[:probability] for drop and corrupt, Name:duration[:probability] for delay
+func (f faultFlag) Set(value string) error {
+	parts := strings.Split(value, ":")
+	name, err := lookupPacket(parts[0])
+	if err != nil {
+		return err
+	}
+	rule := faultRule{action: f.action, packet: name, probability: 1}
+	args := parts[1:]
+
+	if f.action == faultDelay {
+		if len(args) == 0 {
+			return fmt.Errorf("missing delay, expected %s:duration", parts[0])
+		}
+		if rule.delay, err = time.ParseDuration(args[0]); err != nil || rule.delay < 0 {
+			return fmt.Errorf("invalid delay %q", args[0])
+		}
+		args = args[1:]
+	}
+	if len(args) > 0 {
+		if rule.probability, err = strconv.ParseFloat(args[0], 64); err != nil || rule.probability < 0 || rule.probability > 1 {
+			return fmt.Errorf("invalid probability %q, expected a number from 0 to 1", args[0])
+		}
+		args = args[1:]
+	}
+	if len(args) > 0 {
+		return fmt.Errorf("unexpected %q after the fault", strings.Join(args, ":"))
+	}
+
+	*f.rules = append(*f.rules, rule)
+	return nil
+}
+
+// faults decides which rules apply to a packet
+type faults []faultRule
+
+// pick returns the rules applying to this packet, rolling their probabilities
+func (fs faults) pick(name string) []faultRule {
+	var picked []faultRule
+	for _, rule := range fs {
+		if rule.packet == strings.ToLower(name) && rand.Float64() < rule.probability {
+			picked = append(picked, rule)
+		}
+	}
+	return picked
+}
+
+// corrupt flips a random bit of the payload, or of the ID if there is no payload
+func corrupt(f *frame) {
+	target := f.raw[f.idOffset:]
+	if payload := f.payload(); len(payload) > 0 {
+		target = payload
+	}
+	target[rand.IntN(len(target))] ^= 1 << rand.IntN(8)
+}
+
+// packetNames lists the lowercase name of every packet in both directions
+var packetNames = func() map[string]string {
+	names := make(map[string]string)
+	for _, registry := range []*packet.Registry{packet.ClientBound, packet.ServerBound} {
+		for _, id := range registry.IDs() {
+			name := packetName(registry, id)
+			names[strings.ToLower(name)] = name
+		}
+	}
+	return names
+}()
+
+// lookupPacket checks a packet name, ignoring case, and returns it lowercase
+func lookupPacket(name string) (string, error) {
+	lower := strings.ToLower(strings.TrimSpace(name))
+	if _, ok := packetNames[lower]; !ok {
+		known := make([]string, 0, len(packetNames))
+		for _, name := range packetNames {
+			known = append(known, name)
+		}
+		slices.Sort(known)
+		return "", fmt.Errorf("unknown packet %q, known are %s", name, strings.Join(known, ", "))
+	}
+	return lower, nil
+}
+
+// packetFilter selects the packets to log
+type packetFilter struct {
+	only map[string]bool // Log nothing else if not empty
+	hide map[string]bool
+}
+
+// parsePacketList parses comma-separated packet names into a set
+func parsePacketList(list string) (map[string]bool, error) {
+	set := make(map[string]bool)
+	for name := range strings.SplitSeq(list, ",") {
+		if strings.TrimSpace(name) == "" {
+			continue
+		}
+		lower, err := lookupPacket(name)
+		if err != nil {
+			return nil, err
+		}
+		set[lower] = true
+	}
+	return set, nil
+}
+
+// shows reports whether packets with the name are logged
+func (f *packetFilter) shows(name string) bool {
+	name = strings.ToLower(name)
+	if len(f.only) > 0 && !f.only[name] {
+		return false
+	}
+	return !f.hide[name]
+}
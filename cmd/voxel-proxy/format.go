@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/leterax/go-voxels/pkg/network/packet"
+)
+
+// packetName returns the type name of a packet ID, or the ID in hex if it is unknown
+func packetName(registry *packet.Registry, id uint8) string {
+	p, ok := registry.New(id)
+	if !ok {
+		return fmt.Sprintf("0x%02x", id)
+	}
+	return strings.TrimPrefix(fmt.Sprintf("%T", p), "*packet.")
+}
+
+// fields returns what to show of a packet: the packet itself, or a summary instead of
+// the contents of chunks and bulk edits
+func fields(p packet.Packet) any {
+	switch p := p.(type) {
+	case *packet.SendChunk:
+		return struct {
+			X, Y, Z int32
+			Blocks  int
+		}{p.X, p.Y, p.Z, len(p.Blocks)}
+	case *packet.SendChunkStates:
+		return struct {
+			X, Y, Z int32
+			Blocks  int
+			Props   int
+		}{p.X, p.Y, p.Z, len(p.Blocks), len(p.Props)}
+	case *packet.SendCompressedChunk:
+		return struct {
+			X, Y, Z  int32
+			Encoding uint8
+			HasProps bool
+			Data     int
+		}{p.X, p.Y, p.Z, p.Encoding, p.HasProps, len(p.Data)}
+	case *packet.BlockBulkEdit:
+		return struct{ Updates int }{len(p.Updates)}
+	case *packet.MultiBlockChange:
+		if len(p.Changes) > 8 {
+			return struct {
+				X, Y, Z int32
+				Changes int
+			}{p.X, p.Y, p.Z, len(p.Changes)}
+		}
+	}
+	return p
+}
+
+// entry is a logged packet, also the JSON form of a line
+type entry struct {
+	Time      time.Time `json:"time"`
+	Conn      int       `json:"conn"`
+	Direction string    `json:"direction"`
+	Packet    string    `json:"packet"`
+	ID        uint8     `json:"id"`
+	Size      int       `json:"size"`
+	Fields    any       `json:"fields,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Faults    []string  `json:"faults,omitempty"`
+}
+
+// printer writes entries as text or JSON lines, one at a time
+type printer struct {
+	mu   sync.Mutex
+	w    io.Writer
+	json bool
+}
+
+func (p *printer) print(e *entry) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.json {
+		data, err := json.Marshal(e)
+		if err != nil {
+			data, _ = json.Marshal(entry{Time: e.Time, Conn: e.Conn, Direction: e.Direction,
+				Packet: e.Packet, ID: e.ID, Size: e.Size, Error: err.Error(), Faults: e.Faults})
+		}
+		p.w.Write(append(data, '\n'))
+		return
+	}
+
+	arrow := "client -> server"
+	if e.Direction == packet.ClientBoundDirection.String() {
+		arrow = "server -> client"
+	}
+	line := fmt.Sprintf("%s #%d %s %s (0x%02x, %d bytes)",
+		e.Time.Format("15:04:05.000000"), e.Conn, arrow, e.Packet, e.ID, e.Size)
+	if e.Fields != nil {
+		line += " " + strings.TrimPrefix(fmt.Sprintf("%+v", e.Fields), "&")
+	}
+	if e.Error != "" {
+		line += " error: " + e.Error
+	}
+	if len(e.Faults) > 0 {
+		line += " [" + strings.Join(e.Faults, ", ") + "]"
+	}
+	fmt.Fprintln(p.w, line)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+
+	"github.com/leterax/go-voxels/pkg/network"
+)
+
+// options holds the command line flags
+type options struct {
+	listen  string
+	addr    string
+	json    bool
+	only    string
+	hide    string
+	faults  []faultRule
+	logPath string
+}
+
+func main() {
+	// Parse command line flags
+	var opts options
+	flag.StringVar(&opts.listen, "listen", ":20001", "Address clients connect to")
+	flag.StringVar(&opts.addr, "server", "localhost:20000", "Server address, ws:// or wss:// for WebSocket")
+	flag.BoolVar(&opts.json, "json", false, "Log packets as JSON lines")
+	flag.StringVar(&opts.only, "only", "", "Comma-separated packet names to log, all if empty")
+	flag.StringVar(&opts.hide, "hide", "", "Comma-separated packet names not to log")
+	flag.StringVar(&opts.logPath, "out", "", "Write the packet log to this file instead of stdout")
+	flag.Var(faultFlag{faultDrop, &opts.faults}, "drop", "Drop packets, as Name[:probability] (repeatable)")
+	flag.Var(faultFlag{faultDelay, &opts.faults}, "delay", "Delay packets, as Name:duration[:probability] (repeatable)")
+	flag.Var(faultFlag{faultCorrupt, &opts.faults}, "corrupt", "Flip a random payload bit, as Name[:probability] (repeatable)")
+	flag.Parse()
+
+	if err := run(opts); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run accepts clients and relays each to its own server connection
+func run(opts options) error {
+	filter := &packetFilter{}
+	var err error
+	if filter.only, err = parsePacketList(opts.only); err != nil {
+		return fmt.Errorf("invalid -only: %w", err)
+	}
+	if filter.hide, err = parsePacketList(opts.hide); err != nil {
+		return fmt.Errorf("invalid -hide: %w", err)
+	}
+
+	out := os.Stdout
+	if opts.logPath != "" {
+		file, err := os.Create(opts.logPath)
+		if err != nil {
+			return fmt.Errorf("failed to create packet log: %w", err)
+		}
+		defer file.Close()
+		out = file
+	}
+	printer := &printer{w: out, json: opts.json}
+
+	listener, err := net.Listen("tcp", opts.listen)
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+	defer listener.Close()
+	log.Printf("Proxying %s to %s", listener.Addr(), opts.addr)
+	for _, rule := range opts.faults {
+		log.Printf("Fault: %s", rule)
+	}
+
+	for id := 1; ; id++ {
+		client, err := listener.Accept()
+		if err != nil {
+			return fmt.Errorf("failed to accept: %w", err)
+		}
+		go func() {
+			server, err := network.Dial(opts.addr)
+			if err != nil {
+				log.Printf("Connection #%d from %s: %v", id, client.RemoteAddr(), err)
+				client.Close()
+				return
+			}
+			log.Printf("Connection #%d from %s", id, client.RemoteAddr())
+			r := &relay{id: id, client: client, server: server, faults: opts.faults, filter: filter, printer: printer}
+			r.run()
+			log.Printf("Connection #%d closed", id)
+		}()
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/leterax/go-voxels/pkg/network/packet"
+)
+
+// relay forwards the packets of one proxied connection in both directions
+type relay struct {
+	id      int
+	client  net.Conn
+	server  net.Conn
+	faults  faults
+	filter  *packetFilter
+	printer *printer
+}
+
+// run relays until either side closes, then closes both
+func (r *relay) run() {
+	var wg sync.WaitGroup
+	var once sync.Once
+	closeBoth := func() {
+		once.Do(func() {
+			r.client.Close()
+			r.server.Close()
+		})
+	}
+
+	for _, direction := range []struct {
+		from, to net.Conn
+		registry *packet.Registry
+	}{
+		{r.client, r.server, packet.ServerBound},
+		{r.server, r.client, packet.ClientBound},
+	} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer closeBoth()
+			if err := r.pump(direction.from, direction.to, direction.registry); err != nil {
+				log.Printf("Connection #%d: %s stream ended: %v", r.id, direction.registry.Direction, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// pump logs and forwards the packets read from one side to the other
+func (r *relay) pump(from, to net.Conn, registry *packet.Registry) error {
+	s := newStream(from, registry)
+	for {
+		f, err := s.next()
+		if err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+
+		name := packetName(registry, f.id)
+		e := &entry{
+			Time:      time.Now(),
+			Conn:      r.id,
+			Direction: registry.Direction.String(),
+			Packet:    name,
+			ID:        f.id,
+			Size:      len(f.raw),
+		}
+		if f.packet != nil {
+			e.Fields = fields(f.packet)
+		}
+		if f.err != nil {
+			e.Error = f.err.Error()
+		}
+
+		forward := true
+		var delay time.Duration
+		for _, rule := range r.faults.pick(name) {
+			switch rule.action {
+			case faultDrop:
+				forward = false
+			case faultDelay:
+				delay += rule.delay
+			case faultCorrupt:
+				corrupt(f)
+			}
+			e.Faults = append(e.Faults, rule.action.String())
+		}
+		if r.filter.shows(name) {
+			r.printer.print(e)
+		}
+
+		if !forward {
+			continue
+		}
+		if delay > 0 {
+			time.Sleep(delay)
+		}
+		if _, err := to.Write(f.raw); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/leterax/go-voxels/pkg/network/packet"
+)
+
+// frame is a packet as it passed the proxy, with the bytes to forward
+type frame struct {
+	raw      []byte        // Exactly as read, including the length prefix of framed packets
+	id       uint8         // Packet ID
+	idOffset int           // Position of the ID in raw
+	packet   packet.Packet // Nil if the packet couldn't be decoded
+	err      error         // Why decoding failed
+}
+
+// payload returns the bytes following the packet ID
+func (f *frame) payload() []byte {
+	return f.raw[f.idOffset+1:]
+}
+
+// stream splits one direction of a connection into packets: the unframed Hello first,
+// then length-prefixed packets if the Hello announced framing, unframed ones otherwise
+type stream struct {
+	r         *bufio.Reader
+	registry  *packet.Registry
+	helloDone bool
+	framed    bool
+}
+
+func newStream(r io.Reader, registry *packet.Registry) *stream {
+	return &stream{r: bufio.NewReaderSize(r, 64<<10), registry: registry}
+}
+
+// next reads the next packet. Framed packets that fail to decode are still returned with
+// their error, since their length is known; anything else ends the stream.
+func (s *stream) next() (*frame, error) {
+	if !s.framed {
+		// Unframed packets end where decoding ends, so keep what the decoder consumes
+		var raw bytes.Buffer
+		p, err := packet.Read(io.TeeReader(s.r, &raw), s.registry)
+		if err != nil {
+			return nil, err
+		}
+		if hello, ok := p.(*packet.ServerHello); ok && !s.helloDone {
+			s.framed = hello.Flags&packet.CapabilityFramed != 0
+		}
+		if hello, ok := p.(*packet.ClientHello); ok && !s.helloDone {
+			s.framed = hello.Flags&packet.CapabilityFramed != 0
+		}
+		s.helloDone = true
+		return &frame{raw: raw.Bytes(), id: p.ID(), packet: p}, nil
+	}
+
+	var header [4]byte
+	if _, err := io.ReadFull(s.r, header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("failed to read packet header: %w", err)
+		}
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(header[:])
+	if length == 0 || length > packet.MaxFrameLength {
+		return nil, fmt.Errorf("invalid frame length %d", length)
+	}
+	raw := make([]byte, 4+length)
+	copy(raw, header[:])
+	if _, err := io.ReadFull(s.r, raw[4:]); err != nil {
+		return nil, fmt.Errorf("failed to read packet of %d bytes: %w", length, err)
+	}
+
+	f := &frame{raw: raw, id: raw[4], idOffset: 4}
+	if _, ok := s.registry.New(f.id); !ok {
+		f.err = fmt.Errorf("%w: %s 0x%02x", packet.ErrUnknownPacket, s.registry.Direction, f.id)
+		return f, nil
+	}
+	// Trailing fields of newer protocol versions are left unread, like the decoder skips them
+	f.packet, f.err = packet.Read(bytes.NewReader(raw[4:]), s.registry)
+	return f, nil
+}
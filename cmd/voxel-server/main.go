@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/leterax/go-voxels/pkg/network"
+	"github.com/leterax/go-voxels/pkg/server"
+	"github.com/leterax/go-voxels/pkg/voxel"
+)
+
+func main() {
+	// Parse command line flags
+	addr := flag.String("addr", ":20000", "Address to listen on")
+	wsAddr := flag.String("ws", "", "Address to accept WebSocket clients on (disabled if empty)")
+	seed := flag.Int64("seed", 0, "Terrain generator seed")
+	worldPath := flag.String("world", "", "World file to load on start and save on shutdown")
+	maxRenderDistance := flag.Int("maxrd", server.DefaultMaxRenderDistance, "Maximum render distance in chunks")
+	flag.Parse()
+
+	var world *voxel.World
+	if *worldPath != "" {
+		loaded, err := loadWorld(*worldPath)
+		if err != nil {
+			log.Fatalf("Failed to load world: %v", err)
+		}
+		world = loaded
+	}
+
+	srv := server.New(server.Config{
+		Generator:         server.NewTerrainGenerator(*seed),
+		World:             world,
+		MaxRenderDistance: *maxRenderDistance,
+	})
+
+	// Shut down cleanly on Ctrl+C
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-signals
+		log.Println("Shutting down")
+		srv.Close()
+	}()
+
+	if *wsAddr != "" {
+		go func() {
+			err := srv.ListenAndServeWebSocket(*wsAddr)
+			if err != nil && !errors.Is(err, server.ErrServerClosed) {
+				log.Printf("WebSocket server error: %v", err)
+				srv.Close()
+			}
+		}()
+	}
+
+	if err := srv.ListenAndServe(*addr); err != nil && !errors.Is(err, server.ErrServerClosed) {
+		log.Fatalf("Server error: %v", err)
+	}
+
+	if *worldPath != "" {
+		if err := saveWorld(*worldPath, srv.World()); err != nil {
+			log.Fatalf("Failed to save world: %v", err)
+		}
+		log.Printf("Saved world to %s", *worldPath)
+	}
+}
+
+// loadWorld reads a saved world, returning nil if the file doesn't exist yet
+func loadWorld(path string) (*voxel.World, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return voxel.ReadWorld(bufio.NewReader(file), network.ChunkSize)
+}
+
+// saveWorld writes the world to path, replacing the file only once the write succeeded
+func saveWorld(path string, world *voxel.World) error {
+	tmpPath := path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(file)
+	if _, err := world.WriteTo(writer); err != nil {
+		file.Close()
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	return nil
+}
@@ -251,6 +251,13 @@ func (vao *VertexArrayObject) SetVertexAttribPointer(index uint32, size int32, x
 	gl.EnableVertexAttribArray(index)
 }
 
+// SetVertexAttribIPointer sets up an integer vertex attribute pointer and enables the attribute.
+// Unlike SetVertexAttribPointer the values reach the shader unconverted, as int or uint inputs.
+func (vao *VertexArrayObject) SetVertexAttribIPointer(index uint32, size int32, xtype uint32, stride int32, offset int) {
+	gl.VertexAttribIPointer(index, size, xtype, stride, gl.PtrOffset(offset))
+	gl.EnableVertexAttribArray(index)
+}
+
 // NewIndirectBuffer creates a buffer for multi-draw indirect commands.
 // Returns a new buffer object configured for indirect drawing commands.
 func NewIndirectBuffer(maxCommands int, usage BufferUsage) *BufferObject {
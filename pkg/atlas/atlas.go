@@ -0,0 +1,324 @@
+// Package atlas builds block texture atlases from a directory of PNG tiles.
+//
+// Tiles are named after the block they texture, optionally with a face suffix:
+// "stone.png" textures every face, while "grass_top.png", "grass_side.png" and
+// "grass_bottom.png" texture individual faces. The resulting atlas is written as
+// one image per mip level plus a JSON index mapping each block face to a tile.
+package atlas
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"math"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/leterax/go-voxels/pkg/voxel"
+)
+
+// MaxTiles is the number of tiles addressable by the 8-bit texture field of a packed vertex
+const MaxTiles = 256
+
+// Layout selects how tiles are arranged in the output images
+type Layout int
+
+const (
+	LayoutGrid  Layout = iota // Tiles in a square-ish grid with padding, for a 2D texture
+	LayoutArray               // Tiles stacked vertically, one per layer, for a 2D array texture
+)
+
+// String returns the name of the layout as used in the JSON index
+func (l Layout) String() string {
+	if l == LayoutArray {
+		return "array"
+	}
+	return "grid"
+}
+
+// ParseLayout returns the layout with the given name
+func ParseLayout(name string) (Layout, error) {
+	switch name {
+	case "grid":
+		return LayoutGrid, nil
+	case "array":
+		return LayoutArray, nil
+	default:
+		return LayoutGrid, fmt.Errorf("unknown atlas layout %q", name)
+	}
+}
+
+// Face suffixes recognised in tile file names
+const (
+	FaceTop    = "top"
+	FaceSide   = "side"
+	FaceBottom = "bottom"
+)
+
+// Options configures how an atlas is built
+type Options struct {
+	Layout    Layout
+	Padding   int // Pixels of edge extrusion around each grid tile, rounded up to halve evenly at every mip level; ignored for arrays
+	MipLevels int // Number of mip levels to generate, 0 for a full chain down to 1x1 tiles
+}
+
+// Atlas is a packed set of block tiles with their mip chain
+type Atlas struct {
+	Layout   Layout
+	TileSize int
+	Padding  int
+	Columns  int
+	Rows     int
+
+	// Tile names ("grass_top", "stone", ...), indexed by texture ID
+	Tiles []string
+	// Texture IDs for each textured block
+	Blocks map[voxel.BlockType]voxel.FaceTextures
+	// Atlas image for each mip level, level 0 first
+	Levels []*image.NRGBA
+}
+
+// Build reads every PNG tile in dir and packs them into an atlas
+func Build(dir string, opts Options) (*Atlas, error) {
+	if opts.Padding < 0 {
+		return nil, errors.New("padding must not be negative")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tile directory: %w", err)
+	}
+
+	// Sort by name so texture IDs are stable between runs
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.EqualFold(filepath.Ext(entry.Name()), ".png") {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no PNG tiles in %s", dir)
+	}
+	if len(names) > MaxTiles {
+		return nil, fmt.Errorf("%d tiles exceed the limit of %d", len(names), MaxTiles)
+	}
+
+	atlas := &Atlas{
+		Layout: opts.Layout,
+		Blocks: make(map[voxel.BlockType]voxel.FaceTextures),
+	}
+	if opts.Layout == LayoutGrid {
+		atlas.Padding = opts.Padding
+	}
+
+	// faces[block][face] = texture ID, face "" being the whole-block tile
+	faces := make(map[voxel.BlockType]map[string]int)
+	var tiles []*image.NRGBA
+
+	for _, fileName := range names {
+		tileName := strings.TrimSuffix(fileName, filepath.Ext(fileName))
+		blockType, face, err := parseTileName(tileName)
+		if err != nil {
+			return nil, err
+		}
+
+		tile, err := loadTile(filepath.Join(dir, fileName))
+		if err != nil {
+			return nil, err
+		}
+		if atlas.TileSize == 0 {
+			atlas.TileSize = tile.Bounds().Dx()
+		}
+		if tile.Bounds().Dx() != atlas.TileSize || tile.Bounds().Dy() != atlas.TileSize {
+			return nil, fmt.Errorf("tile %s is %dx%d, expected %dx%d", fileName,
+				tile.Bounds().Dx(), tile.Bounds().Dy(), atlas.TileSize, atlas.TileSize)
+		}
+
+		if faces[blockType] == nil {
+			faces[blockType] = make(map[string]int)
+		}
+		faces[blockType][face] = len(tiles)
+		atlas.Tiles = append(atlas.Tiles, tileName)
+		tiles = append(tiles, tile)
+	}
+
+	if atlas.TileSize&(atlas.TileSize-1) != 0 {
+		return nil, fmt.Errorf("tile size %d is not a power of two", atlas.TileSize)
+	}
+
+	for blockType, blockFaces := range faces {
+		atlas.Blocks[blockType] = resolveFaces(blockFaces)
+	}
+
+	levels, err := atlas.mipLevels(opts.MipLevels)
+	if err != nil {
+		return nil, err
+	}
+	atlas.pack(tiles, levels)
+	return atlas, nil
+}
+
+// parseTileName splits a tile name into its block type and face suffix
+func parseTileName(name string) (voxel.BlockType, string, error) {
+	face := ""
+	base := name
+	for _, suffix := range []string{FaceTop, FaceSide, FaceBottom} {
+		if trimmed, ok := strings.CutSuffix(name, "_"+suffix); ok {
+			base, face = trimmed, suffix
+			break
+		}
+	}
+
+	blockType, ok := voxel.ParseBlockType(base)
+	if !ok {
+		return voxel.Air, "", fmt.Errorf("tile %q doesn't name a known block", name)
+	}
+	return blockType, face, nil
+}
+
+// loadTile decodes a PNG file into an NRGBA image with its origin at (0, 0)
+func loadTile(path string) (*image.NRGBA, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open tile: %w", err)
+	}
+	defer file.Close()
+
+	img, err := png.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+
+	bounds := img.Bounds()
+	tile := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(tile, tile.Bounds(), img, bounds.Min, draw.Src)
+	return tile, nil
+}
+
+// resolveFaces picks a tile for every face, falling back to the whole-block tile
+// and then to whichever other faces were provided
+func resolveFaces(blockFaces map[string]int) voxel.FaceTextures {
+	pick := func(order ...string) uint8 {
+		for _, face := range order {
+			if id, ok := blockFaces[face]; ok {
+				return uint8(id)
+			}
+		}
+		return 0
+	}
+	return voxel.FaceTextures{
+		Top:    pick(FaceTop, "", FaceSide, FaceBottom),
+		Side:   pick(FaceSide, "", FaceTop, FaceBottom),
+		Bottom: pick(FaceBottom, "", FaceSide, FaceTop),
+	}
+}
+
+// mipLevels returns how many mip levels to generate, the full chain if requested is 0, and rounds
+// the padding up to fit them. Grid padding must halve evenly at every level so tile UVs stay the
+// same across levels.
+func (a *Atlas) mipLevels(requested int) (int, error) {
+	levels := bits.Len(uint(a.TileSize))
+	if requested > levels {
+		return 0, fmt.Errorf("%d mip levels requested, %dx%d tiles have %d", requested, a.TileSize, a.TileSize, levels)
+	}
+	if requested > 0 {
+		levels = requested
+	}
+	if step := 1 << (levels - 1); a.Padding%step != 0 {
+		a.Padding += step - a.Padding%step
+	}
+	return levels, nil
+}
+
+// pack lays out the tiles and their mip chains into one image per level
+func (a *Atlas) pack(tiles []*image.NRGBA, levels int) {
+	if a.Layout == LayoutArray {
+		a.Columns, a.Rows = 1, len(tiles)
+	} else {
+		a.Columns = int(math.Ceil(math.Sqrt(float64(len(tiles)))))
+		a.Rows = (len(tiles) + a.Columns - 1) / a.Columns
+	}
+
+	for level := range levels {
+		tileSize := a.TileSize >> level
+		padding := a.Padding >> level
+		cell := tileSize + 2*padding
+
+		img := image.NewNRGBA(image.Rect(0, 0, a.Columns*cell, a.Rows*cell))
+		for i, tile := range tiles {
+			x := (i%a.Columns)*cell + padding
+			y := (i/a.Columns)*cell + padding
+			blitPadded(img, tile, x, y, padding)
+		}
+		a.Levels = append(a.Levels, img)
+
+		// Downsample each tile on its own so neighbouring tiles never bleed together
+		if level+1 < levels {
+			for i, tile := range tiles {
+				tiles[i] = downsample(tile)
+			}
+		}
+	}
+}
+
+// blitPadded draws tile at (x, y) and extrudes its edge pixels padding pixels outwards
+func blitPadded(dst, tile *image.NRGBA, x, y, padding int) {
+	size := tile.Bounds().Dx()
+	for ty := -padding; ty < size+padding; ty++ {
+		for tx := -padding; tx < size+padding; tx++ {
+			sx := min(max(tx, 0), size-1)
+			sy := min(max(ty, 0), size-1)
+			dst.SetNRGBA(x+tx, y+ty, tile.NRGBAAt(sx, sy))
+		}
+	}
+}
+
+// downsample halves a tile with a 2x2 box filter, weighting colors by alpha
+func downsample(src *image.NRGBA) *image.NRGBA {
+	size := max(src.Bounds().Dx()/2, 1)
+	dst := image.NewNRGBA(image.Rect(0, 0, size, size))
+
+	for y := range size {
+		for x := range size {
+			var r, g, b, a uint32
+			for _, offset := range [4][2]int{{0, 0}, {1, 0}, {0, 1}, {1, 1}} {
+				c := src.NRGBAAt(min(2*x+offset[0], src.Bounds().Dx()-1), min(2*y+offset[1], src.Bounds().Dy()-1))
+				r += uint32(c.R) * uint32(c.A)
+				g += uint32(c.G) * uint32(c.A)
+				b += uint32(c.B) * uint32(c.A)
+				a += uint32(c.A)
+			}
+
+			pixel := dst.PixOffset(x, y)
+			if a > 0 {
+				dst.Pix[pixel+0] = uint8(r / a)
+				dst.Pix[pixel+1] = uint8(g / a)
+				dst.Pix[pixel+2] = uint8(b / a)
+			}
+			dst.Pix[pixel+3] = uint8(a / 4)
+		}
+	}
+	return dst
+}
+
+// TileRect returns the normalized UV rectangle of a tile in a grid atlas,
+// or the full [0, 1] range for array layers
+func (a *Atlas) TileRect(id int) (u0, v0, u1, v1 float32) {
+	if a.Layout == LayoutArray {
+		return 0, 0, 1, 1
+	}
+
+	cell := a.TileSize + 2*a.Padding
+	width := float32(a.Columns * cell)
+	height := float32(a.Rows * cell)
+	x := float32((id%a.Columns)*cell + a.Padding)
+	y := float32((id/a.Columns)*cell + a.Padding)
+	return x / width, y / height, (x + float32(a.TileSize)) / width, (y + float32(a.TileSize)) / height
+}
@@ -0,0 +1,207 @@
+package atlas
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/leterax/go-voxels/pkg/voxel"
+)
+
+// tileColor is the color of a test tile pixel, unique to the tile and position
+func tileColor(tile, x, y int) color.NRGBA {
+	return color.NRGBA{R: uint8(x * 16), G: uint8(y * 16), B: uint8(tile * 40), A: 255}
+}
+
+// writeTiles writes a 16x16 tile of tileColor for each name into a new directory
+func writeTiles(t *testing.T, names ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for i, name := range names {
+		tile := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+		for y := range 16 {
+			for x := range 16 {
+				tile.SetNRGBA(x, y, tileColor(i, x, y))
+			}
+		}
+		if err := writePNG(filepath.Join(dir, name+".png"), tile); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestBuildPacksTiles(t *testing.T) {
+	// Written out of order, packed by name
+	dir := writeTiles(t, "stone", "grass_top", "dirt", "grass_side", "sand")
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not a tile"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	atlas, err := Build(dir, Options{Padding: 2, MipLevels: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantTiles := []string{"dirt", "grass_side", "grass_top", "sand", "stone"}
+	if !reflect.DeepEqual(atlas.Tiles, wantTiles) {
+		t.Errorf("tiles %v, want %v", atlas.Tiles, wantTiles)
+	}
+	wantBlocks := map[voxel.BlockType]voxel.FaceTextures{
+		voxel.Dirt:  {Top: 0, Side: 0, Bottom: 0},
+		voxel.Grass: {Top: 2, Side: 1, Bottom: 1},
+		voxel.Sand:  {Top: 3, Side: 3, Bottom: 3},
+		voxel.Stone: {Top: 4, Side: 4, Bottom: 4},
+	}
+	if !reflect.DeepEqual(atlas.Blocks, wantBlocks) {
+		t.Errorf("blocks %v, want %v", atlas.Blocks, wantBlocks)
+	}
+	if atlas.Columns != 3 || atlas.Rows != 2 {
+		t.Errorf("%dx%d grid, want 3x2", atlas.Columns, atlas.Rows)
+	}
+	img := atlas.Levels[0]
+	if size := img.Bounds().Size(); size != image.Pt(3*20, 2*20) {
+		t.Fatalf("atlas is %v, want 60x40", size)
+	}
+
+	// File order: stone, grass_top, dirt, grass_side, sand
+	fileIndex := map[string]int{"stone": 0, "grass_top": 1, "dirt": 2, "grass_side": 3, "sand": 4}
+	for id, name := range atlas.Tiles {
+		u0, v0, u1, v1 := atlas.TileRect(id)
+		x0, y0 := int(u0*60), int(v0*40)
+		if x1, y1 := int(u1*60), int(v1*40); x1-x0 != 16 || y1-y0 != 16 {
+			t.Errorf("tile %s spans %dx%d pixels", name, x1-x0, y1-y0)
+		}
+		// Every pixel of the cell is the tile pixel, or the nearest one in the padding
+		for y := -2; y < 18; y++ {
+			for x := -2; x < 18; x++ {
+				want := tileColor(fileIndex[name], min(max(x, 0), 15), min(max(y, 0), 15))
+				if got := img.NRGBAAt(x0+x, y0+y); got != want {
+					t.Fatalf("tile %s pixel (%d, %d) is %v, want %v", name, x, y, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestBuildRejectsBadTiles(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(t *testing.T) string
+	}{
+		{"no tiles", func(t *testing.T) string { return t.TempDir() }},
+		{"unknown block", func(t *testing.T) string { return writeTiles(t, "stone", "cheese") }},
+		{"mixed sizes", func(t *testing.T) string {
+			dir := writeTiles(t, "stone")
+			writePNG(filepath.Join(dir, "dirt.png"), image.NewNRGBA(image.Rect(0, 0, 8, 8)))
+			return dir
+		}},
+		{"not a power of two", func(t *testing.T) string {
+			dir := t.TempDir()
+			writePNG(filepath.Join(dir, "dirt.png"), image.NewNRGBA(image.Rect(0, 0, 12, 12)))
+			return dir
+		}},
+		{"not a PNG", func(t *testing.T) string {
+			dir := t.TempDir()
+			os.WriteFile(filepath.Join(dir, "dirt.png"), []byte("GIF89a"), 0o644)
+			return dir
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Build(tt.setup(t), Options{}); err == nil {
+				t.Error("built an atlas")
+			}
+		})
+	}
+	if _, err := Build(writeTiles(t, "stone"), Options{Padding: -1}); err == nil {
+		t.Error("built an atlas with negative padding")
+	}
+}
+
+func TestMipChain(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        Options
+		levels      int
+		padding     int
+		wantErr     bool
+		levelWidths []int
+	}{
+		{"full chain", Options{}, 5, 0, false, []int{32, 16, 8, 4, 2}},
+		{"full chain padded", Options{Padding: 4}, 5, 16, false, []int{96, 48, 24, 12, 6}},
+		{"padding fits", Options{Padding: 4, MipLevels: 3}, 3, 4, false, []int{48, 24, 12}},
+		{"padding rounded up", Options{Padding: 3, MipLevels: 2}, 2, 4, false, []int{48, 24}},
+		{"array ignores padding", Options{Layout: LayoutArray, Padding: 4}, 5, 0, false, []int{16, 8, 4, 2, 1}},
+		{"too many levels", Options{MipLevels: 6}, 0, 0, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atlas, err := Build(writeTiles(t, "dirt", "stone"), tt.opts)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("built more mip levels than the tiles have")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(atlas.Levels) != tt.levels || atlas.Padding != tt.padding {
+				t.Errorf("%d levels with %d px padding, want %d with %d", len(atlas.Levels), atlas.Padding, tt.levels, tt.padding)
+			}
+			for level, img := range atlas.Levels {
+				if level < len(tt.levelWidths) && img.Bounds().Dx() != tt.levelWidths[level] {
+					t.Errorf("level %d is %d px wide, want %d", level, img.Bounds().Dx(), tt.levelWidths[level])
+				}
+			}
+
+			// The tile rectangle covers the same pixels of the downsampled tile at every level
+			_, _, u1, v1 := atlas.TileRect(1)
+			last := atlas.Levels[len(atlas.Levels)-1]
+			size := last.Bounds().Size()
+			corner := last.NRGBAAt(int(u1*float32(size.X))-1, int(v1*float32(size.Y))-1)
+			tile := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+			for y := range 16 {
+				for x := range 16 {
+					tile.SetNRGBA(x, y, tileColor(1, x, y))
+				}
+			}
+			for range len(atlas.Levels) - 1 {
+				tile = downsample(tile)
+			}
+			if want := tile.NRGBAAt(tile.Bounds().Dx()-1, tile.Bounds().Dy()-1); corner != want {
+				t.Errorf("last level corner of tile 1 is %v, want %v", corner, want)
+			}
+		})
+	}
+}
+
+func TestDownsampleWeightsByAlpha(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	src.SetNRGBA(0, 0, color.NRGBA{R: 200, G: 100, B: 40, A: 255})
+	src.SetNRGBA(1, 1, color.NRGBA{R: 0, G: 0, B: 255, A: 0}) // Invisible, so its color must not bleed
+
+	got := downsample(src).NRGBAAt(0, 0)
+	if want := (color.NRGBA{R: 200, G: 100, B: 40, A: 63}); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+// decodePNG reads a PNG file written by the atlas
+func decodePNG(t *testing.T, path string) image.Image {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	img, err := png.Decode(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
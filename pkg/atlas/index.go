@@ -0,0 +1,169 @@
+package atlas
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+
+	"github.com/leterax/go-voxels/pkg/voxel"
+)
+
+// Index is the JSON description written next to the atlas images
+type Index struct {
+	Layout   string                `json:"layout"`
+	TileSize int                   `json:"tileSize"`
+	Padding  int                   `json:"padding"`
+	Width    int                   `json:"width"`
+	Height   int                   `json:"height"`
+	Images   []string              `json:"images"` // One image per mip level, level 0 first
+	Tiles    []TileEntry           `json:"tiles"`
+	Blocks   map[string]BlockEntry `json:"blocks"`
+}
+
+// TileEntry locates a single tile in the atlas
+type TileEntry struct {
+	ID    int     `json:"id"`    // Texture ID used in packed vertices
+	Name  string  `json:"name"`  // Tile file name without extension
+	Layer int     `json:"layer"` // Array layer, equal to ID for array layouts and 0 for grids
+	U0    float32 `json:"u0"`
+	V0    float32 `json:"v0"`
+	U1    float32 `json:"u1"`
+	V1    float32 `json:"v1"`
+}
+
+// BlockEntry holds the texture IDs of a block's faces
+type BlockEntry struct {
+	Top    int `json:"top"`
+	Side   int `json:"side"`
+	Bottom int `json:"bottom"`
+}
+
+// Index builds the JSON index for the atlas, naming images after name
+func (a *Atlas) Index(name string) *Index {
+	index := &Index{
+		Layout:   a.Layout.String(),
+		TileSize: a.TileSize,
+		Padding:  a.Padding,
+		Blocks:   make(map[string]BlockEntry, len(a.Blocks)),
+	}
+	if len(a.Levels) > 0 {
+		index.Width = a.Levels[0].Bounds().Dx()
+		index.Height = a.Levels[0].Bounds().Dy()
+	}
+
+	for level := range a.Levels {
+		index.Images = append(index.Images, imageName(name, level))
+	}
+
+	for id, tileName := range a.Tiles {
+		entry := TileEntry{ID: id, Name: tileName}
+		if a.Layout == LayoutArray {
+			entry.Layer = id
+		}
+		entry.U0, entry.V0, entry.U1, entry.V1 = a.TileRect(id)
+		index.Tiles = append(index.Tiles, entry)
+	}
+
+	for blockType, textures := range a.Blocks {
+		index.Blocks[blockType.String()] = BlockEntry{
+			Top:    int(textures.Top),
+			Side:   int(textures.Side),
+			Bottom: int(textures.Bottom),
+		}
+	}
+
+	return index
+}
+
+// imageName returns the file name of a mip level image
+func imageName(name string, level int) string {
+	if level == 0 {
+		return name + ".png"
+	}
+	return fmt.Sprintf("%s_mip%d.png", name, level)
+}
+
+// Write saves every mip level image and the JSON index (name.json) into dir
+func (a *Atlas) Write(dir, name string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	for level, img := range a.Levels {
+		if err := writePNG(filepath.Join(dir, imageName(name, level)), img); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.MarshalIndent(a.Index(name), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode atlas index: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name+".json"), data, 0o644); err != nil {
+		return fmt.Errorf("failed to write atlas index: %w", err)
+	}
+	return nil
+}
+
+// writePNG encodes an image to a PNG file
+func writePNG(path string, img *image.NRGBA) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer file.Close()
+
+	if err := png.Encode(file, img); err != nil {
+		return fmt.Errorf("failed to encode %s: %w", path, err)
+	}
+	return file.Close()
+}
+
+// LoadIndex reads an atlas index written by Atlas.Write
+func LoadIndex(path string) (*Index, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read atlas index: %w", err)
+	}
+
+	var index Index
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("failed to decode atlas index: %w", err)
+	}
+	return &index, nil
+}
+
+// FaceTextures converts the index's block entries into the mesher's texture table
+func (idx *Index) FaceTextures() (map[voxel.BlockType]voxel.FaceTextures, error) {
+	table := make(map[voxel.BlockType]voxel.FaceTextures, len(idx.Blocks))
+	for name, entry := range idx.Blocks {
+		blockType, ok := voxel.ParseBlockType(name)
+		if !ok {
+			return nil, fmt.Errorf("atlas index names unknown block %q", name)
+		}
+		for _, id := range []int{entry.Top, entry.Side, entry.Bottom} {
+			if id < 0 || id >= MaxTiles {
+				return nil, fmt.Errorf("block %q uses texture ID %d outside 0-%d", name, id, MaxTiles-1)
+			}
+		}
+		table[blockType] = voxel.FaceTextures{
+			Top:    uint8(entry.Top),
+			Side:   uint8(entry.Side),
+			Bottom: uint8(entry.Bottom),
+		}
+	}
+	return table, nil
+}
+
+// Apply makes the meshers use this index for the texture field of packed vertices
+func (idx *Index) Apply() error {
+	table, err := idx.FaceTextures()
+	if err != nil {
+		return err
+	}
+	voxel.SetFaceTextures(table)
+	return nil
+}
@@ -0,0 +1,98 @@
+package atlas
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/leterax/go-voxels/pkg/voxel"
+)
+
+func TestIndexRoundTrip(t *testing.T) {
+	atlas, err := Build(writeTiles(t, "grass_top", "grass_side", "dirt", "stone"), Options{Padding: 4, MipLevels: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(t.TempDir(), "out")
+	if err := atlas.Write(dir, "blocks"); err != nil {
+		t.Fatal(err)
+	}
+
+	index, err := LoadIndex(filepath.Join(dir, "blocks.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(index, atlas.Index("blocks")) {
+		t.Errorf("loaded %+v, want %+v", index, atlas.Index("blocks"))
+	}
+	if index.Layout != "grid" || index.TileSize != 16 || index.Padding != 4 || index.Width != 48 || index.Height != 48 {
+		t.Errorf("%s %dx%d atlas of %d px tiles padded by %d, want a 48x48 grid of 16 px tiles padded by 4",
+			index.Layout, index.Width, index.Height, index.TileSize, index.Padding)
+	}
+
+	wantImages := []string{"blocks.png", "blocks_mip1.png", "blocks_mip2.png"}
+	if !reflect.DeepEqual(index.Images, wantImages) {
+		t.Fatalf("images %v, want %v", index.Images, wantImages)
+	}
+	for level, name := range index.Images {
+		if width := decodePNG(t, filepath.Join(dir, name)).Bounds().Dx(); width != 48>>level {
+			t.Errorf("%s is %d px wide, want %d", name, width, 48>>level)
+		}
+	}
+
+	// Tiles are sorted by name: dirt, grass_side, grass_top, stone
+	if len(index.Tiles) != 4 {
+		t.Fatalf("%d tiles, want 4", len(index.Tiles))
+	}
+	if want := (TileEntry{ID: 3, Name: "stone", U0: 28.0 / 48, V0: 28.0 / 48, U1: 44.0 / 48, V1: 44.0 / 48}); index.Tiles[3] != want {
+		t.Errorf("got %+v, want %+v", index.Tiles[3], want)
+	}
+
+	table, err := index.FaceTextures()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(table, atlas.Blocks) {
+		t.Errorf("face textures %v, want %v", table, atlas.Blocks)
+	}
+	if table[voxel.Grass] != (voxel.FaceTextures{Top: 2, Side: 1, Bottom: 1}) {
+		t.Errorf("grass textured with %+v", table[voxel.Grass])
+	}
+}
+
+func TestIndexArrayLayers(t *testing.T) {
+	atlas, err := Build(writeTiles(t, "dirt", "stone"), Options{Layout: LayoutArray})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id, entry := range atlas.Index("blocks").Tiles {
+		if want := (TileEntry{ID: id, Name: atlas.Tiles[id], Layer: id, U1: 1, V1: 1}); entry != want {
+			t.Errorf("got %+v, want %+v", entry, want)
+		}
+	}
+}
+
+func TestFaceTexturesRejectsBadIndex(t *testing.T) {
+	tests := []struct {
+		name   string
+		blocks map[string]BlockEntry
+	}{
+		{"unknown block", map[string]BlockEntry{"cheese": {}}},
+		{"negative ID", map[string]BlockEntry{"stone": {Top: -1}}},
+		{"ID too large", map[string]BlockEntry{"stone": {Bottom: MaxTiles}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := (&Index{Blocks: tt.blocks}).FaceTextures(); err == nil {
+				t.Error("accepted the index")
+			}
+		})
+	}
+
+	path := filepath.Join(t.TempDir(), "atlas.json")
+	os.WriteFile(path, []byte("{"), 0o644)
+	if _, err := LoadIndex(path); err == nil {
+		t.Error("loaded a truncated index")
+	}
+}
@@ -0,0 +1,255 @@
+package chat
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/leterax/go-voxels/pkg/voxel"
+)
+
+// ParamKind tells how a parameter is parsed
+type ParamKind uint8
+
+const (
+	ParamInt    ParamKind = iota // Integer
+	ParamFloat                   // Decimal number
+	ParamCoords                  // Three coordinates, each absolute or relative to the player with ~
+	ParamBlock                   // Block name or ID, optionally followed by :props
+	ParamWord                    // Single word, one of Choices if there are any
+	ParamText                    // Rest of the line, must be the last parameter
+)
+
+// Param describes a command parameter
+type Param struct {
+	Name     string
+	Kind     ParamKind
+	Optional bool     // May be left out, only followed by other optional parameters
+	Choices  []string // Allowed words of a ParamWord, offered for completion
+	Min, Max float64  // Allowed range of numbers, unchecked if both are zero
+	// Complete returns completion candidates starting with prefix, replacing the defaults of the kind
+	Complete func(prefix string) []string
+}
+
+// width returns the number of words the parameter takes, 0 for the rest of the line
+func (p *Param) width() int {
+	switch p.Kind {
+	case ParamCoords:
+		return 3
+	case ParamText:
+		return 0
+	}
+	return 1
+}
+
+// usage returns the parameter as shown in usage strings
+func (p *Param) usage() string {
+	text := p.Name
+	switch {
+	case p.Kind == ParamCoords:
+		text += ": x y z"
+	case len(p.Choices) > 0:
+		text = strings.Join(p.Choices, "|")
+	case p.Kind == ParamText:
+		text += "..."
+	}
+	if p.Optional {
+		return "[" + text + "]"
+	}
+	return "<" + text + ">"
+}
+
+// complete returns the candidates for a word of the parameter
+func (p *Param) complete(prefix string) []string {
+	var options []string
+	switch {
+	case p.Complete != nil:
+		return p.Complete(prefix)
+	case len(p.Choices) > 0:
+		options = p.Choices
+	case p.Kind == ParamBlock:
+		options = voxel.BlockTypeNames()
+	case p.Kind == ParamCoords:
+		options = []string{"~"}
+	}
+	var candidates []string
+	for _, option := range options {
+		if strings.HasPrefix(option, prefix) {
+			candidates = append(candidates, option)
+		}
+	}
+	return candidates
+}
+
+// paramAt returns the parameter the word with index i of the arguments belongs to
+func paramAt(params []Param, i int) (*Param, bool) {
+	for j := range params {
+		width := params[j].width()
+		if width == 0 || i < width {
+			return &params[j], true
+		}
+		i -= width
+	}
+	return nil, false
+}
+
+// Coords is a position in the world, in blocks
+type Coords struct {
+	X, Y, Z float64
+}
+
+// Block returns the block containing the position
+func (c Coords) Block() (x, y, z int32) {
+	return int32(math.Floor(c.X)), int32(math.Floor(c.Y)), int32(math.Floor(c.Z))
+}
+
+// Call is a command invocation with its parsed arguments
+type Call struct {
+	Command *Command
+	Args    string // Everything typed after the command name
+
+	dispatcher *Dispatcher
+	values     map[string]any
+}
+
+// parse matches the words typed after the command name to its parameters
+func (d *Dispatcher) parse(cmd *Command, text string) (*Call, error) {
+	_, rest, _ := strings.Cut(text, " ")
+	call := &Call{Command: cmd, Args: strings.TrimSpace(rest), dispatcher: d, values: make(map[string]any)}
+	words := strings.Fields(rest)
+
+	for _, param := range cmd.Params {
+		if len(words) == 0 {
+			if !param.Optional {
+				return nil, fmt.Errorf("missing %s", param.Name)
+			}
+			break
+		}
+		if param.Kind == ParamText {
+			call.values[param.Name] = strings.Join(words, " ")
+			words = nil
+			break
+		}
+		if len(words) < param.width() {
+			return nil, fmt.Errorf("%s takes %d values", param.Name, param.width())
+		}
+
+		value, err := d.parseValue(&param, words[:param.width()])
+		if err != nil {
+			return nil, err
+		}
+		call.values[param.Name] = value
+		words = words[param.width():]
+	}
+	if len(words) > 0 {
+		return nil, fmt.Errorf("unexpected %q", strings.Join(words, " "))
+	}
+	return call, nil
+}
+
+// parseValue parses the words of a single parameter
+func (d *Dispatcher) parseValue(param *Param, words []string) (any, error) {
+	checkRange := func(value float64) error {
+		if (param.Min != 0 || param.Max != 0) && (value < param.Min || value > param.Max) {
+			return fmt.Errorf("%s must be between %g and %g", param.Name, param.Min, param.Max)
+		}
+		return nil
+	}
+
+	switch param.Kind {
+	case ParamInt:
+		value, err := strconv.Atoi(words[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a whole number, not %q", param.Name, words[0])
+		}
+		return value, checkRange(float64(value))
+	case ParamFloat:
+		value, err := strconv.ParseFloat(words[0], 64)
+		if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+			return nil, fmt.Errorf("%s must be a number, not %q", param.Name, words[0])
+		}
+		return value, checkRange(value)
+	case ParamCoords:
+		var origin Coords
+		if d.Position != nil {
+			origin = d.Position()
+		}
+		var coords [3]float64
+		for i, base := range [3]float64{origin.X, origin.Y, origin.Z} {
+			value, err := parseCoord(words[i], base)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", param.Name, err)
+			}
+			coords[i] = value
+		}
+		return Coords{X: coords[0], Y: coords[1], Z: coords[2]}, nil
+	case ParamBlock:
+		return voxel.ParseBlockState(words[0])
+	case ParamWord:
+		if len(param.Choices) > 0 && !slices.Contains(param.Choices, words[0]) {
+			return nil, fmt.Errorf("%s must be one of %s", param.Name, strings.Join(param.Choices, ", "))
+		}
+		return words[0], nil
+	}
+	return nil, fmt.Errorf("%s has an unknown kind %d", param.Name, param.Kind)
+}
+
+// parseCoord parses an absolute coordinate, or one relative to base if prefixed with ~
+func parseCoord(text string, base float64) (float64, error) {
+	rest, relative := strings.CutPrefix(text, "~")
+	if relative && rest == "" {
+		return base, nil
+	}
+	value, err := strconv.ParseFloat(rest, 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) || math.Abs(value) > 1<<30 {
+		return 0, fmt.Errorf("invalid coordinate %q", text)
+	}
+	if relative {
+		return base + value, nil
+	}
+	return value, nil
+}
+
+// Int returns the value of a ParamInt, 0 if it was left out
+func (c *Call) Int(name string) int {
+	value, _ := c.values[name].(int)
+	return value
+}
+
+// Float returns the value of a ParamFloat, 0 if it was left out
+func (c *Call) Float(name string) float64 {
+	value, _ := c.values[name].(float64)
+	return value
+}
+
+// Coords returns the value of a ParamCoords with relative coordinates resolved,
+// the origin if it was left out
+func (c *Call) Coords(name string) Coords {
+	value, _ := c.values[name].(Coords)
+	return value
+}
+
+// Block returns the value of a ParamBlock, air if it was left out
+func (c *Call) Block(name string) voxel.BlockState {
+	value, _ := c.values[name].(voxel.BlockState)
+	return value
+}
+
+// String returns the value of a ParamWord or ParamText, empty if it was left out
+func (c *Call) String(name string) string {
+	value, _ := c.values[name].(string)
+	return value
+}
+
+// Has reports whether an optional parameter was given
+func (c *Call) Has(name string) bool {
+	_, ok := c.values[name]
+	return ok
+}
+
+// Reply sends a message to the local player
+func (c *Call) Reply(format string, args ...any) {
+	c.dispatcher.reply(fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,71 @@
+package chat
+
+import (
+	"testing"
+)
+
+func TestParseCoord(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    float64
+		wantErr bool
+	}{
+		{text: "5", want: 5},
+		{text: "-7.25", want: -7.25},
+		{text: "~", want: 2},
+		{text: "~3", want: 5},
+		{text: "~-1.5", want: 0.5},
+		{text: "~+1", want: 3},
+		{text: "1073741824", want: 1 << 30},
+		{text: "1073741825", wantErr: true},
+		{text: "~1e31", wantErr: true},
+		{text: "", wantErr: true},
+		{text: "~~", wantErr: true},
+		{text: "1~", wantErr: true},
+		{text: "x", wantErr: true},
+		{text: "NaN", wantErr: true},
+		{text: "~Inf", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got, err := parseCoord(tt.text, 2)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parsed as %g", got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("got %g, %v, want %g", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParamAt(t *testing.T) {
+	params := []Param{
+		{Name: "count", Kind: ParamInt},
+		{Name: "position", Kind: ParamCoords},
+		{Name: "mode", Kind: ParamWord, Optional: true},
+		{Name: "message", Kind: ParamText, Optional: true},
+	}
+	want := []string{"count", "position", "position", "position", "mode", "message", "message"}
+	for i, name := range want {
+		if param, ok := paramAt(params, i); !ok || param.Name != name {
+			t.Errorf("word %d belongs to %v, want %s", i, param, name)
+		}
+	}
+	if param, ok := paramAt(params, 100); !ok || param.Name != "message" {
+		t.Errorf("word 100 belongs to %v, want the rest of the line", param)
+	}
+	if param, ok := paramAt(params[:3], 5); ok {
+		t.Errorf("word 5 belongs to %s, want none after the last parameter", param.Name)
+	}
+}
+
+func TestCoordsBlock(t *testing.T) {
+	x, y, z := Coords{X: -0.5, Y: 1.9, Z: -16}.Block()
+	if x != -1 || y != 1 || z != -16 {
+		t.Errorf("got (%d, %d, %d), want (-1, 1, -16)", x, y, z)
+	}
+}
@@ -0,0 +1,155 @@
+package chat
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/leterax/go-voxels/pkg/network"
+)
+
+// DefaultMaxFill is the most blocks /fill places unless told otherwise
+const DefaultMaxFill = 32 * 32 * 32
+
+// MetadataSender changes and resends the client metadata, like network.Client
+type MetadataSender interface {
+	SetRenderDistance(distance uint8)
+	SendClientMetadata() error
+}
+
+// BulkEditor sends block updates, like network.Client
+type BulkEditor interface {
+	SendBlockBulkEdit(updates []network.BlockUpdate) error
+}
+
+// Teleport returns /tp, moving the local player with teleport.
+// Teleport must update the player's own position, which the server only learns from the client.
+func Teleport(teleport func(to Coords) error) *Command {
+	return &Command{
+		Name:    "tp",
+		Aliases: []string{"teleport"},
+		Help:    "Move to a position",
+		Params:  []Param{{Name: "position", Kind: ParamCoords}},
+		Run: func(call *Call) error {
+			to := call.Coords("position")
+			if err := teleport(to); err != nil {
+				return err
+			}
+			call.Reply("Teleported to %.1f %.1f %.1f", to.X, to.Y, to.Z)
+			return nil
+		},
+	}
+}
+
+// RenderDistance returns /rd, changing the render distance and telling the server
+func RenderDistance(client MetadataSender) *Command {
+	return &Command{
+		Name:    "rd",
+		Aliases: []string{"renderdistance"},
+		Help:    "Change the render distance in chunks",
+		Params:  []Param{{Name: "chunks", Kind: ParamInt, Min: 0, Max: 255}},
+		Run: func(call *Call) error {
+			distance := call.Int("chunks")
+			client.SetRenderDistance(uint8(distance))
+			if err := client.SendClientMetadata(); err != nil {
+				return err
+			}
+			call.Reply("Render distance set to %d chunks", distance)
+			return nil
+		},
+	}
+}
+
+// Fill returns /fill, setting every block of a box in a single bulk edit of at most maxBlocks
+// blocks, DefaultMaxFill if zero. Block properties can't be sent in bulk edits and are refused.
+func Fill(editor BulkEditor, maxBlocks int) *Command {
+	if maxBlocks <= 0 {
+		maxBlocks = DefaultMaxFill
+	}
+	return &Command{
+		Name: "fill",
+		Help: "Set every block of a box",
+		Params: []Param{
+			{Name: "from", Kind: ParamCoords},
+			{Name: "to", Kind: ParamCoords},
+			{Name: "block", Kind: ParamBlock},
+		},
+		Run: func(call *Call) error {
+			state := call.Block("block")
+			if state.Props() != 0 {
+				return fmt.Errorf("%w: /fill places blocks without properties", ErrUsage)
+			}
+			x1, y1, z1 := call.Coords("from").Block()
+			x2, y2, z2 := call.Coords("to").Block()
+			x1, x2 = min(x1, x2), max(x1, x2)
+			y1, y2 = min(y1, y2), max(y1, y2)
+			z1, z2 = min(z1, z2), max(z1, z2)
+
+			volume := (int64(x2) - int64(x1) + 1) * (int64(y2) - int64(y1) + 1) * (int64(z2) - int64(z1) + 1)
+			if volume > int64(maxBlocks) {
+				return fmt.Errorf("%w: box of %d blocks exceeds the limit of %d", ErrUsage, volume, maxBlocks)
+			}
+
+			updates := make([]network.BlockUpdate, 0, volume)
+			for x := x1; x <= x2; x++ {
+				for y := y1; y <= y2; y++ {
+					for z := z1; z <= z2; z++ {
+						updates = append(updates, network.BlockUpdate{BlockType: state.Type(), X: x, Y: y, Z: z})
+					}
+				}
+			}
+			if err := editor.SendBlockBulkEdit(updates); err != nil {
+				return err
+			}
+			call.Reply("Filled %d blocks with %s", len(updates), state.Type())
+			return nil
+		},
+	}
+}
+
+// Seed returns /seed, showing the terrain seed. Only register it on clients that know the seed,
+// the protocol doesn't carry it and /seed is sent to the server otherwise.
+func Seed(seed int64) *Command {
+	return &Command{
+		Name: "seed",
+		Help: "Show the terrain seed",
+		Run: func(call *Call) error {
+			call.Reply("Seed: %d", seed)
+			return nil
+		},
+	}
+}
+
+// Who returns /who, listing the names of the players returned by players
+func Who(players func() []string) *Command {
+	return &Command{
+		Name:    "who",
+		Aliases: []string{"list"},
+		Help:    "List the players online",
+		Run: func(call *Call) error {
+			names := slices.Clone(players())
+			slices.Sort(names)
+			call.Reply("%d online: %s", len(names), strings.Join(names, ", "))
+			return nil
+		},
+	}
+}
+
+// PlayerNames returns the names of the entities in store and self, the local player,
+// for use with Who. Entities without a name are shown by ID.
+func PlayerNames(store *network.EntityStore, self func() string) func() []string {
+	return func() []string {
+		var names []string
+		if self != nil {
+			names = append(names, self())
+		}
+		for _, entity := range store.Current() {
+			name := entity.Name
+			if name == "" {
+				name = fmt.Sprintf("#%d", entity.EntityID)
+			}
+			names = append(names, name)
+		}
+		return names
+	}
+}
@@ -0,0 +1,216 @@
+// Package chat turns text typed by the local player into slash commands handled on the client,
+// or chat sent to the server. Commands the client doesn't know are passed through to the server.
+package chat
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ErrUsage is returned when a command's arguments don't match its parameters
+var ErrUsage = errors.New("invalid arguments")
+
+// Sender sends chat to the server, like network.Client and network.ReconnectingClient
+type Sender interface {
+	SendChat(message string) error
+}
+
+// Command is a slash command handled on the client
+type Command struct {
+	Name    string   // Typed after the slash
+	Aliases []string // Other names for the command
+	Help    string   // One line shown by /help
+	Params  []Param
+	Run     func(call *Call) error
+}
+
+// Usage returns the command with its parameters, like "/tp <x> <y> <z>"
+func (c *Command) Usage() string {
+	var b strings.Builder
+	b.WriteString("/" + c.Name)
+	for _, param := range c.Params {
+		b.WriteString(" " + param.usage())
+	}
+	return b.String()
+}
+
+// Dispatcher runs slash commands registered on the client and sends everything else to the server
+type Dispatcher struct {
+	// Position returns where the local player is, for coordinates relative with ~.
+	// Relative coordinates are taken from the origin if it is nil.
+	Position func() Coords
+	// OnOutput receives messages for the local player, like command results
+	OnOutput func(message string)
+
+	sender   Sender
+	commands map[string]*Command // By name and alias
+}
+
+// NewDispatcher creates a dispatcher sending chat through sender, with /help registered
+func NewDispatcher(sender Sender) *Dispatcher {
+	d := &Dispatcher{sender: sender, commands: make(map[string]*Command)}
+	d.MustRegister(d.helpCommand())
+	return d
+}
+
+// Register adds a command. It fails if the command or one of its aliases is already registered,
+// or if its parameters are declared in an order that can't be parsed.
+func (d *Dispatcher) Register(cmd *Command) error {
+	if cmd.Name == "" || cmd.Run == nil {
+		return fmt.Errorf("command needs a name and a Run function")
+	}
+	for i, param := range cmd.Params {
+		last := i == len(cmd.Params)-1
+		if param.Kind == ParamText && !last {
+			return fmt.Errorf("/%s: text parameter %q must be the last", cmd.Name, param.Name)
+		}
+		if i > 0 && cmd.Params[i-1].Optional && !param.Optional {
+			return fmt.Errorf("/%s: required parameter %q follows an optional one", cmd.Name, param.Name)
+		}
+	}
+
+	names := append([]string{cmd.Name}, cmd.Aliases...)
+	for _, name := range names {
+		if _, exists := d.commands[strings.ToLower(name)]; exists {
+			return fmt.Errorf("command /%s is already registered", name)
+		}
+	}
+	for _, name := range names {
+		d.commands[strings.ToLower(name)] = cmd
+	}
+	return nil
+}
+
+// MustRegister is Register panicking on errors, for commands built into the program
+func (d *Dispatcher) MustRegister(cmd *Command) {
+	if err := d.Register(cmd); err != nil {
+		panic(err)
+	}
+}
+
+// Commands returns the registered commands sorted by name
+func (d *Dispatcher) Commands() []*Command {
+	var commands []*Command
+	for name, cmd := range d.commands {
+		if name == strings.ToLower(cmd.Name) {
+			commands = append(commands, cmd)
+		}
+	}
+	slices.SortFunc(commands, func(a, b *Command) int { return strings.Compare(a.Name, b.Name) })
+	return commands
+}
+
+// lookup returns the command registered under a name, ignoring case
+func (d *Dispatcher) lookup(name string) *Command {
+	return d.commands[strings.ToLower(name)]
+}
+
+// Handle processes a line typed by the local player. Registered commands run on the client,
+// anything else, unknown slash commands included, is sent to the server as chat.
+// Arguments not matching the command's parameters fail with ErrUsage.
+func (d *Dispatcher) Handle(line string) error {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return nil
+	}
+	text, isCommand := strings.CutPrefix(line, "/")
+	if !isCommand {
+		return d.sender.SendChat(line)
+	}
+
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return d.sender.SendChat(line)
+	}
+	cmd := d.lookup(fields[0])
+	if cmd == nil {
+		return d.sender.SendChat(line)
+	}
+
+	call, err := d.parse(cmd, text)
+	if err != nil {
+		return fmt.Errorf("%w: %v, usage: %s", ErrUsage, err, cmd.Usage())
+	}
+	return cmd.Run(call)
+}
+
+// Complete returns what the last word of a partially typed line can be completed to,
+// for tab completion. Each candidate replaces the last word, which is empty after a space.
+func (d *Dispatcher) Complete(line string) []string {
+	text, isCommand := strings.CutPrefix(line, "/")
+	if !isCommand {
+		return nil
+	}
+	fields := strings.Fields(text)
+	if len(fields) == 0 || len(fields) == 1 && !strings.HasSuffix(text, " ") {
+		// Completing the command name
+		prefix := ""
+		if len(fields) == 1 {
+			prefix = strings.ToLower(fields[0])
+		}
+		var candidates []string
+		for _, cmd := range d.Commands() {
+			if strings.HasPrefix(strings.ToLower(cmd.Name), prefix) {
+				candidates = append(candidates, "/"+cmd.Name)
+			}
+		}
+		return candidates
+	}
+
+	cmd := d.lookup(fields[0])
+	if cmd == nil {
+		return nil
+	}
+	args := fields[1:]
+	current := ""
+	if !strings.HasSuffix(text, " ") {
+		current = args[len(args)-1]
+		args = args[:len(args)-1]
+	}
+	param, ok := paramAt(cmd.Params, len(args))
+	if !ok {
+		return nil
+	}
+	return param.complete(current)
+}
+
+// reply sends a message to the local player
+func (d *Dispatcher) reply(message string) {
+	if d.OnOutput != nil {
+		d.OnOutput(message)
+	}
+}
+
+// helpCommand lists the commands, or describes one
+func (d *Dispatcher) helpCommand() *Command {
+	return &Command{
+		Name: "help",
+		Help: "List the commands, or show how to use one",
+		Params: []Param{{Name: "command", Kind: ParamWord, Optional: true, Complete: func(prefix string) []string {
+			var names []string
+			for _, cmd := range d.Commands() {
+				if strings.HasPrefix(cmd.Name, prefix) {
+					names = append(names, cmd.Name)
+				}
+			}
+			return names
+		}}},
+		Run: func(call *Call) error {
+			if name := call.String("command"); name != "" {
+				cmd := d.lookup(strings.TrimPrefix(name, "/"))
+				if cmd == nil {
+					call.Reply("Unknown command /%s, it is sent to the server", name)
+					return nil
+				}
+				call.Reply("%s: %s", cmd.Usage(), cmd.Help)
+				return nil
+			}
+			for _, cmd := range d.Commands() {
+				call.Reply("%s: %s", cmd.Usage(), cmd.Help)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,221 @@
+package chat
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// chatLog records the chat sent to the server
+type chatLog struct {
+	sent []string
+}
+
+func (l *chatLog) SendChat(message string) error {
+	l.sent = append(l.sent, message)
+	return nil
+}
+
+// testDispatcher returns a dispatcher with the player at (10.5, 64, -3.25) and commands
+// recording each run into ran
+func testDispatcher(t *testing.T, ran *[]string) (*Dispatcher, *chatLog) {
+	t.Helper()
+	log := &chatLog{}
+	d := NewDispatcher(log)
+	d.Position = func() Coords { return Coords{X: 10.5, Y: 64, Z: -3.25} }
+	d.MustRegister(Teleport(func(to Coords) error {
+		*ran = append(*ran, fmt.Sprintf("tp %g %g %g", to.X, to.Y, to.Z))
+		return nil
+	}))
+	d.MustRegister(&Command{
+		Name:   "say",
+		Params: []Param{{Name: "message", Kind: ParamText}},
+		Run: func(call *Call) error {
+			*ran = append(*ran, "say "+call.String("message"))
+			return nil
+		},
+	})
+	d.MustRegister(&Command{
+		Name: "give",
+		Params: []Param{
+			{Name: "block", Kind: ParamBlock},
+			{Name: "count", Kind: ParamInt, Optional: true, Min: 1, Max: 64},
+		},
+		Run: func(call *Call) error {
+			*ran = append(*ran, fmt.Sprintf("give %v %d %t", call.Block("block").Type(), call.Int("count"), call.Has("count")))
+			return nil
+		},
+	})
+	d.MustRegister(&Command{
+		Name:   "mode",
+		Params: []Param{{Name: "mode", Kind: ParamWord, Choices: []string{"walk", "fly"}}},
+		Run: func(call *Call) error {
+			*ran = append(*ran, "mode "+call.String("mode"))
+			return nil
+		},
+	})
+	return d, log
+}
+
+func TestHandle(t *testing.T) {
+	tests := []struct {
+		line    string
+		sent    []string // Chat sent to the server
+		ran     []string // Commands run on the client
+		wantErr error
+	}{
+		{line: "hello there", sent: []string{"hello there"}},
+		{line: "   "},
+		{line: "/", sent: []string{"/"}},
+		{line: "/weather rain", sent: []string{"/weather rain"}},
+		{line: "  /tp 1 2 3  ", ran: []string{"tp 1 2 3"}},
+		{line: "/TELEPORT -1.5 0 7", ran: []string{"tp -1.5 0 7"}},
+		{line: "/tp ~ ~1 ~-0.5", ran: []string{"tp 10.5 65 -3.75"}},
+		{line: "/tp ~ 70 ~", ran: []string{"tp 10.5 70 -3.25"}},
+		{line: "/tp 1 2", wantErr: ErrUsage},
+		{line: "/tp 1 2 3 4", wantErr: ErrUsage},
+		{line: "/tp ~x 2 3", wantErr: ErrUsage},
+		{line: "/tp", wantErr: ErrUsage},
+		{line: "/say hello   there ", ran: []string{"say hello there"}},
+		{line: "/say", wantErr: ErrUsage},
+		{line: "/give stone", ran: []string{"give stone 0 false"}},
+		{line: "/give stone 64", ran: []string{"give stone 64 true"}},
+		{line: "/give stone 65", wantErr: ErrUsage},
+		{line: "/give stone 1.5", wantErr: ErrUsage},
+		{line: "/give cheese", wantErr: ErrUsage},
+		{line: "/mode fly", ran: []string{"mode fly"}},
+		{line: "/mode swim", wantErr: ErrUsage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			var ran []string
+			d, log := testDispatcher(t, &ran)
+			err := d.Handle(tt.line)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("got %v, want %v", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(log.sent, tt.sent) {
+				t.Errorf("sent %q, want %q", log.sent, tt.sent)
+			}
+			if !slices.Equal(ran, tt.ran) {
+				t.Errorf("ran %q, want %q", ran, tt.ran)
+			}
+		})
+	}
+}
+
+func TestHandleWithoutPosition(t *testing.T) {
+	var ran []string
+	d, _ := testDispatcher(t, &ran)
+	d.Position = nil
+	if err := d.Handle("/tp ~1 ~ ~-2"); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"tp 1 0 -2"}; !slices.Equal(ran, want) {
+		t.Errorf("ran %q, want %q relative to the origin", ran, want)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	run := func(*Call) error { return nil }
+	tests := []struct {
+		name string
+		cmd  *Command
+	}{
+		{"no name", &Command{Run: run}},
+		{"no Run", &Command{Name: "x"}},
+		{"taken name", &Command{Name: "TP", Run: run}},
+		{"taken alias", &Command{Name: "move", Aliases: []string{"teleport"}, Run: run}},
+		{"text not last", &Command{Name: "x", Run: run, Params: []Param{
+			{Name: "a", Kind: ParamText},
+			{Name: "b", Kind: ParamInt},
+		}}},
+		{"required after optional", &Command{Name: "x", Run: run, Params: []Param{
+			{Name: "a", Kind: ParamInt, Optional: true},
+			{Name: "b", Kind: ParamInt},
+		}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ran []string
+			d, _ := testDispatcher(t, &ran)
+			if err := d.Register(tt.cmd); err == nil {
+				t.Error("registered the command")
+			}
+		})
+	}
+
+	var ran []string
+	d, _ := testDispatcher(t, &ran)
+	var names []string
+	for _, cmd := range d.Commands() {
+		names = append(names, cmd.Name)
+	}
+	if want := []string{"give", "help", "mode", "say", "tp"}; !slices.Equal(names, want) {
+		t.Errorf("commands %q, want %q once each", names, want)
+	}
+}
+
+func TestComplete(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"hello", nil},
+		{"/", []string{"/give", "/help", "/mode", "/say", "/tp"}},
+		{"/T", []string{"/tp"}},
+		{"/x", nil},
+		{"/tp ", []string{"~"}},
+		{"/tp 1 ~", []string{"~"}},
+		{"/tp 1 2 3 ", nil},
+		{"/mode ", []string{"walk", "fly"}},
+		{"/mode f", []string{"fly"}},
+		{"/mode fly ", nil},
+		{"/give stone ", nil},
+		{"/help t", []string{"tp"}},
+		{"/say a b ", nil},
+		{"/weather ", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			var ran []string
+			d, _ := testDispatcher(t, &ran)
+			if got := d.Complete(tt.line); !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	var ran []string
+	d, _ := testDispatcher(t, &ran)
+	if got := d.Complete("/give sto"); !slices.Contains(got, "stone") || slices.Contains(got, "dirt") {
+		t.Errorf("got %q, want the blocks starting with sto", got)
+	}
+}
+
+func TestHelp(t *testing.T) {
+	var ran []string
+	d, _ := testDispatcher(t, &ran)
+	var output []string
+	d.OnOutput = func(message string) { output = append(output, message) }
+
+	if err := d.Handle("/help /give"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Handle("/help weather"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"/give <block> [count]: ",
+		"Unknown command /weather, it is sent to the server",
+	}
+	if !reflect.DeepEqual(output, want) {
+		t.Errorf("got %q, want %q", output, want)
+	}
+}
@@ -0,0 +1,188 @@
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/leterax/go-voxels/pkg/network/packet"
+)
+
+// Capture file format:
+//
+//	magic "VXCP" + version(U8)
+//	[offset(I64 nanoseconds since the start) + length(U32) + packet(U8[length])] ...
+//
+// Every packet is stored with its ID, exactly as it would be sent by the server.
+const (
+	captureMagic   = "VXCP"
+	captureVersion = 1
+	maxCaptured    = 1 << 24 // Sanity limit on a single recorded packet
+)
+
+// ErrInvalidCapture is returned when a capture file is malformed
+var ErrInvalidCapture = errors.New("invalid capture data")
+
+// Recorder writes inbound packets with timestamps to a capture file
+type Recorder struct {
+	mu    sync.Mutex
+	w     *bufio.Writer
+	start time.Time
+	buf   []byte
+}
+
+// NewRecorder writes the capture header to w and starts the clock
+func NewRecorder(w io.Writer) (*Recorder, error) {
+	r := &Recorder{w: bufio.NewWriter(w), start: time.Now()}
+	if _, err := r.w.WriteString(captureMagic); err != nil {
+		return nil, fmt.Errorf("failed to write capture header: %w", err)
+	}
+	if err := r.w.WriteByte(captureVersion); err != nil {
+		return nil, fmt.Errorf("failed to write capture header: %w", err)
+	}
+	return r, nil
+}
+
+// Record appends a packet, stamped with the time since the recorder was created
+func (r *Recorder) Record(p packet.Packet) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.buf = append(r.buf[:0], p.ID())
+	r.buf = p.AppendPayload(r.buf)
+
+	var header [12]byte
+	binary.BigEndian.PutUint64(header[0:], uint64(time.Since(r.start)))
+	binary.BigEndian.PutUint32(header[8:], uint32(len(r.buf)))
+	if _, err := r.w.Write(header[:]); err != nil {
+		return fmt.Errorf("failed to write capture record: %w", err)
+	}
+	if _, err := r.w.Write(r.buf); err != nil {
+		return fmt.Errorf("failed to write capture record: %w", err)
+	}
+	return nil
+}
+
+// Flush writes buffered records to the underlying writer
+func (r *Recorder) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.w.Flush()
+}
+
+// CaptureRecord is a single packet read back from a capture
+type CaptureRecord struct {
+	Offset time.Duration // Time since the start of the recording
+	Packet packet.Packet
+}
+
+// CaptureReader reads records from a capture file
+type CaptureReader struct {
+	r   *bufio.Reader
+	buf []byte
+}
+
+// NewCaptureReader checks the capture header and returns a reader for its records
+func NewCaptureReader(r io.Reader) (*CaptureReader, error) {
+	br := bufio.NewReader(r)
+	header := make([]byte, len(captureMagic)+1)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, fmt.Errorf("failed to read capture header: %w", err)
+	}
+	if string(header[:len(captureMagic)]) != captureMagic {
+		return nil, fmt.Errorf("%w: bad magic", ErrInvalidCapture)
+	}
+	if header[len(captureMagic)] != captureVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidCapture, header[len(captureMagic)])
+	}
+	return &CaptureReader{r: br}, nil
+}
+
+// Next returns the next record, or io.EOF at the end of the capture
+func (cr *CaptureReader) Next() (CaptureRecord, error) {
+	var header [12]byte
+	if _, err := io.ReadFull(cr.r, header[:]); err != nil {
+		if err == io.EOF {
+			return CaptureRecord{}, io.EOF
+		}
+		return CaptureRecord{}, fmt.Errorf("failed to read capture record: %w", err)
+	}
+	offset := time.Duration(binary.BigEndian.Uint64(header[0:]))
+	length := binary.BigEndian.Uint32(header[8:])
+	if length == 0 || length > maxCaptured {
+		return CaptureRecord{}, fmt.Errorf("%w: record of %d bytes", ErrInvalidCapture, length)
+	}
+
+	if cap(cr.buf) < int(length) {
+		cr.buf = make([]byte, length)
+	}
+	data := cr.buf[:length]
+	if _, err := io.ReadFull(cr.r, data); err != nil {
+		return CaptureRecord{}, fmt.Errorf("failed to read capture record: %w", err)
+	}
+
+	p, err := packet.Read(bytes.NewReader(data), packet.ClientBound)
+	if err != nil {
+		return CaptureRecord{}, fmt.Errorf("%w: %w", ErrInvalidCapture, err)
+	}
+	return CaptureRecord{Offset: offset, Packet: p}, nil
+}
+
+// SetRecorder makes the client record every inbound packet. It must be called before Run.
+func (c *Client) SetRecorder(recorder *Recorder) {
+	c.recorder = recorder
+}
+
+// NewReplayClient creates a client without a connection whose Run feeds the packets of a capture
+// to the callbacks and subscriptions, exactly as they were received.
+// Speed scales the original timing: 1 replays in real time, 2 twice as fast and 0 without delays.
+// Run returns ErrConnectionClosed at the end of the capture. Sent packets are discarded.
+func NewReplayClient(capture io.Reader, speed float64) (*Client, error) {
+	reader, err := NewCaptureReader(capture)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := newClient(nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	c.next = replaySource(c, reader, speed)
+	return c, nil
+}
+
+// replaySource returns a packet source that waits for each record's offset before returning it
+func replaySource(c *Client, reader *CaptureReader, speed float64) func(ctx context.Context) (packet.Packet, error) {
+	var start time.Time
+	return func(ctx context.Context) (packet.Packet, error) {
+		record, err := reader.Next()
+		if err != nil {
+			return nil, err
+		}
+		if start.IsZero() {
+			start = time.Now()
+		}
+
+		if speed > 0 {
+			due := start.Add(time.Duration(float64(record.Offset) / speed))
+			if wait := time.Until(due); wait > 0 {
+				timer := time.NewTimer(wait)
+				defer timer.Stop()
+				select {
+				case <-timer.C:
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				case <-c.done:
+					return nil, ErrClientClosed
+				}
+			}
+		}
+		return record.Packet, nil
+	}
+}
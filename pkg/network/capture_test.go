@@ -0,0 +1,198 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/leterax/go-voxels/pkg/network/packet"
+	"github.com/leterax/go-voxels/pkg/voxel"
+)
+
+// capturedPackets are sent by the server in the capture tests
+func capturedPackets() []packet.Packet {
+	return []packet.Packet{
+		&packet.Identification{EntityID: 3},
+		&packet.AddEntity{EntityID: 4, Position: packet.Position{X: 1, Y: 2, Z: 3, Yaw: 90}, Name: "bob"},
+		&packet.Chat{Message: "hello"},
+		&packet.SendMonoTypeChunk{X: 0, Y: 4, Z: -1, BlockType: voxel.Stone},
+		&packet.RemoveEntity{EntityID: 4},
+	}
+}
+
+// capturedEvents are the events a client publishes for capturedPackets
+func capturedEvents() []Event {
+	return []Event{
+		IdentifiedEvent{EntityID: 3},
+		EntityAddedEvent{EntityID: 4, X: 1, Y: 2, Z: 3, Yaw: 90, Name: "bob"},
+		ChatEvent{Message: "hello"},
+		MonoChunkEvent{X: 0, Y: 4, Z: -1, BlockType: voxel.Stone},
+		EntityRemovedEvent{EntityID: 4},
+	}
+}
+
+// buildCapture encodes records in the capture format with the given offsets
+func buildCapture(records ...CaptureRecord) []byte {
+	data := []byte(captureMagic)
+	data = append(data, captureVersion)
+	for _, record := range records {
+		payload := packet.Encode(record.Packet)
+		data = binary.BigEndian.AppendUint64(data, uint64(record.Offset))
+		data = binary.BigEndian.AppendUint32(data, uint32(len(payload)))
+		data = append(data, payload...)
+	}
+	return data
+}
+
+// replay runs a replay client over the capture and returns the events published and how long it took
+func replay(t *testing.T, capture []byte, speed float64) ([]Event, time.Duration) {
+	t.Helper()
+	c, err := NewReplayClient(bytes.NewReader(capture), speed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := c.Subscribe(SubscribeOptions{})
+	started := time.Now()
+	if err := c.Run(context.Background()); !errors.Is(err, ErrConnectionClosed) {
+		t.Errorf("replay ended with %v, want ErrConnectionClosed", err)
+	}
+	elapsed := time.Since(started)
+
+	var events []Event
+	for ev := range sub.Events() {
+		events = append(events, ev)
+	}
+	return events, elapsed
+}
+
+func TestCaptureRoundTrip(t *testing.T) {
+	c, server := pipeClient(t)
+	var capture bytes.Buffer
+	recorder, err := NewRecorder(&capture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetRecorder(recorder)
+
+	go func() {
+		for _, p := range capturedPackets() {
+			packet.Write(server, p)
+		}
+		server.Close()
+	}()
+	if err := c.Run(context.Background()); !errors.Is(err, ErrConnectionClosed) {
+		t.Fatalf("client ended with %v, want ErrConnectionClosed", err)
+	}
+
+	// Run flushed the capture when the connection ended
+	reader, err := NewCaptureReader(bytes.NewReader(capture.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var previous time.Duration
+	for i, want := range capturedPackets() {
+		record, err := reader.Next()
+		if err != nil {
+			t.Fatalf("record %d: %v", i, err)
+		}
+		if !reflect.DeepEqual(record.Packet, want) {
+			t.Errorf("record %d is %+v, want %+v", i, record.Packet, want)
+		}
+		if record.Offset < previous {
+			t.Errorf("record %d at %v, before the one before it at %v", i, record.Offset, previous)
+		}
+		previous = record.Offset
+	}
+	if _, err := reader.Next(); err != io.EOF {
+		t.Errorf("got %v after the last record, want io.EOF", err)
+	}
+
+	events, _ := replay(t, capture.Bytes(), 0)
+	if !reflect.DeepEqual(events, capturedEvents()) {
+		t.Errorf("replayed %+v, want %+v", events, capturedEvents())
+	}
+}
+
+func TestCaptureRejectsBadData(t *testing.T) {
+	valid := buildCapture(CaptureRecord{Packet: &packet.Chat{Message: "hi"}})
+	record := func(length uint32, payload []byte) []byte {
+		data := buildCapture()
+		data = binary.BigEndian.AppendUint64(data, 0)
+		data = binary.BigEndian.AppendUint32(data, length)
+		return append(data, payload...)
+	}
+
+	headers := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"empty", nil, io.EOF},
+		{"short header", []byte("VXC"), io.ErrUnexpectedEOF},
+		{"bad magic", append([]byte("VXCK"), captureVersion), ErrInvalidCapture},
+		{"bad version", append([]byte(captureMagic), captureVersion+1), ErrInvalidCapture},
+	}
+	for _, tt := range headers {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewCaptureReader(bytes.NewReader(tt.data)); !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+			if _, err := NewReplayClient(bytes.NewReader(tt.data), 0); !errors.Is(err, tt.want) {
+				t.Errorf("replaying: got %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	records := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"zero length", record(0, nil), ErrInvalidCapture},
+		{"oversized", record(maxCaptured+1, nil), ErrInvalidCapture},
+		{"unknown packet", record(1, []byte{0xFF}), ErrInvalidCapture},
+		{"truncated header", valid[:len(captureMagic)+1+6], io.ErrUnexpectedEOF},
+		{"truncated packet", valid[:len(valid)-1], io.ErrUnexpectedEOF},
+	}
+	for _, tt := range records {
+		t.Run(tt.name, func(t *testing.T) {
+			reader, err := NewCaptureReader(bytes.NewReader(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := reader.Next(); !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplayTiming(t *testing.T) {
+	var records []CaptureRecord
+	for i, p := range capturedPackets() {
+		records = append(records, CaptureRecord{Offset: time.Duration(i) * 100 * time.Millisecond, Packet: p})
+	}
+	capture := buildCapture(records...)
+
+	// Recorded over 400ms
+	events, elapsed := replay(t, capture, 0)
+	if elapsed >= 200*time.Millisecond {
+		t.Errorf("replay without delays took %v", elapsed)
+	}
+	if !reflect.DeepEqual(events, capturedEvents()) {
+		t.Errorf("replayed %+v, want %+v", events, capturedEvents())
+	}
+
+	events, elapsed = replay(t, capture, 2)
+	if elapsed < 200*time.Millisecond || elapsed >= 400*time.Millisecond {
+		t.Errorf("replay at twice the speed took %v, want 200ms", elapsed)
+	}
+	if !reflect.DeepEqual(events, capturedEvents()) {
+		t.Errorf("replayed %+v, want %+v", events, capturedEvents())
+	}
+}
@@ -1,459 +1,609 @@
 package network
 
 import (
-	"encoding/binary"
+	"cmp"
+	"context"
+	"errors"
 	"fmt"
 	"io"
-	"math"
 	"net"
-	"strings"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/leterax/go-voxels/pkg/network/packet"
 	"github.com/leterax/go-voxels/pkg/voxel"
 )
 
 const (
 	ServerPort = 20000
-	ChunkSize  = 16
+	ChunkSize  = packet.ChunkSize
+
+	handshakeTimeout = 10 * time.Second // Time allowed for the server's Hello
+)
+
+// ErrIncompatibleVersion is returned by NewClient when the server speaks no supported protocol version
+var ErrIncompatibleVersion = packet.ErrIncompatibleVersion
+
+// Errors ending the connection when the server sends a packet breaking the protocol's rules,
+// see the packet package. The client closes rather than hand invalid values to callbacks.
+var (
+	ErrUnknownPacket      = packet.ErrUnknownPacket
+	ErrInvalidBlockType   = packet.ErrInvalidBlockType
+	ErrInvalidBlockState  = packet.ErrInvalidBlockState
+	ErrInvalidText        = packet.ErrInvalidText
+	ErrInvalidChunkCoords = packet.ErrInvalidChunkCoords
+	ErrInvalidPosition    = packet.ErrInvalidPosition
+	ErrInvalidLength      = packet.ErrInvalidLength
 )
 
 // ClientBound packet IDs
 const (
-	PacketIDIdentification       uint8 = 0x00
-	PacketIDAddEntity            uint8 = 0x01
-	PacketIDRemoveEntity         uint8 = 0x02
-	PacketIDUpdateEntityPosition uint8 = 0x03
-	PacketIDSendChunk            uint8 = 0x04
-	PacketIDSendMonoTypeChunk    uint8 = 0x05
-	PacketIDChat                 uint8 = 0x06
-	PacketIDUpdateEntityMetadata uint8 = 0x07
+	PacketIDIdentification       = packet.IDIdentification
+	PacketIDAddEntity            = packet.IDAddEntity
+	PacketIDRemoveEntity         = packet.IDRemoveEntity
+	PacketIDUpdateEntityPosition = packet.IDUpdateEntityPosition
+	PacketIDSendChunk            = packet.IDSendChunk
+	PacketIDSendMonoTypeChunk    = packet.IDSendMonoTypeChunk
+	PacketIDChat                 = packet.IDChat
+	PacketIDUpdateEntityMetadata = packet.IDUpdateEntityMetadata
+	PacketIDSendChunkStates      = packet.IDSendChunkStates
 )
 
 // ServerBound packet IDs
 const (
-	PacketIDUpdateEntity   uint8 = 0x00
-	PacketIDUpdateBlock    uint8 = 0x01
-	PacketIDBlockBulkEdit  uint8 = 0x02
-	PacketIDChatMessage    uint8 = 0x03
-	PacketIDClientMetadata uint8 = 0x04
+	PacketIDUpdateEntity     = packet.IDUpdateEntity
+	PacketIDUpdateBlock      = packet.IDUpdateBlock
+	PacketIDBlockBulkEdit    = packet.IDBlockBulkEdit
+	PacketIDChatMessage      = packet.IDChatMessage
+	PacketIDClientMetadata   = packet.IDClientMetadata
+	PacketIDUpdateBlockState = packet.IDUpdateBlockState
 )
 
 // Client represents a connection to the voxel game server
 type Client struct {
-	conn             net.Conn
-	entityID         uint32
-	entityName       string
-	renderDist       uint8
+	conn             net.Conn // nil for replay clients
+	next             func(ctx context.Context) (packet.Packet, error)
+	decoder          *packet.Decoder // nil for replay clients
+	stats            *statsTracker
+	recorder         *Recorder
+	out              *outbox
+	entityID         atomic.Uint32
+	capabilities     uint32        // Optional features the client accepts
+	negotiated       atomic.Uint32 // Optional features enabled with the server
+	pingToken        atomic.Uint32 // Last token sent in a Ping
+	version          uint16        // Protocol version agreed in the handshake
 	OnEntityAdd      func(entityID uint32, x, y, z, yaw, pitch float32, name string)
 	OnEntityRemove   func(entityID uint32)
 	OnEntityUpdate   func(entityID uint32, x, y, z, yaw, pitch float32)
 	OnChunkReceive   func(x, y, z int32, blocks []voxel.BlockType)
+	OnChunkStates    func(x, y, z int32, blocks []voxel.BlockType, props []uint8)
 	OnMonoChunk      func(x, y, z int32, blockType voxel.BlockType)
 	OnChat           func(message string)
 	OnEntityMetadata func(entityID uint32, name string)
+	OnBlockChange    func(x, y, z int32, state voxel.BlockState)
+	OnChunkUnload    func(x, y, z int32)
+
+	eventHub // Subscribe and Err
+
+	chunksMu sync.Mutex
+	loaded   map[voxel.ChunkCoord]struct{} // Chunks received and neither unloaded nor dropped since
+
+	mu         sync.Mutex
+	entityName string
+	renderDist uint8
+	running    bool
+	closed     bool
+	done       chan struct{} // Closed to stop the writer goroutine
+	stopOnce   sync.Once
 }
 
-// NewClient creates a new client connected to the server at the given address
+// NewClient creates a new client connected to the server at the given address,
+// over WebSocket for ws:// and wss:// addresses and TCP otherwise (see Dial).
+// It fails with ErrIncompatibleVersion if the server speaks no supported protocol version.
+// Servers predating the handshake are spoken to with packet.LegacyProtocolVersion and no capabilities.
 func NewClient(address string) (*Client, error) {
-	if !strings.Contains(address, ":") {
-		address = fmt.Sprintf("%s:%d", address, ServerPort)
+	return NewClientWithCapabilities(address, packet.SupportedCapabilities)
+}
+
+// NewClientWithCapabilities is NewClient accepting only the given optional features from the server
+func NewClientWithCapabilities(address string, capabilities uint32) (*Client, error) {
+	return NewClientContext(context.Background(), address, capabilities)
+}
+
+// NewClientContext is NewClientWithCapabilities giving up when ctx is done,
+// while dialling or during the handshake
+func NewClientContext(ctx context.Context, address string, capabilities uint32) (*Client, error) {
+	conn, err := DialContext(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	// Closing the connection interrupts the handshake
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	c, err := NewClientConn(conn, capabilities)
+	if !stop() {
+		if c != nil {
+			c.Close()
+		}
+		return nil, ctx.Err()
 	}
+	return c, err
+}
 
-	conn, err := net.Dial("tcp", address)
+// NewClientConn runs the protocol over an established connection of any transport.
+// The connection is closed if the handshake fails.
+func NewClientConn(conn net.Conn, capabilities uint32) (*Client, error) {
+	c, err := newClient(conn, capabilities)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to server: %w", err)
+		conn.Close()
+		return nil, err
 	}
+	return c, nil
+}
 
-	return &Client{
-		conn:       conn,
-		renderDist: 8, // Default render distance
-	}, nil
+// newClient exchanges Hello packets on an established connection and starts the writer,
+// or creates a client without a connection if conn is nil
+func newClient(conn net.Conn, capabilities uint32) (*Client, error) {
+	c := &Client{
+		conn:         conn,
+		out:          newOutbox(),
+		renderDist:   8, // Default render distance
+		capabilities: capabilities & packet.SupportedCapabilities,
+		loaded:       make(map[voxel.ChunkCoord]struct{}),
+		stats:        newStatsTracker(),
+		done:         make(chan struct{}),
+	}
+	c.out.sent = c.stats.sent
+	if conn != nil {
+		decoder := packet.NewDecoder(conn, packet.ClientBound)
+		first, err := c.handshake(decoder)
+		if err != nil {
+			return nil, err
+		}
+		c.decoder = decoder
+		c.next = func(context.Context) (packet.Packet, error) {
+			// Legacy servers start with a packet to dispatch
+			if p := first; p != nil {
+				first = nil
+				return p, nil
+			}
+			return decoder.Next()
+		}
+	}
+	go c.writeLoop()
+	return c, nil
 }
 
-// Close closes the connection to the server
-func (c *Client) Close() error {
+// handshake sends the client's Hello and reads the server's reply, before anything else is sent.
+// Servers predating the handshake reply with Identification, which is returned to be dispatched.
+func (c *Client) handshake(decoder *packet.Decoder) (packet.Packet, error) {
+	c.conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	defer c.conn.SetDeadline(time.Time{})
+
+	hello := &packet.ClientHello{
+		Version:    packet.ProtocolVersion,
+		MinVersion: packet.MinProtocolVersion,
+		Flags:      c.capabilities,
+	}
+	if err := packet.Write(c.conn, hello); err != nil {
+		return nil, fmt.Errorf("failed to send client hello: %w", err)
+	}
+	c.stats.sent(hello, len(packet.Encode(hello)), time.Now())
+
+	p, err := decoder.Next()
+	switch {
+	case err == io.EOF:
+		return nil, ErrConnectionClosed
+	case errors.Is(err, packet.ErrUnknownPacket):
+		return nil, fmt.Errorf("%w: server sent no hello: %w", ErrIncompatibleVersion, err)
+	case err != nil:
+		return nil, fmt.Errorf("failed to read server hello: %w", err)
+	}
+	reply, ok := p.(*packet.ServerHello)
+	if !ok {
+		if _, ok := p.(*packet.Identification); ok {
+			// A legacy server, spoken to without capabilities or framing
+			c.version = packet.LegacyProtocolVersion
+			return p, nil
+		}
+		return nil, fmt.Errorf("%w: server sent %T instead of a hello", ErrIncompatibleVersion, p)
+	}
+	c.stats.received(p, decoder.Size(), decoder.DecodeTime(), 0, time.Now())
+
+	version, err := packet.NegotiateVersion(reply.Version, reply.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+	// The server enables a subset of the capabilities accepted, framing is each side's choice
+	decoder.SetFramed(reply.Flags&packet.CapabilityFramed != 0)
+	c.out.framed = c.capabilities&packet.CapabilityFramed != 0
+	c.version = version
+	c.negotiated.Store(reply.Flags & c.capabilities)
+	return nil, nil
+}
+
+// writeLoop runs the writer goroutine, closing the connection if a write fails
+func (c *Client) writeLoop() {
+	if c.conn == nil {
+		c.out.run(io.Discard, c.done)
+		return
+	}
+	c.out.run(c.conn, c.done)
+	c.conn.Close()
+}
+
+// closeConn closes the connection, if there is one
+func (c *Client) closeConn() error {
+	if c.conn == nil {
+		return nil
+	}
 	return c.conn.Close()
 }
 
+// stopWriter stops the writer goroutine, dropping anything not yet written
+func (c *Client) stopWriter() {
+	c.stopOnce.Do(func() { close(c.done) })
+}
+
+// Close closes the connection to the server. A running Run returns ErrClientClosed.
+// Packets not written yet are dropped, call Flush first to send them.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	c.closed = true
+	c.mu.Unlock()
+	c.out.stop(ErrClientClosed)
+	c.stopWriter()
+	return c.closeConn()
+}
+
+// Flush blocks until every packet sent so far has been written to the connection
+func (c *Client) Flush() error {
+	return c.out.flush()
+}
+
+// EntityID returns the entity ID assigned by the server, 0 until identified
+func (c *Client) EntityID() uint32 {
+	return c.entityID.Load()
+}
+
+// Stats returns a snapshot of the connection's traffic, chunk rate, decode time and round trip
+func (c *Client) Stats() Stats {
+	return c.stats.snapshot(time.Now())
+}
+
 // SetEntityName sets the name of the client's entity
 func (c *Client) SetEntityName(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.entityName = name
 }
 
 // SetRenderDistance sets the render distance for the client
 func (c *Client) SetRenderDistance(distance uint8) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.renderDist = distance
 }
 
+// Capabilities returns the optional features enabled with the server
+func (c *Client) Capabilities() uint32 {
+	return c.negotiated.Load()
+}
+
+// ProtocolVersion returns the protocol version agreed with the server,
+// packet.LegacyProtocolVersion for servers predating the handshake and replay clients
+func (c *Client) ProtocolVersion() uint16 {
+	return c.version
+}
+
+// The Send methods are safe for concurrent use. They queue packets for a single writer goroutine
+// and only fail if the queue is full or the connection is gone.
+
 // SendClientMetadata sends the client metadata to the server
 func (c *Client) SendClientMetadata() error {
-	// Packet structure: id(U8) + renderDistance(U8) + name(U8[64])
-	packet := make([]byte, 1+1+64)
-	packet[0] = PacketIDClientMetadata
-	packet[1] = c.renderDist
-
-	// Copy name, truncating or padding with zeros as needed
-	nameBytes := []byte(c.entityName)
-	if len(nameBytes) > 64 {
-		nameBytes = nameBytes[:64]
-	}
-	copy(packet[2:], nameBytes)
-
-	_, err := c.conn.Write(packet)
-	return err
+	c.mu.Lock()
+	metadata := &packet.ClientMetadata{RenderDistance: c.renderDist, Name: c.entityName}
+	c.mu.Unlock()
+	return c.out.enqueue(metadata)
 }
 
-// SendUpdateEntity sends the client's entity position to the server
+// SendUpdateEntity sends the client's entity position to the server.
+// Only the latest position is sent if several are queued in a row before the writer catches up.
 func (c *Client) SendUpdateEntity(x, y, z, yaw, pitch float32) error {
-	// Packet structure: id(U8) + x(F32) + y(F32) + z(F32) + yaw(F32) + pitch(F32)
-	packet := make([]byte, 1+4*5)
-	packet[0] = PacketIDUpdateEntity
-
-	// Write floats in big endian
-	binary.BigEndian.PutUint32(packet[1:], float32ToUint32(x))
-	binary.BigEndian.PutUint32(packet[5:], float32ToUint32(y))
-	binary.BigEndian.PutUint32(packet[9:], float32ToUint32(z))
-	binary.BigEndian.PutUint32(packet[13:], float32ToUint32(yaw))
-	binary.BigEndian.PutUint32(packet[17:], float32ToUint32(pitch))
-
-	_, err := c.conn.Write(packet)
-	return err
+	return c.out.setPosition(&packet.UpdateEntity{
+		Position: packet.Position{X: x, Y: y, Z: z, Yaw: yaw, Pitch: pitch},
+	})
 }
 
-// SendUpdateBlock sends a block update to the server
+// SendUpdateBlock sends a block update to the server.
+// Updates queued before the writer catches up are merged into one Block Bulk Edit.
 func (c *Client) SendUpdateBlock(blockType voxel.BlockType, x, y, z int32) error {
-	// Packet structure: id(U8) + blockType(U8) + x(I32) + y(I32) + z(I32)
-	packet := make([]byte, 1+1+4*3)
-	packet[0] = PacketIDUpdateBlock
-	packet[1] = uint8(blockType)
-
-	// Write coordinates in big endian
-	binary.BigEndian.PutUint32(packet[2:], uint32(x))
-	binary.BigEndian.PutUint32(packet[6:], uint32(y))
-	binary.BigEndian.PutUint32(packet[10:], uint32(z))
+	return c.out.addBlocks(BlockUpdate{BlockType: blockType, X: x, Y: y, Z: z})
+}
 
-	_, err := c.conn.Write(packet)
-	return err
+// SendUpdateBlockState sends a block update carrying block state properties to the server
+func (c *Client) SendUpdateBlockState(state voxel.BlockState, x, y, z int32) error {
+	return c.out.enqueue(&packet.UpdateBlockState{State: state, X: x, Y: y, Z: z})
 }
 
-// SendBlockBulkEdit sends multiple block updates to the server
+// SendBlockBulkEdit sends multiple block updates to the server.
+// It fails with ErrSendQueueFull if more than MaxQueuedBlocks updates are waiting.
 func (c *Client) SendBlockBulkEdit(updates []BlockUpdate) error {
 	if len(updates) == 0 {
 		return nil
 	}
-
-	// Packet structure: id(U8) + blockCount(U32) + [blockType(U8) + x(I32) + y(I32) + z(I32)...]
-	packetSize := 1 + 4 + (1+4*3)*len(updates)
-	packet := make([]byte, packetSize)
-
-	packet[0] = PacketIDBlockBulkEdit
-	binary.BigEndian.PutUint32(packet[1:], uint32(len(updates)))
-
-	offset := 5
-	for _, update := range updates {
-		packet[offset] = uint8(update.BlockType)
-		binary.BigEndian.PutUint32(packet[offset+1:], uint32(update.X))
-		binary.BigEndian.PutUint32(packet[offset+5:], uint32(update.Y))
-		binary.BigEndian.PutUint32(packet[offset+9:], uint32(update.Z))
-		offset += 13
-	}
-
-	_, err := c.conn.Write(packet)
-	return err
+	return c.out.addBlocks(updates...)
 }
 
 // SendChat sends a chat message to the server
 func (c *Client) SendChat(message string) error {
-	// Packet structure: id(U8) + message(U8[4096])
-	packet := make([]byte, 1+4096)
-	packet[0] = PacketIDChatMessage
-
-	// Copy message, truncating or padding with zeros as needed
-	msgBytes := []byte(message)
-	if len(msgBytes) > 4096 {
-		msgBytes = msgBytes[:4096]
-	}
-	copy(packet[1:], msgBytes)
-
-	_, err := c.conn.Write(packet)
-	return err
+	return c.out.enqueue(&packet.ChatMessage{Message: message})
 }
 
-// BlockUpdate represents a single block update
-type BlockUpdate struct {
-	BlockType voxel.BlockType
-	X, Y, Z   int32
+// DropChunk discards a loaded chunk and tells the server to stop sending it and its changes,
+// until RequestChunk asks for it again or it leaves and reenters the render distance
+func (c *Client) DropChunk(x, y, z int32) error {
+	c.chunksMu.Lock()
+	delete(c.loaded, voxel.ChunkCoord{X: x, Y: y, Z: z})
+	c.chunksMu.Unlock()
+	return c.out.enqueue(&packet.DropChunk{X: x, Y: y, Z: z})
 }
 
-// ProcessPackets starts processing incoming packets from the server
-func (c *Client) ProcessPackets() error {
-	for {
-		// Read packet ID
-		var packetID uint8
-		if err := binary.Read(c.conn, binary.BigEndian, &packetID); err != nil {
-			if err == io.EOF {
-				return fmt.Errorf("connection closed by server")
-			}
-			return fmt.Errorf("failed to read packet ID: %w", err)
-		}
-
-		// Process packet based on ID
-		switch packetID {
-		case PacketIDIdentification:
-			if err := c.handleIdentification(); err != nil {
-				return err
-			}
-		case PacketIDAddEntity:
-			if err := c.handleAddEntity(); err != nil {
-				return err
-			}
-		case PacketIDRemoveEntity:
-			if err := c.handleRemoveEntity(); err != nil {
-				return err
-			}
-		case PacketIDUpdateEntityPosition:
-			if err := c.handleUpdateEntityPosition(); err != nil {
-				return err
-			}
-		case PacketIDSendChunk:
-			if err := c.handleSendChunk(); err != nil {
-				return err
-			}
-		case PacketIDSendMonoTypeChunk:
-			if err := c.handleSendMonoTypeChunk(); err != nil {
-				return err
-			}
-		case PacketIDChat:
-			if err := c.handleChat(); err != nil {
-				return err
-			}
-		case PacketIDUpdateEntityMetadata:
-			if err := c.handleUpdateEntityMetadata(); err != nil {
-				return err
-			}
-		default:
-			return fmt.Errorf("unknown packet ID: %d", packetID)
-		}
+// Ping asks the server for a Pong, which adds a round trip sample to Stats.
+// It fails with errors.ErrUnsupported unless the server enabled CapabilityPing.
+func (c *Client) Ping() error {
+	if c.Capabilities()&packet.CapabilityPing == 0 {
+		return fmt.Errorf("%w: the server doesn't answer pings", errors.ErrUnsupported)
 	}
+	return c.out.enqueue(&packet.Ping{Token: c.pingToken.Add(1)})
 }
 
-func (c *Client) handleIdentification() error {
-	var entityID uint32
-	if err := binary.Read(c.conn, binary.BigEndian, &entityID); err != nil {
-		return fmt.Errorf("failed to read entity ID: %w", err)
-	}
-
-	c.entityID = entityID
-	return nil
+// RequestChunk asks the server to send a chunk within the render distance, even if it was sent
+// before. The server answers empty chunks with a Send Mono Type Chunk of air.
+func (c *Client) RequestChunk(x, y, z int32) error {
+	return c.out.enqueue(&packet.RequestChunk{X: x, Y: y, Z: z})
 }
 
-func (c *Client) handleAddEntity() error {
-	var entityID uint32
-	var x, y, z, yaw, pitch float32
-
-	if err := binary.Read(c.conn, binary.BigEndian, &entityID); err != nil {
-		return fmt.Errorf("failed to read entity ID: %w", err)
-	}
-
-	if err := binary.Read(c.conn, binary.BigEndian, &x); err != nil {
-		return fmt.Errorf("failed to read x: %w", err)
-	}
-
-	if err := binary.Read(c.conn, binary.BigEndian, &y); err != nil {
-		return fmt.Errorf("failed to read y: %w", err)
+// LoadedChunks returns the chunks received from the server and not unloaded or dropped since,
+// ordered by coordinates. Without CapabilityChunkUnload the server never reports unloads.
+func (c *Client) LoadedChunks() []voxel.ChunkCoord {
+	c.chunksMu.Lock()
+	coords := make([]voxel.ChunkCoord, 0, len(c.loaded))
+	for coord := range c.loaded {
+		coords = append(coords, coord)
 	}
+	c.chunksMu.Unlock()
 
-	if err := binary.Read(c.conn, binary.BigEndian, &z); err != nil {
-		return fmt.Errorf("failed to read z: %w", err)
-	}
-
-	if err := binary.Read(c.conn, binary.BigEndian, &yaw); err != nil {
-		return fmt.Errorf("failed to read yaw: %w", err)
-	}
-
-	if err := binary.Read(c.conn, binary.BigEndian, &pitch); err != nil {
-		return fmt.Errorf("failed to read pitch: %w", err)
-	}
-
-	// Read name (64 bytes)
-	nameBytes := make([]byte, 64)
-	if _, err := io.ReadFull(c.conn, nameBytes); err != nil {
-		return fmt.Errorf("failed to read name: %w", err)
-	}
-
-	// Extract null-terminated name
-	name := string(nameBytes)
-	if idx := strings.IndexByte(name, 0); idx >= 0 {
-		name = name[:idx]
-	}
-
-	if c.OnEntityAdd != nil {
-		c.OnEntityAdd(entityID, x, y, z, yaw, pitch, name)
-	}
-
-	return nil
+	slices.SortFunc(coords, func(a, b voxel.ChunkCoord) int {
+		return cmp.Or(cmp.Compare(a.X, b.X), cmp.Compare(a.Y, b.Y), cmp.Compare(a.Z, b.Z))
+	})
+	return coords
 }
 
-func (c *Client) handleRemoveEntity() error {
-	var entityID uint32
-	if err := binary.Read(c.conn, binary.BigEndian, &entityID); err != nil {
-		return fmt.Errorf("failed to read entity ID: %w", err)
-	}
-
-	if c.OnEntityRemove != nil {
-		c.OnEntityRemove(entityID)
-	}
-
-	return nil
+// HasChunk reports whether the chunk is loaded
+func (c *Client) HasChunk(coord voxel.ChunkCoord) bool {
+	c.chunksMu.Lock()
+	defer c.chunksMu.Unlock()
+	_, ok := c.loaded[coord]
+	return ok
 }
 
-func (c *Client) handleUpdateEntityPosition() error {
-	var entityID uint32
-	var x, y, z, yaw, pitch float32
-
-	if err := binary.Read(c.conn, binary.BigEndian, &entityID); err != nil {
-		return fmt.Errorf("failed to read entity ID: %w", err)
-	}
-
-	if err := binary.Read(c.conn, binary.BigEndian, &x); err != nil {
-		return fmt.Errorf("failed to read x: %w", err)
-	}
-
-	if err := binary.Read(c.conn, binary.BigEndian, &y); err != nil {
-		return fmt.Errorf("failed to read y: %w", err)
-	}
-
-	if err := binary.Read(c.conn, binary.BigEndian, &z); err != nil {
-		return fmt.Errorf("failed to read z: %w", err)
-	}
-
-	if err := binary.Read(c.conn, binary.BigEndian, &yaw); err != nil {
-		return fmt.Errorf("failed to read yaw: %w", err)
-	}
-
-	if err := binary.Read(c.conn, binary.BigEndian, &pitch); err != nil {
-		return fmt.Errorf("failed to read pitch: %w", err)
-	}
-
-	if c.OnEntityUpdate != nil {
-		c.OnEntityUpdate(entityID, x, y, z, yaw, pitch)
-	}
-
-	return nil
+// setLoaded adds a chunk to or removes it from the loaded set, reporting whether that changed it
+func (c *Client) setLoaded(coord voxel.ChunkCoord, loaded bool) bool {
+	c.chunksMu.Lock()
+	defer c.chunksMu.Unlock()
+	_, was := c.loaded[coord]
+	if loaded {
+		c.loaded[coord] = struct{}{}
+	} else {
+		delete(c.loaded, coord)
+	}
+	return was != loaded
 }
 
-func (c *Client) handleSendChunk() error {
-	var x, y, z int32
-
-	if err := binary.Read(c.conn, binary.BigEndian, &x); err != nil {
-		return fmt.Errorf("failed to read x: %w", err)
-	}
-
-	if err := binary.Read(c.conn, binary.BigEndian, &y); err != nil {
-		return fmt.Errorf("failed to read y: %w", err)
-	}
-
-	if err := binary.Read(c.conn, binary.BigEndian, &z); err != nil {
-		return fmt.Errorf("failed to read z: %w", err)
-	}
-
-	// Read chunk data (ChunkSize^3 bytes)
-	chunkDataSize := ChunkSize * ChunkSize * ChunkSize
-	chunkData := make([]byte, chunkDataSize)
-	if _, err := io.ReadFull(c.conn, chunkData); err != nil {
-		return fmt.Errorf("failed to read chunk data: %w", err)
-	}
-
-	// Convert to BlockType slice
-	blocks := make([]voxel.BlockType, chunkDataSize)
-	for i := range chunkDataSize {
-		blocks[i] = voxel.BlockType(chunkData[i])
-	}
+// BlockUpdate represents a single block update
+type BlockUpdate = packet.BlockUpdate
 
-	if c.OnChunkReceive != nil {
-		c.OnChunkReceive(x, y, z, blocks)
-	}
+// BlockChange is a block changed by the server, in world coordinates
+type BlockChange = packet.BlockChange
 
-	return nil
+// ReleaseBlocks lets the client reuse the blocks slice passed to OnChunkReceive or OnChunkStates.
+// Callbacks that copy the blocks into their own storage can call it to avoid an allocation per chunk.
+// ChunkEvent carries its own copy, so releasing doesn't affect subscriptions.
+func ReleaseBlocks(blocks []voxel.BlockType) {
+	packet.ReleaseBlocks(blocks)
 }
 
-func (c *Client) handleSendMonoTypeChunk() error {
-	var x, y, z int32
-	var blockType voxel.BlockType
-
-	if err := binary.Read(c.conn, binary.BigEndian, &x); err != nil {
-		return fmt.Errorf("failed to read x: %w", err)
-	}
-
-	if err := binary.Read(c.conn, binary.BigEndian, &y); err != nil {
-		return fmt.Errorf("failed to read y: %w", err)
-	}
-
-	if err := binary.Read(c.conn, binary.BigEndian, &z); err != nil {
-		return fmt.Errorf("failed to read z: %w", err)
-	}
+// ProcessPackets reads packets from the server until the connection ends.
+// It is Run without a context.
+func (c *Client) ProcessPackets() error {
+	return c.Run(context.Background())
+}
 
-	// Read single block type
-	var blockTypeByte uint8
-	if err := binary.Read(c.conn, binary.BigEndian, &blockTypeByte); err != nil {
-		return fmt.Errorf("failed to read block type: %w", err)
+// Run reads packets from the server, calling the callbacks and publishing events to subscriptions,
+// until the connection fails, Close is called or ctx is cancelled. Cancelling ctx closes the connection.
+// The returned error is also reported by Err, and subscription channels are closed when Run returns.
+// Run can only be called once, later calls return ErrAlreadyRunning.
+func (c *Client) Run(ctx context.Context) error {
+	c.mu.Lock()
+	if c.running {
+		c.mu.Unlock()
+		return ErrAlreadyRunning
+	}
+	c.running = true
+	c.mu.Unlock()
+
+	stop := context.AfterFunc(ctx, func() { c.closeConn() })
+	defer stop()
+
+	err := c.readLoop(ctx)
+
+	c.mu.Lock()
+	closed := c.closed
+	c.mu.Unlock()
+	switch {
+	case ctx.Err() != nil:
+		err = ctx.Err()
+	case closed:
+		err = ErrClientClosed
+	}
+
+	if c.recorder != nil {
+		if flushErr := c.recorder.Flush(); flushErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to flush capture: %w", flushErr))
+		}
 	}
-	blockType = voxel.BlockType(blockTypeByte)
 
-	if c.OnMonoChunk != nil {
-		c.OnMonoChunk(x, y, z, blockType)
-	}
+	// Later sends fail with the same error
+	c.out.stop(err)
+	c.stopWriter()
 
-	return nil
+	c.finish(err)
+	return err
 }
 
-func (c *Client) handleChat() error {
-	// Read message (4096 bytes)
-	messageBytes := make([]byte, 4096)
-	if _, err := io.ReadFull(c.conn, messageBytes); err != nil {
-		return fmt.Errorf("failed to read message: %w", err)
-	}
-
-	// Extract null-terminated message
-	message := string(messageBytes)
-	if idx := strings.IndexByte(message, 0); idx >= 0 {
-		message = message[:idx]
+// readLoop decodes and dispatches packets until reading fails
+func (c *Client) readLoop(ctx context.Context) error {
+	for {
+		p, err := c.next(ctx)
+		if err != nil {
+			if err == io.EOF {
+				return ErrConnectionClosed
+			}
+			return err
+		}
+		if c.decoder != nil {
+			c.stats.received(p, c.decoder.Size(), c.decoder.DecodeTime(), c.EntityID(), time.Now())
+		} else {
+			c.stats.received(p, len(packet.Encode(p)), 0, c.EntityID(), time.Now())
+		}
+		if c.recorder != nil {
+			if err := c.recorder.Record(p); err != nil {
+				return err
+			}
+		}
+		if err := c.handlePacket(ctx, p); err != nil {
+			return err
+		}
 	}
+}
 
-	if c.OnChat != nil {
-		c.OnChat(message)
+// handlePacket dispatches a decoded packet to the matching callback and the subscriptions
+func (c *Client) handlePacket(ctx context.Context, p packet.Packet) error {
+	switch p := p.(type) {
+	case *packet.Identification:
+		c.entityID.Store(p.EntityID)
+		c.publish(ctx, IdentifiedEvent{EntityID: p.EntityID})
+	case *packet.AddEntity:
+		pos := p.Position
+		if c.OnEntityAdd != nil {
+			c.OnEntityAdd(p.EntityID, pos.X, pos.Y, pos.Z, pos.Yaw, pos.Pitch, p.Name)
+		}
+		c.publish(ctx, EntityAddedEvent{
+			EntityID: p.EntityID,
+			X:        pos.X, Y: pos.Y, Z: pos.Z,
+			Yaw: pos.Yaw, Pitch: pos.Pitch,
+			Name: p.Name,
+		})
+	case *packet.RemoveEntity:
+		if c.OnEntityRemove != nil {
+			c.OnEntityRemove(p.EntityID)
+		}
+		c.publish(ctx, EntityRemovedEvent{EntityID: p.EntityID})
+	case *packet.UpdateEntityPosition:
+		pos := p.Position
+		if c.OnEntityUpdate != nil {
+			c.OnEntityUpdate(p.EntityID, pos.X, pos.Y, pos.Z, pos.Yaw, pos.Pitch)
+		}
+		c.publish(ctx, EntityMovedEvent{
+			EntityID: p.EntityID,
+			X:        pos.X, Y: pos.Y, Z: pos.Z,
+			Yaw: pos.Yaw, Pitch: pos.Pitch,
+		})
+	case *packet.SendChunk:
+		c.handleChunk(ctx, p.X, p.Y, p.Z, p.Blocks, nil)
+	case *packet.SendChunkStates:
+		c.handleChunk(ctx, p.X, p.Y, p.Z, p.Blocks, p.Props)
+	case *packet.SendCompressedChunk:
+		start := time.Now()
+		blocks, props, err := p.Decompress()
+		if err != nil {
+			return fmt.Errorf("failed to decompress chunk (%d, %d, %d): %w", p.X, p.Y, p.Z, err)
+		}
+		c.stats.addDecodeTime(p.ID(), time.Since(start))
+		c.handleChunk(ctx, p.X, p.Y, p.Z, blocks, props)
+	case *packet.BlockChange:
+		c.handleBlockChanges(ctx, voxel.WorldToChunkCoord(p.X, p.Y, p.Z, ChunkSize), []BlockChange{*p})
+	case *packet.MultiBlockChange:
+		changes := make([]BlockChange, len(p.Changes))
+		for i, change := range p.Changes {
+			changes[i] = BlockChange{
+				X:     p.X*ChunkSize + int32(change.X),
+				Y:     p.Y*ChunkSize + int32(change.Y),
+				Z:     p.Z*ChunkSize + int32(change.Z),
+				State: change.State,
+			}
+		}
+		c.handleBlockChanges(ctx, voxel.ChunkCoord{X: p.X, Y: p.Y, Z: p.Z}, changes)
+	case *packet.UnloadChunk:
+		if !c.setLoaded(voxel.ChunkCoord{X: p.X, Y: p.Y, Z: p.Z}, false) {
+			break
+		}
+		if c.OnChunkUnload != nil {
+			c.OnChunkUnload(p.X, p.Y, p.Z)
+		}
+		c.publish(ctx, ChunkUnloadedEvent{X: p.X, Y: p.Y, Z: p.Z})
+	case *packet.SendMonoTypeChunk:
+		c.setLoaded(voxel.ChunkCoord{X: p.X, Y: p.Y, Z: p.Z}, true)
+		if c.OnMonoChunk != nil {
+			c.OnMonoChunk(p.X, p.Y, p.Z, p.BlockType)
+		}
+		c.publish(ctx, MonoChunkEvent{X: p.X, Y: p.Y, Z: p.Z, BlockType: p.BlockType})
+	case *packet.Chat:
+		if c.OnChat != nil {
+			c.OnChat(p.Message)
+		}
+		c.publish(ctx, ChatEvent{Message: p.Message})
+	case *packet.UpdateEntityMetadata:
+		if c.OnEntityMetadata != nil {
+			c.OnEntityMetadata(p.EntityID, p.Name)
+		}
+		c.publish(ctx, EntityMetadataEvent{EntityID: p.EntityID, Name: p.Name})
 	}
-
 	return nil
 }
 
-func (c *Client) handleUpdateEntityMetadata() error {
-	var entityID uint32
-
-	if err := binary.Read(c.conn, binary.BigEndian, &entityID); err != nil {
-		return fmt.Errorf("failed to read entity ID: %w", err)
+// handleChunk delivers a full chunk, with props if the server sent block states
+func (c *Client) handleChunk(ctx context.Context, x, y, z int32, blocks []voxel.BlockType, props []uint8) {
+	c.setLoaded(voxel.ChunkCoord{X: x, Y: y, Z: z}, true)
+	// Subscribers get their own copy, callbacks may release the pooled blocks
+	var ev ChunkEvent
+	subscribed := c.subscribed()
+	if subscribed {
+		ev = ChunkEvent{X: x, Y: y, Z: z, Blocks: slices.Clone(blocks), Props: props}
+	}
+	if props != nil && c.OnChunkStates != nil {
+		c.OnChunkStates(x, y, z, blocks, props)
+	} else if c.OnChunkReceive != nil {
+		// Fall back to plain block types for callers that don't track states
+		c.OnChunkReceive(x, y, z, blocks)
 	}
-
-	// Read name (64 bytes)
-	nameBytes := make([]byte, 64)
-	if _, err := io.ReadFull(c.conn, nameBytes); err != nil {
-		return fmt.Errorf("failed to read name: %w", err)
+	if subscribed {
+		c.publish(ctx, ev)
 	}
+}
 
-	// Extract null-terminated name
-	name := string(nameBytes)
-	if idx := strings.IndexByte(name, 0); idx >= 0 {
-		name = name[:idx]
+// handleBlockChanges delivers blocks changed within a chunk the client holds
+func (c *Client) handleBlockChanges(ctx context.Context, chunk voxel.ChunkCoord, changes []BlockChange) {
+	// Changes sent before the server processed a Drop Chunk
+	if !c.HasChunk(chunk) {
+		return
 	}
-
-	if c.OnEntityMetadata != nil {
-		c.OnEntityMetadata(entityID, name)
+	if c.OnBlockChange != nil {
+		for _, change := range changes {
+			c.OnBlockChange(change.X, change.Y, change.Z, change.State)
+		}
 	}
-
-	return nil
-}
-
-// Helper function to convert float32 to uint32 for binary encoding
-func float32ToUint32(f float32) uint32 {
-	bits := math.Float32bits(f)
-	return bits
+	c.publish(ctx, BlocksChangedEvent{Chunk: chunk, Changes: changes})
 }
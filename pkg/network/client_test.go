@@ -0,0 +1,235 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/leterax/go-voxels/pkg/network/packet"
+	"github.com/leterax/go-voxels/pkg/voxel"
+)
+
+// pipeClient returns a client connected to the server end of an in-memory pipe.
+// The server enables no capabilities and doesn't frame its packets.
+func pipeClient(t *testing.T) (*Client, net.Conn) {
+	t.Helper()
+	server, conn := net.Pipe()
+	handshake := make(chan error, 1)
+	go func() { handshake <- acceptHello(server) }()
+
+	c, err := NewClientConn(conn, packet.SupportedCapabilities)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := <-handshake; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		c.Close()
+		server.Close()
+	})
+	return c, server
+}
+
+// nextEvent returns the next event of the given type, skipping any other
+func nextEvent[E Event](t *testing.T, sub *Subscription) E {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case ev, ok := <-sub.Events():
+			if !ok {
+				t.Fatalf("client stopped: %v", sub.Err())
+			}
+			if ev, ok := ev.(E); ok {
+				return ev
+			}
+		case <-timeout:
+			var zero E
+			t.Fatalf("no %T received", zero)
+		}
+	}
+}
+
+func chunkOf(blockType voxel.BlockType) []voxel.BlockType {
+	blocks := make([]voxel.BlockType, packet.ChunkVolume)
+	for i := range blocks {
+		blocks[i] = blockType
+	}
+	return blocks
+}
+
+func TestChunkEventSurvivesReleaseBlocks(t *testing.T) {
+	c, server := pipeClient(t)
+	// A callback copying the chunk away and handing the blocks back
+	c.OnChunkReceive = func(x, y, z int32, blocks []voxel.BlockType) {
+		ReleaseBlocks(blocks)
+	}
+	sub := c.Subscribe(SubscribeOptions{Buffer: 4})
+	go c.Run(context.Background())
+
+	for i, blockType := range []voxel.BlockType{voxel.Stone, voxel.Dirt} {
+		if err := packet.Write(server, &packet.SendChunk{X: int32(i), Blocks: chunkOf(blockType)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	first := nextEvent[ChunkEvent](t, sub)
+	second := nextEvent[ChunkEvent](t, sub)
+	for i := range first.Blocks {
+		if first.Blocks[i] != voxel.Stone || second.Blocks[i] != voxel.Dirt {
+			t.Fatalf("block %d is %v and %v, want stone and dirt", i, first.Blocks[i], second.Blocks[i])
+		}
+	}
+}
+
+func TestLegacyServer(t *testing.T) {
+	server, conn := net.Pipe()
+	defer server.Close()
+	go func() {
+		// A server predating the handshake, skipping the Client Hello and replying with Identification
+		io.ReadFull(server, make([]byte, len(packet.Encode(&packet.ClientHello{}))))
+		packet.Write(server, &packet.Identification{EntityID: 7})
+		packet.Write(server, &packet.Chat{Message: "welcome"})
+	}()
+
+	c, err := NewClientConn(conn, packet.SupportedCapabilities)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if c.ProtocolVersion() != packet.LegacyProtocolVersion || c.Capabilities() != 0 {
+		t.Errorf("version %d, capabilities %#x, want the legacy version without capabilities",
+			c.ProtocolVersion(), c.Capabilities())
+	}
+	sub := c.Subscribe(SubscribeOptions{})
+	go c.Run(context.Background())
+
+	if ev := nextEvent[IdentifiedEvent](t, sub); ev.EntityID != 7 || c.EntityID() != 7 {
+		t.Errorf("identified as %d, want 7", ev.EntityID)
+	}
+	if ev := nextEvent[ChatEvent](t, sub); ev.Message != "welcome" {
+		t.Errorf("chat %q, want the message after Identification", ev.Message)
+	}
+
+	if err := c.Ping(); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("Ping: got %v, want errors.ErrUnsupported", err)
+	}
+
+	// No Client Hello, packets are sent unframed
+	if err := c.SendChat("hi"); err != nil {
+		t.Fatal(err)
+	}
+	p, err := packet.Read(server, packet.ServerBound)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chat, ok := p.(*packet.ChatMessage); !ok || chat.Message != "hi" {
+		t.Errorf("server received %+v, want the chat message", p)
+	}
+}
+
+func TestIncompatibleServer(t *testing.T) {
+	server, conn := net.Pipe()
+	defer server.Close()
+	go func() {
+		packet.Read(server, packet.ServerBound)
+		packet.Write(server, &packet.ServerHello{Version: packet.ProtocolVersion + 2, MinVersion: packet.ProtocolVersion + 1})
+	}()
+
+	if _, err := NewClientConn(conn, packet.SupportedCapabilities); !errors.Is(err, ErrIncompatibleVersion) {
+		t.Errorf("got %v, want ErrIncompatibleVersion", err)
+	}
+}
+
+func TestBlockChanges(t *testing.T) {
+	c, server := pipeClient(t)
+	var changed []BlockChange
+	c.OnBlockChange = func(x, y, z int32, state voxel.BlockState) {
+		changed = append(changed, BlockChange{X: x, Y: y, Z: z, State: state})
+	}
+	sub := c.Subscribe(SubscribeOptions{Buffer: 8})
+	go c.Run(context.Background())
+
+	glass := voxel.BlockState(voxel.Glass)
+	for _, p := range []packet.Packet{
+		// Changes to a chunk the client doesn't hold are ignored
+		&packet.BlockChange{X: 1, Y: 2, Z: 3, State: glass},
+		&packet.SendChunk{X: -1, Y: 0, Z: 2, Blocks: chunkOf(voxel.Stone)},
+		&packet.MultiBlockChange{X: -1, Y: 0, Z: 2, Changes: []packet.LocalBlockChange{
+			{X: 15, Y: 0, Z: 3, State: glass},
+			{X: 0, Y: 1, Z: 0, State: glass},
+		}},
+		&packet.BlockChange{X: -16, Y: 15, Z: 47, State: glass},
+	} {
+		if err := packet.Write(server, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []BlockChange{
+		{X: -1, Y: 0, Z: 35, State: glass},
+		{X: -16, Y: 1, Z: 32, State: glass},
+		{X: -16, Y: 15, Z: 47, State: glass},
+	}
+	multi := nextEvent[BlocksChangedEvent](t, sub)
+	single := nextEvent[BlocksChangedEvent](t, sub)
+	chunk := voxel.ChunkCoord{X: -1, Y: 0, Z: 2}
+	if multi.Chunk != chunk || single.Chunk != chunk {
+		t.Errorf("changes to chunks %v and %v, want %v", multi.Chunk, single.Chunk, chunk)
+	}
+	if got := append(multi.Changes, single.Changes...); !slices.Equal(got, want) {
+		t.Errorf("events carry %v, want %v", got, want)
+	}
+	if !slices.Equal(changed, want) {
+		t.Errorf("OnBlockChange got %v, want %v", changed, want)
+	}
+}
+
+func TestClientMetadataConcurrent(t *testing.T) {
+	c, server := pipeClient(t)
+	server.SetDeadline(time.Now().Add(5 * time.Second))
+	received := make(chan *packet.ClientMetadata)
+	go func() {
+		// The client frames its packets once it announced framing
+		decoder := packet.NewDecoder(server, packet.ServerBound)
+		decoder.SetFramed(true)
+		for {
+			p, err := decoder.Next()
+			if err != nil {
+				close(received)
+				return
+			}
+			received <- p.(*packet.ClientMetadata)
+		}
+	}()
+
+	// Metadata is sent while the game changes it, and must be read under the same lock
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range 100 {
+			c.SetRenderDistance(uint8(i))
+			c.SetEntityName("player")
+		}
+	}()
+	for range 20 {
+		if err := c.SendClientMetadata(); err != nil {
+			t.Fatal(err)
+		}
+		<-received
+	}
+	<-done
+
+	c.SetEntityName("final")
+	c.SetRenderDistance(12)
+	if err := c.SendClientMetadata(); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-received; got == nil || *got != (packet.ClientMetadata{RenderDistance: 12, Name: "final"}) {
+		t.Errorf("sent %+v, want the latest name and render distance", got)
+	}
+}
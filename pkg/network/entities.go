@@ -0,0 +1,270 @@
+package network
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/leterax/go-voxels/pkg/network/packet"
+)
+
+// EntityState is an entity's name and pose at some point in time
+type EntityState struct {
+	EntityID   uint32
+	Name       string
+	X, Y, Z    float32
+	Yaw, Pitch float32 // Degrees
+}
+
+// EntityStoreOptions configures the snapshot buffer of an EntityStore
+type EntityStoreOptions struct {
+	History          int           // Snapshots kept per entity, 32 if zero
+	Delay            time.Duration // How far behind Current renders, 100ms if zero
+	MaxExtrapolation time.Duration // How long to keep moving after updates stop, 250ms if zero
+}
+
+// DefaultEntityStoreOptions returns the options used for zero fields
+func DefaultEntityStoreOptions() EntityStoreOptions {
+	return EntityStoreOptions{
+		History:          32,
+		Delay:            100 * time.Millisecond,
+		MaxExtrapolation: 250 * time.Millisecond,
+	}
+}
+
+// EntityStore keeps the entities announced by the server with a short history of
+// timestamped positions, to render smooth movement from irregular updates.
+// Feed it the events of a Client or ReconnectingClient with Follow or Apply.
+// It is safe for concurrent use.
+type EntityStore struct {
+	options EntityStoreOptions
+
+	mu       sync.RWMutex
+	entities map[uint32]*trackedEntity
+}
+
+// trackedEntity is an entity with its snapshots, oldest first
+type trackedEntity struct {
+	name      string
+	snapshots []entitySnapshot
+}
+
+// entitySnapshot is a position received at a given time
+type entitySnapshot struct {
+	at       time.Time
+	position packet.Position
+}
+
+// NewEntityStore creates an empty store
+func NewEntityStore(options EntityStoreOptions) *EntityStore {
+	defaults := DefaultEntityStoreOptions()
+	if options.History <= 0 {
+		options.History = defaults.History
+	}
+	if options.Delay <= 0 {
+		options.Delay = defaults.Delay
+	}
+	if options.MaxExtrapolation <= 0 {
+		options.MaxExtrapolation = defaults.MaxExtrapolation
+	}
+	return &EntityStore{options: options, entities: make(map[uint32]*trackedEntity)}
+}
+
+// Apply updates the store from a client event received now. Other events are ignored.
+func (s *EntityStore) Apply(ev Event) {
+	s.ApplyAt(ev, time.Now())
+}
+
+// ApplyAt updates the store from a client event received at the given time
+func (s *EntityStore) ApplyAt(ev Event, at time.Time) {
+	switch ev := ev.(type) {
+	case EntityAddedEvent:
+		s.Add(ev.EntityID, ev.Name, packet.Position{X: ev.X, Y: ev.Y, Z: ev.Z, Yaw: ev.Yaw, Pitch: ev.Pitch}, at)
+	case EntityMovedEvent:
+		s.Update(ev.EntityID, packet.Position{X: ev.X, Y: ev.Y, Z: ev.Z, Yaw: ev.Yaw, Pitch: ev.Pitch}, at)
+	case EntityMetadataEvent:
+		s.Rename(ev.EntityID, ev.Name)
+	case EntityRemovedEvent:
+		s.Remove(ev.EntityID)
+	}
+}
+
+// Follow applies every event of the subscription until its channel is closed.
+// Run it in its own goroutine: go store.Follow(client.Subscribe(SubscribeOptions{}))
+func (s *EntityStore) Follow(sub *Subscription) {
+	for ev := range sub.Events() {
+		s.Apply(ev)
+	}
+}
+
+// Add starts tracking an entity, replacing any previous entity with the same ID
+func (s *EntityStore) Add(entityID uint32, name string, position packet.Position, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entities[entityID] = &trackedEntity{
+		name:      name,
+		snapshots: []entitySnapshot{{at: at, position: position}},
+	}
+}
+
+// Update records a new position for a tracked entity. Unknown entities, such as the client's own, are ignored.
+func (s *EntityStore) Update(entityID uint32, position packet.Position, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entity, ok := s.entities[entityID]
+	if !ok {
+		return
+	}
+
+	// Keep the history ordered even if the caller's clock went backwards
+	snapshot := entitySnapshot{at: at, position: position}
+	if last := entity.snapshots[len(entity.snapshots)-1]; at.Before(last.at) {
+		snapshot.at = last.at
+	}
+	if len(entity.snapshots) >= s.options.History {
+		entity.snapshots = slices.Delete(entity.snapshots, 0, len(entity.snapshots)-s.options.History+1)
+	}
+	entity.snapshots = append(entity.snapshots, snapshot)
+}
+
+// Rename changes the name of a tracked entity
+func (s *EntityStore) Rename(entityID uint32, name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entity, ok := s.entities[entityID]; ok {
+		entity.name = name
+	}
+}
+
+// Remove stops tracking an entity
+func (s *EntityStore) Remove(entityID uint32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entities, entityID)
+}
+
+// Clear forgets every entity
+func (s *EntityStore) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.entities)
+}
+
+// Len returns the number of tracked entities
+func (s *EntityStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.entities)
+}
+
+// At returns an entity's state at time t, interpolated between the snapshots around it
+// or extrapolated for at most MaxExtrapolation past the latest one
+func (s *EntityStore) At(entityID uint32, t time.Time) (EntityState, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	entity, ok := s.entities[entityID]
+	if !ok {
+		return EntityState{}, false
+	}
+	return s.state(entityID, entity, t), true
+}
+
+// All returns the state of every entity at time t, ordered by entity ID
+func (s *EntityStore) All(t time.Time) []EntityState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	states := make([]EntityState, 0, len(s.entities))
+	for entityID, entity := range s.entities {
+		states = append(states, s.state(entityID, entity, t))
+	}
+	slices.SortFunc(states, func(a, b EntityState) int {
+		return cmp.Compare(a.EntityID, b.EntityID)
+	})
+	return states
+}
+
+// Current returns the state of every entity Delay ago, the point in time usually rendered
+// so there is a snapshot on either side to interpolate between
+func (s *EntityStore) Current() []EntityState {
+	return s.All(time.Now().Add(-s.options.Delay))
+}
+
+// state computes an entity's pose at time t. The caller must hold s.mu.
+func (s *EntityStore) state(entityID uint32, entity *trackedEntity, t time.Time) EntityState {
+	snapshots := entity.snapshots
+	position := snapshots[0].position
+
+	// Index of the first snapshot after t
+	i, _ := slices.BinarySearchFunc(snapshots, t, func(snapshot entitySnapshot, t time.Time) int {
+		if snapshot.at.After(t) {
+			return 1
+		}
+		return -1
+	})
+	switch {
+	case i == 0:
+		// Before the first snapshot, nothing to interpolate from
+	case i < len(snapshots):
+		from, to := snapshots[i-1], snapshots[i]
+		fraction := float32(t.Sub(from.at)) / float32(to.at.Sub(from.at))
+		position = lerpPosition(from.position, to.position, fraction)
+	default:
+		position = s.extrapolate(snapshots, t)
+	}
+
+	return EntityState{
+		EntityID: entityID,
+		Name:     entity.name,
+		X:        position.X, Y: position.Y, Z: position.Z,
+		Yaw: position.Yaw, Pitch: position.Pitch,
+	}
+}
+
+// velocitySamples is how many snapshots extrapolation averages the velocity over,
+// so updates arriving in bursts don't exaggerate it
+const velocitySamples = 4
+
+// extrapolate continues the latest movement past the last snapshot, for at most MaxExtrapolation.
+// Orientation is held since turning rarely continues at a steady rate.
+func (s *EntityStore) extrapolate(snapshots []entitySnapshot, t time.Time) packet.Position {
+	last := snapshots[len(snapshots)-1]
+	previous := snapshots[max(len(snapshots)-velocitySamples, 0)]
+	interval := last.at.Sub(previous.at)
+	if interval <= 0 {
+		return last.position
+	}
+
+	ahead := min(t.Sub(last.at), s.options.MaxExtrapolation)
+	scale := float32(ahead) / float32(interval)
+	position := last.position
+	position.X += (last.position.X - previous.position.X) * scale
+	position.Y += (last.position.Y - previous.position.Y) * scale
+	position.Z += (last.position.Z - previous.position.Z) * scale
+	return position
+}
+
+// lerpPosition interpolates between two positions, turning the short way around for yaw
+func lerpPosition(from, to packet.Position, fraction float32) packet.Position {
+	lerp := func(a, b float32) float32 { return a + (b-a)*fraction }
+	return packet.Position{
+		X:     lerp(from.X, to.X),
+		Y:     lerp(from.Y, to.Y),
+		Z:     lerp(from.Z, to.Z),
+		Yaw:   from.Yaw + angleDelta(from.Yaw, to.Yaw)*fraction,
+		Pitch: lerp(from.Pitch, to.Pitch),
+	}
+}
+
+// angleDelta returns the signed difference from a to b in degrees, between -180 and 180
+func angleDelta(a, b float32) float32 {
+	delta := float32(math.Mod(float64(b-a), 360))
+	if delta > 180 {
+		delta -= 360
+	} else if delta < -180 {
+		delta += 360
+	}
+	return delta
+}
@@ -0,0 +1,175 @@
+package network
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/leterax/go-voxels/pkg/network/packet"
+)
+
+// packetPosition returns a position at x on the ground
+func packetPosition(x float32) packet.Position {
+	return packet.Position{X: x, Y: 64}
+}
+
+// sameAngle reports whether two angles in degrees point the same way
+func sameAngle(a, b float32) bool {
+	return math.Abs(float64(angleDelta(a, b))) < 1e-3
+}
+
+func TestEntityStoreAt(t *testing.T) {
+	start := time.Unix(1000, 0)
+	ms := func(n int) time.Time { return start.Add(time.Duration(n) * time.Millisecond) }
+
+	store := NewEntityStore(EntityStoreOptions{})
+	store.ApplyAt(EntityAddedEvent{EntityID: 7, X: 0, Y: 64, Yaw: 350, Name: "alice"}, ms(0))
+	store.ApplyAt(EntityMovedEvent{EntityID: 7, X: 10, Y: 64, Yaw: 10, Pitch: 20}, ms(100))
+	store.ApplyAt(EntityMovedEvent{EntityID: 7, X: 20, Y: 64, Yaw: 30, Pitch: 20}, ms(200))
+
+	tests := []struct {
+		name          string
+		at            time.Time
+		x, yaw, pitch float32
+	}{
+		{"before the first update", ms(-50), 0, 350, 0},
+		{"at the first update", ms(0), 0, 350, 0},
+		{"halfway, turning through north", ms(50), 5, 0, 10},
+		{"between later updates", ms(150), 15, 20, 20},
+		{"at the last update", ms(200), 20, 30, 20},
+		{"extrapolated", ms(300), 30, 30, 20},
+		{"extrapolated to the limit", ms(450), 45, 30, 20},
+		{"past the extrapolation limit", ms(5000), 45, 30, 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state, ok := store.At(7, tt.at)
+			if !ok {
+				t.Fatal("entity not found")
+			}
+			if math.Abs(float64(state.X-tt.x)) > 1e-3 || state.Y != 64 || !sameAngle(state.Yaw, tt.yaw) || state.Pitch != tt.pitch {
+				t.Errorf("at x %g, yaw %g, pitch %g, want x %g, yaw %g, pitch %g", state.X, state.Yaw, state.Pitch, tt.x, tt.yaw, tt.pitch)
+			}
+			if state.EntityID != 7 || state.Name != "alice" {
+				t.Errorf("got entity %d named %q", state.EntityID, state.Name)
+			}
+		})
+	}
+}
+
+func TestEntityStoreExtrapolationLimit(t *testing.T) {
+	start := time.Unix(1000, 0)
+	store := NewEntityStore(EntityStoreOptions{MaxExtrapolation: 50 * time.Millisecond})
+	store.Add(1, "", packetPosition(0), start)
+	store.Update(1, packetPosition(1), start.Add(100*time.Millisecond))
+
+	// One block per 100ms, for at most 50ms past the last update
+	for _, after := range []time.Duration{50 * time.Millisecond, time.Second, time.Hour} {
+		state, _ := store.At(1, start.Add(100*time.Millisecond+after))
+		if math.Abs(float64(state.X-1.5)) > 1e-3 {
+			t.Errorf("%v after the last update at x %g, want 1.5", after, state.X)
+		}
+	}
+}
+
+func TestAngleDelta(t *testing.T) {
+	tests := []struct {
+		a, b, want float32
+	}{
+		{0, 90, 90},
+		{350, 10, 20},
+		{10, 350, -20},
+		{-170, 170, -20},
+		{170, -170, 20},
+		{90, 450, 0},
+		{-720, 30, 30},
+		{0, 180, 180},
+		{0, 540, 180},
+		{0, -180, -180},
+	}
+	for _, tt := range tests {
+		if got := angleDelta(tt.a, tt.b); math.Abs(float64(got-tt.want)) > 1e-3 {
+			t.Errorf("angleDelta(%g, %g) = %g, want %g", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestEntityStoreHistory(t *testing.T) {
+	start := time.Unix(1000, 0)
+	second := func(n int) time.Time { return start.Add(time.Duration(n) * time.Second) }
+	store := NewEntityStore(EntityStoreOptions{History: 3})
+	store.Add(1, "", packetPosition(0), second(0))
+	for i := 1; i <= 5; i++ {
+		store.Update(1, packetPosition(float32(i)), second(i))
+	}
+
+	snapshots := store.entities[1].snapshots
+	if len(snapshots) != 3 {
+		t.Fatalf("%d snapshots kept, want 3", len(snapshots))
+	}
+	for i, snapshot := range snapshots {
+		if want := float32(i + 3); snapshot.position.X != want || !snapshot.at.Equal(second(i+3)) {
+			t.Errorf("snapshot %d at x %g, want the update at x %g", i, snapshot.position.X, want)
+		}
+	}
+	// Older times than the history covers get the oldest snapshot kept
+	if state, _ := store.At(1, second(1)); state.X != 3 {
+		t.Errorf("at x %g, want 3", state.X)
+	}
+}
+
+func TestEntityStoreClampsTimestamps(t *testing.T) {
+	start := time.Unix(1000, 0)
+	store := NewEntityStore(EntityStoreOptions{})
+	store.Add(1, "", packetPosition(0), start)
+	store.Update(1, packetPosition(10), start.Add(100*time.Millisecond))
+	// The clock went backwards, so the update is taken as arriving with the latest one
+	store.Update(1, packetPosition(20), start.Add(50*time.Millisecond))
+
+	snapshots := store.entities[1].snapshots
+	for i := 1; i < len(snapshots); i++ {
+		if snapshots[i].at.Before(snapshots[i-1].at) {
+			t.Fatalf("snapshot %d is older than the one before it", i)
+		}
+	}
+	if last := snapshots[len(snapshots)-1]; !last.at.Equal(start.Add(100*time.Millisecond)) || last.position.X != 20 {
+		t.Errorf("last snapshot at %v, x %g, want the new position at the previous time", last.at.Sub(start), last.position.X)
+	}
+	if state, _ := store.At(1, start.Add(50*time.Millisecond)); state.X != 5 {
+		t.Errorf("at x %g halfway to the clamped update, want 5", state.X)
+	}
+}
+
+func TestEntityStoreEvents(t *testing.T) {
+	now := time.Unix(1000, 0)
+	store := NewEntityStore(EntityStoreOptions{})
+	store.ApplyAt(EntityAddedEvent{EntityID: 2, Name: "bob"}, now)
+	store.ApplyAt(EntityAddedEvent{EntityID: 1, Name: "alice"}, now)
+	store.ApplyAt(EntityMovedEvent{EntityID: 99, X: 5}, now) // Not tracked, like the client itself
+	store.ApplyAt(EntityMetadataEvent{EntityID: 2, Name: "robert"}, now)
+	store.ApplyAt(ChatEvent{Message: "hi"}, now)
+
+	all := store.All(now)
+	if len(all) != 2 || all[0].Name != "alice" || all[1].Name != "robert" {
+		t.Fatalf("got %+v, want alice and robert ordered by ID", all)
+	}
+	if _, ok := store.At(99, now); ok {
+		t.Error("moving an unknown entity added it")
+	}
+
+	// Adding again starts a new history
+	store.ApplyAt(EntityMovedEvent{EntityID: 1, X: 10}, now.Add(time.Second))
+	store.ApplyAt(EntityAddedEvent{EntityID: 1, X: -4, Name: "alice"}, now.Add(2*time.Second))
+	if state, _ := store.At(1, now); state.X != -4 {
+		t.Errorf("at x %g, want the position it was added again at", state.X)
+	}
+
+	store.ApplyAt(EntityRemovedEvent{EntityID: 2}, now)
+	if store.Len() != 1 {
+		t.Errorf("%d entities after removing one, want 1", store.Len())
+	}
+	store.Clear()
+	if store.Len() != 0 {
+		t.Errorf("%d entities after Clear", store.Len())
+	}
+}
@@ -0,0 +1,260 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/leterax/go-voxels/pkg/voxel"
+)
+
+// Terminal errors reported by Run and Subscription.Err
+var (
+	ErrConnectionClosed = errors.New("connection closed by server")
+	ErrClientClosed     = errors.New("client closed")
+	ErrAlreadyRunning   = errors.New("client is already running")
+)
+
+// Event is something received from the server, delivered to subscriptions
+type Event interface {
+	event()
+}
+
+// IdentifiedEvent tells the client its own entity ID
+type IdentifiedEvent struct {
+	EntityID uint32
+}
+
+// EntityAddedEvent announces an entity entering the world
+type EntityAddedEvent struct {
+	EntityID   uint32
+	X, Y, Z    float32
+	Yaw, Pitch float32
+	Name       string
+}
+
+// EntityRemovedEvent announces an entity leaving the world
+type EntityRemovedEvent struct {
+	EntityID uint32
+}
+
+// EntityMovedEvent carries a new entity position
+type EntityMovedEvent struct {
+	EntityID   uint32
+	X, Y, Z    float32
+	Yaw, Pitch float32
+}
+
+// ChunkEvent carries the full contents of a chunk.
+// Props is nil unless the server sent block states.
+// Blocks is a copy shared with every other subscriber, never reused by the client.
+type ChunkEvent struct {
+	X, Y, Z int32
+	Blocks  []voxel.BlockType
+	Props   []uint8
+}
+
+// MonoChunkEvent describes a chunk made of a single block type
+type MonoChunkEvent struct {
+	X, Y, Z   int32
+	BlockType voxel.BlockType
+}
+
+// BlocksChangedEvent carries blocks changed within a single chunk the client already holds.
+// The server sends it instead of the whole chunk after small edits.
+type BlocksChangedEvent struct {
+	Chunk   voxel.ChunkCoord
+	Changes []BlockChange
+}
+
+// ChunkUnloadedEvent tells the client a chunk left its render distance.
+// The server sends no more updates for it until it is streamed again.
+type ChunkUnloadedEvent struct {
+	X, Y, Z int32
+}
+
+// ChatEvent carries a chat message
+type ChatEvent struct {
+	Message string
+}
+
+// EntityMetadataEvent renames an entity
+type EntityMetadataEvent struct {
+	EntityID uint32
+	Name     string
+}
+
+func (IdentifiedEvent) event()     {}
+func (EntityAddedEvent) event()    {}
+func (EntityRemovedEvent) event()  {}
+func (EntityMovedEvent) event()    {}
+func (ChunkEvent) event()          {}
+func (MonoChunkEvent) event()      {}
+func (BlocksChangedEvent) event()  {}
+func (ChunkUnloadedEvent) event()  {}
+func (ChatEvent) event()           {}
+func (EntityMetadataEvent) event() {}
+
+// Backpressure decides what happens when a subscriber falls behind
+type Backpressure uint8
+
+const (
+	// BackpressureBlock makes the reader wait for the subscriber, stalling every other consumer
+	BackpressureBlock Backpressure = iota
+	// BackpressureDropNewest discards events that don't fit in the buffer
+	BackpressureDropNewest
+	// BackpressureDropOldest discards the oldest buffered event to make room
+	BackpressureDropOldest
+)
+
+// DefaultEventBuffer is the subscription buffer size used when none is given
+const DefaultEventBuffer = 256
+
+// SubscribeOptions configures a subscription
+type SubscribeOptions struct {
+	Buffer       int          // Channel capacity, DefaultEventBuffer if zero
+	Backpressure Backpressure // Policy once the buffer is full
+}
+
+// Subscription delivers events from a running client
+type Subscription struct {
+	hub     *eventHub
+	events  chan Event
+	policy  Backpressure
+	done    chan struct{}
+	stop    sync.Once
+	dropped atomic.Uint64
+}
+
+// Events returns the channel of received events.
+// It is closed once the client stops, after which Err reports why.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Dropped returns how many events were discarded by the backpressure policy
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Err returns the client's terminal error, or nil while it is still running
+func (s *Subscription) Err() error {
+	return s.hub.Err()
+}
+
+// Close stops delivery to this subscription. Events is not closed by it.
+func (s *Subscription) Close() {
+	s.stop.Do(func() {
+		close(s.done)
+		s.hub.unsubscribe(s)
+	})
+}
+
+func (s *Subscription) deliver(ctx context.Context, ev Event) {
+	switch s.policy {
+	case BackpressureDropNewest:
+		select {
+		case s.events <- ev:
+		default:
+			s.dropped.Add(1)
+		}
+	case BackpressureDropOldest:
+		for {
+			select {
+			case s.events <- ev:
+				return
+			default:
+			}
+			// Make room, unless the consumer just did
+			select {
+			case <-s.events:
+				s.dropped.Add(1)
+			default:
+			}
+		}
+	default:
+		select {
+		case s.events <- ev:
+		case <-s.done:
+		case <-ctx.Done():
+		}
+	}
+}
+
+// eventHub fans events out to subscriptions and records the terminal error
+type eventHub struct {
+	mu            sync.Mutex
+	subscriptions []*Subscription
+	finished      bool
+	err           error
+}
+
+// Subscribe returns a subscription receiving every event from now on
+func (h *eventHub) Subscribe(options SubscribeOptions) *Subscription {
+	if options.Buffer <= 0 {
+		options.Buffer = DefaultEventBuffer
+	}
+	sub := &Subscription{
+		hub:    h,
+		events: make(chan Event, options.Buffer),
+		policy: options.Backpressure,
+		done:   make(chan struct{}),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.finished {
+		close(sub.events)
+		return sub
+	}
+	h.subscriptions = append(h.subscriptions, sub)
+	return sub
+}
+
+func (h *eventHub) unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	// Copy so a publish in progress keeps iterating over its own snapshot
+	h.subscriptions = slices.DeleteFunc(slices.Clone(h.subscriptions), func(s *Subscription) bool {
+		return s == sub
+	})
+}
+
+// subscribed reports whether any subscription would receive a published event
+func (h *eventHub) subscribed() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subscriptions) > 0
+}
+
+// publish delivers an event to every subscription according to its backpressure policy
+func (h *eventHub) publish(ctx context.Context, ev Event) {
+	h.mu.Lock()
+	subscriptions := h.subscriptions
+	h.mu.Unlock()
+
+	for _, sub := range subscriptions {
+		sub.deliver(ctx, ev)
+	}
+}
+
+// finish records the terminal error and closes every subscription's channel
+func (h *eventHub) finish(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.err = err
+	h.finished = true
+	for _, sub := range h.subscriptions {
+		close(sub.events)
+	}
+	h.subscriptions = nil
+}
+
+// Err returns the terminal error once the client has stopped, and nil before
+func (h *eventHub) Err() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.err
+}
@@ -0,0 +1,207 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/leterax/go-voxels/pkg/network/packet"
+)
+
+// MaxQueuedPackets bounds the packets waiting for the writer goroutine.
+// Batched block updates don't count towards it until another packet is sent after them.
+const MaxQueuedPackets = 1024
+
+// MaxQueuedBlocks bounds the block updates batched while waiting for the writer goroutine
+const MaxQueuedBlocks = 4 * packet.MaxBulkEdit
+
+// ErrSendQueueFull is returned when the writer goroutine can't keep up with the sender
+var ErrSendQueueFull = errors.New("send queue full")
+
+// outbox serializes everything sent to the server through a single writer goroutine.
+// Consecutive position updates coalesce to the latest one and consecutive block updates
+// are merged into a single Block Bulk Edit. Packets are written in the order they were sent.
+type outbox struct {
+	mu      sync.Mutex
+	cond    *sync.Cond // Signalled after every write and when the outbox stops
+	queue   []packet.Packet
+	blocks  []BlockUpdate // Block updates sent after everything in queue
+	pending bool          // The writer is busy with packets taken from the outbox
+	err     error
+	framed  bool // Set before the writer starts, prefix packets with their length
+
+	// sent is called with every packet written and its encoded size, if set before the writer starts
+	sent func(p packet.Packet, size int, now time.Time)
+
+	notify chan struct{}
+}
+
+func newOutbox() *outbox {
+	o := &outbox{notify: make(chan struct{}, 1)}
+	o.cond = sync.NewCond(&o.mu)
+	return o
+}
+
+// enqueue adds a packet behind everything queued so far
+func (o *outbox) enqueue(p packet.Packet) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.err != nil {
+		return o.err
+	}
+	if len(o.queue) >= MaxQueuedPackets {
+		return ErrSendQueueFull
+	}
+
+	// Keep block updates ordered relative to this packet
+	o.flushBlocks()
+	o.queue = append(o.queue, p)
+	o.wake()
+	return nil
+}
+
+// setPosition queues a position update, replacing the last queued packet if it is one too
+func (o *outbox) setPosition(p *packet.UpdateEntity) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.err != nil {
+		return o.err
+	}
+	if n := len(o.queue); n > 0 && len(o.blocks) == 0 {
+		if _, ok := o.queue[n-1].(*packet.UpdateEntity); ok {
+			o.queue[n-1] = p
+			o.wake()
+			return nil
+		}
+	}
+	if len(o.queue) >= MaxQueuedPackets {
+		return ErrSendQueueFull
+	}
+
+	o.flushBlocks()
+	o.queue = append(o.queue, p)
+	o.wake()
+	return nil
+}
+
+// addBlocks queues block updates to be merged with others sent before the next write
+func (o *outbox) addBlocks(updates ...BlockUpdate) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.err != nil {
+		return o.err
+	}
+	if len(o.blocks)+len(updates) > MaxQueuedBlocks {
+		return ErrSendQueueFull
+	}
+	o.blocks = append(o.blocks, updates...)
+	o.wake()
+	return nil
+}
+
+// flushBlocks moves pending block updates into the queue. The caller must hold o.mu.
+func (o *outbox) flushBlocks() {
+	blocks := o.blocks
+	o.blocks = nil
+	if len(blocks) == 1 {
+		update := blocks[0]
+		o.queue = append(o.queue, &packet.UpdateBlock{BlockType: update.BlockType, X: update.X, Y: update.Y, Z: update.Z})
+		return
+	}
+	for len(blocks) > 0 {
+		n := min(len(blocks), packet.MaxBulkEdit)
+		o.queue = append(o.queue, &packet.BlockBulkEdit{Updates: blocks[:n]})
+		blocks = blocks[n:]
+	}
+}
+
+// wake notifies the writer goroutine. The caller must hold o.mu.
+func (o *outbox) wake() {
+	select {
+	case o.notify <- struct{}{}:
+	default:
+	}
+}
+
+// take removes everything waiting to be written, in send order
+func (o *outbox) take() []packet.Packet {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.flushBlocks()
+	packets := o.queue
+	o.queue = nil
+	o.pending = len(packets) > 0
+	return packets
+}
+
+// run writes queued packets to w until done is closed or a write fails
+func (o *outbox) run(w io.Writer, done <-chan struct{}) {
+	var buf []byte
+	var sizes []int
+	for {
+		select {
+		case <-done:
+			o.stop(ErrClientClosed)
+			return
+		case <-o.notify:
+		}
+
+		packets := o.take()
+		if len(packets) == 0 {
+			continue
+		}
+
+		// Encode everything taken into one write
+		buf = buf[:0]
+		sizes = sizes[:0]
+		for _, p := range packets {
+			start := len(buf)
+			if o.framed {
+				buf = packet.AppendFramed(buf, p)
+			} else {
+				buf = append(buf, p.ID())
+				buf = p.AppendPayload(buf)
+			}
+			sizes = append(sizes, len(buf)-start)
+		}
+		if _, err := w.Write(buf); err != nil {
+			o.stop(fmt.Errorf("failed to write: %w", err))
+			return
+		}
+		if o.sent != nil {
+			now := time.Now()
+			for i, p := range packets {
+				o.sent(p, sizes[i], now)
+			}
+		}
+
+		o.mu.Lock()
+		o.pending = false
+		o.cond.Broadcast()
+		o.mu.Unlock()
+	}
+}
+
+// stop makes every later send fail with err and wakes flushers
+func (o *outbox) stop(err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.err == nil {
+		o.err = err
+	}
+	o.queue, o.blocks = nil, nil
+	o.cond.Broadcast()
+}
+
+// flush waits until everything sent so far has been written
+func (o *outbox) flush() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for o.err == nil && (o.pending || len(o.queue) > 0 || len(o.blocks) > 0) {
+		o.cond.Wait()
+	}
+	return o.err
+}
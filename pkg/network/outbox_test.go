@@ -0,0 +1,60 @@
+package network
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/leterax/go-voxels/pkg/network/packet"
+	"github.com/leterax/go-voxels/pkg/voxel"
+)
+
+func TestOutboxKeepsSendOrder(t *testing.T) {
+	position := func(x float32) *packet.UpdateEntity {
+		return &packet.UpdateEntity{Position: packet.Position{X: x}}
+	}
+	stone := BlockUpdate{BlockType: voxel.Stone, X: 1}
+	dirt := BlockUpdate{BlockType: voxel.Dirt, X: 2}
+	chat := &packet.ChatMessage{Message: "hi"}
+
+	o := newOutbox()
+	o.setPosition(position(1))
+	o.enqueue(chat)
+	o.setPosition(position(2))
+	o.setPosition(position(3))
+	o.addBlocks(stone)
+	o.addBlocks(dirt)
+	o.setPosition(position(4))
+	o.addBlocks(stone)
+
+	want := []packet.Packet{
+		position(1),
+		chat,
+		position(3), // Coalesced with the update right before it
+		&packet.BlockBulkEdit{Updates: []BlockUpdate{stone, dirt}},
+		position(4),
+		&packet.UpdateBlock{BlockType: stone.BlockType, X: stone.X},
+	}
+	if got := o.take(); !reflect.DeepEqual(got, want) {
+		t.Errorf("took %v, want %v", got, want)
+	}
+}
+
+func TestOutboxBoundsBlockBatch(t *testing.T) {
+	o := newOutbox()
+	if err := o.addBlocks(make([]BlockUpdate, MaxQueuedBlocks)...); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.addBlocks(BlockUpdate{}); !errors.Is(err, ErrSendQueueFull) {
+		t.Errorf("got %v, want ErrSendQueueFull", err)
+	}
+
+	// The writer taking the batch makes room again
+	packets := o.take()
+	if len(packets) != MaxQueuedBlocks/packet.MaxBulkEdit {
+		t.Errorf("batch split into %d packets, want %d", len(packets), MaxQueuedBlocks/packet.MaxBulkEdit)
+	}
+	if err := o.addBlocks(BlockUpdate{}); err != nil {
+		t.Errorf("after the writer caught up: %v", err)
+	}
+}
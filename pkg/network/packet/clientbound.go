@@ -0,0 +1,270 @@
+package packet
+
+import (
+	"encoding/binary"
+
+	"github.com/leterax/go-voxels/pkg/voxel"
+)
+
+// ClientBound packet IDs
+const (
+	IDIdentification       uint8 = 0x00
+	IDAddEntity            uint8 = 0x01
+	IDRemoveEntity         uint8 = 0x02
+	IDUpdateEntityPosition uint8 = 0x03
+	IDSendChunk            uint8 = 0x04
+	IDSendMonoTypeChunk    uint8 = 0x05
+	IDChat                 uint8 = 0x06
+	IDUpdateEntityMetadata uint8 = 0x07
+	IDSendChunkStates      uint8 = 0x08
+)
+
+// Identification tells a client its own entity ID
+type Identification struct {
+	EntityID uint32
+}
+
+func (p *Identification) ID() uint8 { return IDIdentification }
+
+func (p *Identification) AppendPayload(buf []byte) []byte {
+	return binary.BigEndian.AppendUint32(buf, p.EntityID)
+}
+
+func (p *Identification) DecodePayload(d *Decoder) error {
+	buf, err := d.bytes(4)
+	if err != nil {
+		return err
+	}
+	p.EntityID = binary.BigEndian.Uint32(buf)
+	return nil
+}
+
+// AddEntity announces an entity entering the world
+type AddEntity struct {
+	EntityID uint32
+	Position Position
+	Name     string
+}
+
+func (p *AddEntity) ID() uint8 { return IDAddEntity }
+
+func (p *AddEntity) AppendPayload(buf []byte) []byte {
+	buf = binary.BigEndian.AppendUint32(buf, p.EntityID)
+	buf = appendPosition(buf, p.Position)
+	return appendString(buf, p.Name, NameLength)
+}
+
+func (p *AddEntity) DecodePayload(d *Decoder) error {
+	buf, err := d.bytes(4 + positionSize + NameLength)
+	if err != nil {
+		return err
+	}
+	p.EntityID = binary.BigEndian.Uint32(buf)
+	p.Position = getPosition(buf[4:])
+	if err := checkPosition(p.Position); err != nil {
+		return err
+	}
+	p.Name, err = getText(buf[4+positionSize:])
+	return err
+}
+
+// RemoveEntity announces an entity leaving the world
+type RemoveEntity struct {
+	EntityID uint32
+}
+
+func (p *RemoveEntity) ID() uint8 { return IDRemoveEntity }
+
+func (p *RemoveEntity) AppendPayload(buf []byte) []byte {
+	return binary.BigEndian.AppendUint32(buf, p.EntityID)
+}
+
+func (p *RemoveEntity) DecodePayload(d *Decoder) error {
+	buf, err := d.bytes(4)
+	if err != nil {
+		return err
+	}
+	p.EntityID = binary.BigEndian.Uint32(buf)
+	return nil
+}
+
+// UpdateEntityPosition moves an entity
+type UpdateEntityPosition struct {
+	EntityID uint32
+	Position Position
+}
+
+func (p *UpdateEntityPosition) ID() uint8 { return IDUpdateEntityPosition }
+
+func (p *UpdateEntityPosition) AppendPayload(buf []byte) []byte {
+	buf = binary.BigEndian.AppendUint32(buf, p.EntityID)
+	return appendPosition(buf, p.Position)
+}
+
+func (p *UpdateEntityPosition) DecodePayload(d *Decoder) error {
+	buf, err := d.bytes(4 + positionSize)
+	if err != nil {
+		return err
+	}
+	p.EntityID = binary.BigEndian.Uint32(buf)
+	p.Position = getPosition(buf[4:])
+	return checkPosition(p.Position)
+}
+
+// SendChunk carries every block of a chunk.
+// Blocks is padded with Air or truncated to ChunkVolume entries when encoding.
+// Decoded blocks come from a pool and may be handed back with ReleaseBlocks.
+type SendChunk struct {
+	X, Y, Z int32
+	Blocks  []voxel.BlockType
+}
+
+func (p *SendChunk) ID() uint8 { return IDSendChunk }
+
+func (p *SendChunk) AppendPayload(buf []byte) []byte {
+	buf = appendCoords(buf, p.X, p.Y, p.Z)
+	return appendBlocks(buf, p.Blocks)
+}
+
+func (p *SendChunk) DecodePayload(d *Decoder) error {
+	buf, err := d.bytes(12 + ChunkVolume)
+	if err != nil {
+		return err
+	}
+	p.X, p.Y, p.Z = getCoords(buf)
+	if err := checkChunkCoords(p.X, p.Y, p.Z); err != nil {
+		return err
+	}
+	p.Blocks = getBlocks(buf[12:])
+	if err := checkChunkStates(p.Blocks, nil); err != nil {
+		ReleaseBlocks(p.Blocks)
+		p.Blocks = nil
+		return err
+	}
+	return nil
+}
+
+// SendMonoTypeChunk describes a chunk made of a single block type
+type SendMonoTypeChunk struct {
+	X, Y, Z   int32
+	BlockType voxel.BlockType
+}
+
+func (p *SendMonoTypeChunk) ID() uint8 { return IDSendMonoTypeChunk }
+
+func (p *SendMonoTypeChunk) AppendPayload(buf []byte) []byte {
+	buf = appendCoords(buf, p.X, p.Y, p.Z)
+	return append(buf, uint8(p.BlockType))
+}
+
+func (p *SendMonoTypeChunk) DecodePayload(d *Decoder) error {
+	buf, err := d.bytes(12 + 1)
+	if err != nil {
+		return err
+	}
+	p.X, p.Y, p.Z = getCoords(buf)
+	if err := checkChunkCoords(p.X, p.Y, p.Z); err != nil {
+		return err
+	}
+	p.BlockType = voxel.BlockType(buf[12])
+	return checkBlockType(p.BlockType)
+}
+
+// Chat delivers a chat message
+type Chat struct {
+	Message string
+}
+
+func (p *Chat) ID() uint8 { return IDChat }
+
+func (p *Chat) AppendPayload(buf []byte) []byte {
+	return appendString(buf, p.Message, MessageLength)
+}
+
+func (p *Chat) DecodePayload(d *Decoder) error {
+	buf, err := d.bytes(MessageLength)
+	if err != nil {
+		return err
+	}
+	p.Message, err = getText(buf)
+	return err
+}
+
+// UpdateEntityMetadata renames an entity
+type UpdateEntityMetadata struct {
+	EntityID uint32
+	Name     string
+}
+
+func (p *UpdateEntityMetadata) ID() uint8 { return IDUpdateEntityMetadata }
+
+func (p *UpdateEntityMetadata) AppendPayload(buf []byte) []byte {
+	buf = binary.BigEndian.AppendUint32(buf, p.EntityID)
+	return appendString(buf, p.Name, NameLength)
+}
+
+func (p *UpdateEntityMetadata) DecodePayload(d *Decoder) error {
+	buf, err := d.bytes(4 + NameLength)
+	if err != nil {
+		return err
+	}
+	p.EntityID = binary.BigEndian.Uint32(buf)
+	p.Name, err = getText(buf[4:])
+	return err
+}
+
+// SendChunkStates carries every block of a chunk along with its properties byte.
+// Blocks and Props are padded or truncated to ChunkVolume entries when encoding.
+// Decoded blocks come from a pool and may be handed back with ReleaseBlocks.
+type SendChunkStates struct {
+	X, Y, Z int32
+	Blocks  []voxel.BlockType
+	Props   []uint8
+}
+
+func (p *SendChunkStates) ID() uint8 { return IDSendChunkStates }
+
+func (p *SendChunkStates) AppendPayload(buf []byte) []byte {
+	buf = appendCoords(buf, p.X, p.Y, p.Z)
+	buf = appendBlocks(buf, p.Blocks)
+	return appendFixed(buf, p.Props, ChunkVolume)
+}
+
+func (p *SendChunkStates) DecodePayload(d *Decoder) error {
+	buf, err := d.bytes(12 + 2*ChunkVolume)
+	if err != nil {
+		return err
+	}
+	p.X, p.Y, p.Z = getCoords(buf)
+	if err := checkChunkCoords(p.X, p.Y, p.Z); err != nil {
+		return err
+	}
+	p.Blocks = getBlocks(buf[12:])
+	p.Props = append([]uint8(nil), buf[12+ChunkVolume:]...)
+	if err := checkChunkStates(p.Blocks, p.Props); err != nil {
+		ReleaseBlocks(p.Blocks)
+		p.Blocks, p.Props = nil, nil
+		return err
+	}
+	return nil
+}
+
+// appendBlocks appends exactly ChunkVolume block types
+func appendBlocks(buf []byte, blocks []voxel.BlockType) []byte {
+	if len(blocks) > ChunkVolume {
+		blocks = blocks[:ChunkVolume]
+	}
+	for _, blockType := range blocks {
+		buf = append(buf, uint8(blockType))
+	}
+	return append(buf, make([]byte, ChunkVolume-len(blocks))...)
+}
+
+// getBlocks converts ChunkVolume bytes into a pooled block type slice
+func getBlocks(buf []byte) []voxel.BlockType {
+	blocks := blockPool.Get().(*[ChunkVolume]voxel.BlockType)
+	for i := range blocks {
+		blocks[i] = voxel.BlockType(buf[i])
+	}
+	return blocks[:]
+}
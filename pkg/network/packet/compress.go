@@ -0,0 +1,161 @@
+package packet
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/leterax/go-voxels/pkg/voxel"
+)
+
+// Chunk compression encodings
+const (
+	EncodingRLE     uint8 = 0
+	EncodingDeflate uint8 = 1
+)
+
+// maxCompressedChunk bounds the data of a Send Compressed Chunk. RLE of the worst case
+// (every byte different) takes three bytes per block and property.
+const maxCompressedChunk = 3 * 2 * ChunkVolume
+
+// ErrInvalidCompressedChunk is returned when compressed chunk data doesn't decode to a full chunk
+var ErrInvalidCompressedChunk = errors.New("invalid compressed chunk data")
+
+// encodingCapability returns the capability required to receive an encoding
+func encodingCapability(encoding uint8) uint32 {
+	if encoding == EncodingDeflate {
+		return CapabilityChunkDeflate
+	}
+	return CapabilityChunkRLE
+}
+
+// ChunkData lays out blocks followed by props, if any, as sent uncompressed
+func ChunkData(blocks []voxel.BlockType, props []uint8) []byte {
+	raw := appendBlocks(make([]byte, 0, 2*ChunkVolume), blocks)
+	if props != nil {
+		raw = appendFixed(raw, props, ChunkVolume)
+	}
+	return raw
+}
+
+// CompressChunk builds a Send Compressed Chunk with the smallest encoding allowed by capabilities.
+// It returns nil if no encoding is allowed or none beats the uncompressed packet.
+func CompressChunk(x, y, z int32, blocks []voxel.BlockType, props []uint8, capabilities uint32) *SendCompressedChunk {
+	raw := ChunkData(blocks, props)
+
+	var best *SendCompressedChunk
+	for _, encoding := range []uint8{EncodingRLE, EncodingDeflate} {
+		if capabilities&encodingCapability(encoding) == 0 {
+			continue
+		}
+		data := Compress(raw, encoding)
+		if best == nil || len(data) < len(best.Data) {
+			best = &SendCompressedChunk{X: x, Y: y, Z: z, Encoding: encoding, HasProps: props != nil, Data: data}
+		}
+	}
+
+	// The compressed packet carries 6 more header bytes than Send Chunk
+	if best == nil || len(best.Data)+6 >= len(raw) {
+		return nil
+	}
+	return best
+}
+
+// Compress encodes chunk data from ChunkData with the given encoding
+func Compress(raw []byte, encoding uint8) []byte {
+	if encoding == EncodingDeflate {
+		var buf bytes.Buffer
+		w, _ := flate.NewWriter(&buf, flate.BestSpeed)
+		w.Write(raw)
+		w.Close()
+		return buf.Bytes()
+	}
+	return compressRLE(raw)
+}
+
+// compressRLE encodes runs as count(U16) + value(U8)
+func compressRLE(raw []byte) []byte {
+	var out []byte
+	for i := 0; i < len(raw); {
+		value := raw[i]
+		run := 1
+		for i+run < len(raw) && raw[i+run] == value && run < 0xFFFF {
+			run++
+		}
+		out = binary.BigEndian.AppendUint16(out, uint16(run))
+		out = append(out, value)
+		i += run
+	}
+	return out
+}
+
+// decompress decodes exactly size bytes of chunk data
+func decompress(data []byte, encoding uint8, size int) ([]byte, error) {
+	switch encoding {
+	case EncodingRLE:
+		return decompressRLE(data, size)
+	case EncodingDeflate:
+		raw := make([]byte, size)
+		r := flate.NewReader(bytes.NewReader(data))
+		defer r.Close()
+		if _, err := io.ReadFull(r, raw); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidCompressedChunk, err)
+		}
+		// Anything after the chunk means the sender and receiver disagree on the layout
+		if n, _ := r.Read(make([]byte, 1)); n != 0 {
+			return nil, fmt.Errorf("%w: trailing data", ErrInvalidCompressedChunk)
+		}
+		return raw, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown encoding %d", ErrInvalidCompressedChunk, encoding)
+	}
+}
+
+func decompressRLE(data []byte, size int) ([]byte, error) {
+	if len(data)%3 != 0 {
+		return nil, fmt.Errorf("%w: truncated run", ErrInvalidCompressedChunk)
+	}
+	raw := make([]byte, 0, size)
+	for i := 0; i < len(data); i += 3 {
+		run := int(binary.BigEndian.Uint16(data[i:]))
+		if run == 0 || len(raw)+run > size {
+			return nil, fmt.Errorf("%w: bad run length", ErrInvalidCompressedChunk)
+		}
+		value := data[i+2]
+		for range run {
+			raw = append(raw, value)
+		}
+	}
+	if len(raw) != size {
+		return nil, fmt.Errorf("%w: %d of %d bytes", ErrInvalidCompressedChunk, len(raw), size)
+	}
+	return raw, nil
+}
+
+// Decompress returns the chunk's blocks and, if it has them, its properties.
+// The blocks come from the same pool as decoded Send Chunk packets.
+// Invalid blocks fail with the same errors as decoding Send Chunk States.
+func (p *SendCompressedChunk) Decompress() ([]voxel.BlockType, []uint8, error) {
+	size := ChunkVolume
+	if p.HasProps {
+		size *= 2
+	}
+	raw, err := decompress(p.Data, p.Encoding, size)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	blocks := getBlocks(raw)
+	var props []uint8
+	if p.HasProps {
+		props = raw[ChunkVolume:]
+	}
+	if err := checkChunkStates(blocks, props); err != nil {
+		ReleaseBlocks(blocks)
+		return nil, nil, err
+	}
+	return blocks, props, nil
+}
@@ -0,0 +1,227 @@
+package packet
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/leterax/go-voxels/pkg/voxel"
+)
+
+// readBufferSize fits the largest fixed-size packet, so most payloads are decoded in place
+const readBufferSize = 16 << 10
+
+// blockPool recycles the block slices of decoded chunk packets
+var blockPool = sync.Pool{
+	New: func() any { return new([ChunkVolume]voxel.BlockType) },
+}
+
+// ReleaseBlocks hands the blocks of a decoded chunk packet back for reuse.
+// The slice must not be used afterwards. Slices of any other length are ignored.
+func ReleaseBlocks(blocks []voxel.BlockType) {
+	if len(blocks) != ChunkVolume {
+		return
+	}
+	blockPool.Put((*[ChunkVolume]voxel.BlockType)(blocks))
+}
+
+// ErrUnknownPacket is returned when an unframed stream carries a packet ID missing from the registry
+var ErrUnknownPacket = errors.New("unknown packet ID")
+
+// Decoder reads packets from a stream, reusing its buffers between packets
+type Decoder struct {
+	r        io.Reader
+	buffered *bufio.Reader // Same reader as r if buffered, nil otherwise
+	registry *Registry
+	scratch  []byte
+
+	framed    bool // Packets are prefixed with their length
+	inFrame   bool // Payload reads are limited to remaining
+	remaining int
+
+	size       int           // Bytes consumed by the current packet
+	decodeTime time.Duration // Of the last packet, from its header to the end of its payload
+}
+
+// NewDecoder creates a buffered decoder for packets from the registry
+func NewDecoder(r io.Reader, registry *Registry) *Decoder {
+	buffered, ok := r.(*bufio.Reader)
+	if !ok || buffered.Size() < readBufferSize {
+		buffered = bufio.NewReaderSize(r, readBufferSize)
+	}
+	return &Decoder{r: buffered, buffered: buffered, registry: registry}
+}
+
+// Read decodes a single packet from r without buffering, so nothing past the packet is consumed.
+// It returns io.EOF unwrapped if the stream ends cleanly before the ID.
+func Read(r io.Reader, registry *Registry) (Packet, error) {
+	d := &Decoder{r: r, registry: registry}
+	return d.Next()
+}
+
+// SetFramed switches to length-prefixed packets, as announced by the sender's Hello
+func (d *Decoder) SetFramed(framed bool) {
+	d.framed = framed
+}
+
+// Next reads a packet ID and decodes the matching packet.
+// It returns io.EOF unwrapped if the stream ends cleanly between packets, before the ID or frame header.
+func (d *Decoder) Next() (Packet, error) {
+	if d.framed {
+		return d.nextFramed()
+	}
+
+	d.size = 0
+	id, err := d.bytes(1)
+	if err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("failed to read packet ID: %w", err)
+	}
+
+	p, ok := d.registry.New(id[0])
+	if !ok {
+		return nil, fmt.Errorf("%w: %s 0x%02x", ErrUnknownPacket, d.registry.Direction, id[0])
+	}
+	start := time.Now()
+	if err := p.DecodePayload(d); err != nil {
+		return nil, fmt.Errorf("failed to read %T: %w", p, err)
+	}
+	d.decodeTime = time.Since(start)
+	return p, nil
+}
+
+// nextFramed decodes the next length-prefixed packet, skipping packets with unknown IDs
+// and trailing fields added by newer protocol versions
+func (d *Decoder) nextFramed() (Packet, error) {
+	for {
+		d.size = 0
+		// The stream only ends cleanly before the first byte of the header
+		var header [4 + 1]byte
+		first, err := d.bytes(1)
+		if err != nil {
+			if err == io.ErrUnexpectedEOF {
+				return nil, io.EOF
+			}
+			return nil, fmt.Errorf("failed to read packet header: %w", err)
+		}
+		header[0] = first[0]
+		rest, err := d.bytes(len(header) - 1)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read packet header: %w", err)
+		}
+		copy(header[1:], rest)
+		length := binary.BigEndian.Uint32(header[:4])
+		id := header[4]
+		if length == 0 || length > MaxFrameLength {
+			return nil, fmt.Errorf("%w: frame of %d bytes for packet ID 0x%02x", ErrInvalidLength, length, id)
+		}
+
+		p, ok := d.registry.New(id)
+		if !ok {
+			if err := d.skip(int(length) - 1); err != nil {
+				return nil, fmt.Errorf("failed to skip unknown packet ID 0x%02x: %w", id, err)
+			}
+			continue
+		}
+
+		start := time.Now()
+		d.inFrame, d.remaining = true, int(length)-1
+		err = p.DecodePayload(d)
+		d.inFrame = false
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %T: %w", p, err)
+		}
+		if err := d.skip(d.remaining); err != nil {
+			return nil, fmt.Errorf("failed to read %T: %w", p, err)
+		}
+		d.decodeTime = time.Since(start)
+		return p, nil
+	}
+}
+
+// Size returns the encoded length of the packet last returned by Next, including its length prefix.
+// Unknown packets skipped before it aren't included.
+func (d *Decoder) Size() int {
+	return d.size
+}
+
+// DecodeTime returns how long decoding the payload of the packet last returned by Next took,
+// including waiting for payload bytes still in transit
+func (d *Decoder) DecodeTime() time.Duration {
+	return d.decodeTime
+}
+
+// skip discards the next n bytes of the stream
+func (d *Decoder) skip(n int) error {
+	d.size += n
+	var err error
+	if d.buffered != nil {
+		_, err = d.buffered.Discard(n)
+	} else {
+		_, err = io.CopyN(io.Discard, d.r, int64(n))
+	}
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// bytes returns the next n bytes of the stream.
+// The slice is only valid until the next call.
+func (d *Decoder) bytes(n int) ([]byte, error) {
+	d.size += n
+	if d.inFrame {
+		if n > d.remaining {
+			return nil, fmt.Errorf("%w: payload exceeds its frame by %d bytes", ErrInvalidLength, n-d.remaining)
+		}
+		d.remaining -= n
+	}
+	if d.buffered != nil && n <= d.buffered.Size() {
+		buf, err := d.buffered.Peek(n)
+		if err != nil {
+			if err == io.EOF {
+				return nil, io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		// Peek made sure n bytes are buffered, so Discard can't read and invalidate buf
+		d.buffered.Discard(n)
+		return buf, nil
+	}
+
+	// Only keep scratch space for regular packets, not the occasional huge bulk edit
+	if n > readBufferSize {
+		return d.readLarge(n)
+	}
+	if cap(d.scratch) < n {
+		d.scratch = make([]byte, readBufferSize)
+	}
+	buf := d.scratch[:n]
+	if _, err := io.ReadFull(d.r, buf); err != nil {
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf, nil
+}
+
+// readLarge returns the next n bytes in a new slice, grown as they arrive so a length field
+// can't allocate more than the stream holds
+func (d *Decoder) readLarge(n int) ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, d.r, int64(n)); err != nil {
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
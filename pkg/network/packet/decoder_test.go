@@ -0,0 +1,210 @@
+package packet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/leterax/go-voxels/pkg/voxel"
+)
+
+// loadStream reads testdata/stream.vxcp.gz, packets received by a client with a render
+// distance of 3 and compression disabled, recorded as a network.Recorder capture.
+// It returns the encoded packets, each starting with its ID.
+func loadStream(tb testing.TB) [][]byte {
+	tb.Helper()
+	file, err := os.Open("testdata/stream.vxcp.gz")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	data, err := io.ReadAll(gz)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	// magic "VXCP" + version, then offset(I64) + length(U32) + packet per record
+	if len(data) < 5 || string(data[:4]) != "VXCP" {
+		tb.Fatal("not a capture file")
+	}
+	var packets [][]byte
+	for rest := data[5:]; len(rest) > 0; {
+		if len(rest) < 12 {
+			tb.Fatal("truncated capture record")
+		}
+		length := int(binary.BigEndian.Uint32(rest[8:]))
+		packets = append(packets, rest[12:12+length])
+		rest = rest[12+length:]
+	}
+	return packets
+}
+
+// framedStream lays out packets as sent by a framing server
+func framedStream(packets [][]byte) []byte {
+	var stream []byte
+	for _, p := range packets {
+		stream = binary.BigEndian.AppendUint32(stream, uint32(len(p)))
+		stream = append(stream, p...)
+	}
+	return stream
+}
+
+// decodeAll decodes every packet of the stream, handing chunk blocks back if release is set
+func decodeAll(tb testing.TB, stream []byte, framed, release bool) map[uint8]int {
+	counts := make(map[uint8]int)
+	d := NewDecoder(bytes.NewReader(stream), ClientBound)
+	d.SetFramed(framed)
+	for {
+		p, err := d.Next()
+		if err == io.EOF {
+			return counts
+		}
+		if err != nil {
+			tb.Fatal(err)
+		}
+		counts[p.ID()]++
+		if !release {
+			continue
+		}
+		switch p := p.(type) {
+		case *SendChunk:
+			ReleaseBlocks(p.Blocks)
+		case *SendChunkStates:
+			ReleaseBlocks(p.Blocks)
+		}
+	}
+}
+
+func TestDecoderStream(t *testing.T) {
+	packets := loadStream(t)
+	counts := decodeAll(t, framedStream(packets), true, true)
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+	if total != len(packets) {
+		t.Errorf("decoded %d packets, the capture holds %d", total, len(packets))
+	}
+	for _, id := range []uint8{IDSendChunk, IDSendMonoTypeChunk, IDUpdateEntityPosition, IDChat} {
+		if counts[id] == 0 {
+			t.Errorf("the capture has no packet 0x%02x to benchmark", id)
+		}
+	}
+
+	// Unframed, like servers that predate framing
+	if got := decodeAll(t, bytes.Join(packets, nil), false, true); got[IDSendChunk] != counts[IDSendChunk] {
+		t.Errorf("unframed stream decoded %d chunks, want %d", got[IDSendChunk], counts[IDSendChunk])
+	}
+}
+
+func TestDecoderLargePacket(t *testing.T) {
+	// Larger than the read buffer, so it is read into a slice of its own
+	edit := &BlockBulkEdit{}
+	for i := range int32(3000) {
+		edit.Updates = append(edit.Updates, BlockUpdate{BlockType: voxel.Stone, X: i, Y: -i, Z: i % 16})
+	}
+	chat := &ChatMessage{Message: "after"}
+
+	for _, framed := range []bool{false, true} {
+		encode := Encode
+		if framed {
+			encode = EncodeFramed
+		}
+		stream := append(encode(edit), encode(chat)...)
+		d := NewDecoder(bytes.NewReader(stream), ServerBound)
+		d.SetFramed(framed)
+		for _, want := range []Packet{edit, chat} {
+			got, err := d.Next()
+			if err != nil {
+				t.Fatalf("framed %t: %v", framed, err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("framed %t: decoded %T changed", framed, got)
+			}
+		}
+
+		// The stream ends before the edit does
+		d = NewDecoder(bytes.NewReader(stream[:len(stream)/2]), ServerBound)
+		d.SetFramed(framed)
+		if _, err := d.Next(); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("framed %t: truncated edit gave %v, want io.ErrUnexpectedEOF", framed, err)
+		}
+	}
+}
+
+func TestDecoderEndOfStream(t *testing.T) {
+	chat := &Chat{Message: "hi"}
+	for _, framed := range []bool{false, true} {
+		encoded := Encode(chat)
+		if framed {
+			encoded = EncodeFramed(chat)
+		}
+		// Cut after the first packet and i bytes of the second, ending cleanly only between packets
+		for i := range len(encoded) {
+			stream := append(bytes.Clone(encoded), encoded[:i]...)
+			d := NewDecoder(bytes.NewReader(stream), ClientBound)
+			d.SetFramed(framed)
+			if _, err := d.Next(); err != nil {
+				t.Fatal(err)
+			}
+			_, err := d.Next()
+			if i == 0 && err != io.EOF {
+				t.Errorf("framed %t: got %v at the end of the stream, want io.EOF", framed, err)
+			}
+			if i > 0 && (err == io.EOF || !errors.Is(err, io.ErrUnexpectedEOF)) {
+				t.Errorf("framed %t: got %v %d bytes into a packet, want io.ErrUnexpectedEOF", framed, err, i)
+			}
+		}
+	}
+}
+
+// BenchmarkDecoder decodes the recorded stream. Chunk blocks are handed back with
+// ReleaseBlocks as the client does, or kept to show what the pool saves.
+func BenchmarkDecoder(b *testing.B) {
+	packets := loadStream(b)
+	stream := framedStream(packets)
+	for _, bench := range []struct {
+		name    string
+		release bool
+	}{
+		{"pooled", true},
+		{"unreleased", false},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			b.SetBytes(int64(len(stream)))
+			b.ReportAllocs()
+			for b.Loop() {
+				decodeAll(b, stream, true, bench.release)
+			}
+			b.ReportMetric(float64(len(packets)), "packets/op")
+		})
+	}
+}
+
+// BenchmarkRead decodes the recorded stream one packet at a time with the unbuffered Read
+func BenchmarkRead(b *testing.B) {
+	packets := loadStream(b)
+	stream := bytes.Join(packets, nil)
+	b.SetBytes(int64(len(stream)))
+	b.ReportAllocs()
+	for b.Loop() {
+		r := bytes.NewReader(stream)
+		for {
+			if _, err := Read(r, ClientBound); err != nil {
+				if err != io.EOF {
+					b.Fatal(err)
+				}
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package packet
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/leterax/go-voxels/pkg/voxel"
+)
+
+// Clientbound packet IDs added with block deltas
+const (
+	IDBlockChange      uint8 = 0x0B
+	IDMultiBlockChange uint8 = 0x0C
+)
+
+// BlockChange sets a single block of a chunk the client holds, in world coordinates
+type BlockChange struct {
+	X, Y, Z int32
+	State   voxel.BlockState
+}
+
+func (p *BlockChange) ID() uint8 { return IDBlockChange }
+
+func (p *BlockChange) AppendPayload(buf []byte) []byte {
+	buf = appendCoords(buf, p.X, p.Y, p.Z)
+	return append(buf, uint8(p.State.Type()), p.State.Props())
+}
+
+func (p *BlockChange) DecodePayload(d *Decoder) error {
+	buf, err := d.bytes(12 + 2)
+	if err != nil {
+		return err
+	}
+	p.X, p.Y, p.Z = getCoords(buf)
+	p.State = voxel.NewBlockState(voxel.BlockType(buf[12]), buf[13])
+	return checkBlockState(p.State)
+}
+
+// LocalBlockChange is a single entry of a Multi Block Change, in coordinates local to the chunk
+type LocalBlockChange struct {
+	X, Y, Z uint8
+	State   voxel.BlockState
+}
+
+const localBlockChangeSize = 3 + 2
+
+// MultiBlockChange sets several blocks of one chunk the client holds
+type MultiBlockChange struct {
+	X, Y, Z int32 // Chunk coordinates
+	Changes []LocalBlockChange
+}
+
+func (p *MultiBlockChange) ID() uint8 { return IDMultiBlockChange }
+
+func (p *MultiBlockChange) AppendPayload(buf []byte) []byte {
+	buf = appendCoords(buf, p.X, p.Y, p.Z)
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(p.Changes)))
+	for _, change := range p.Changes {
+		buf = append(buf, change.X, change.Y, change.Z, uint8(change.State.Type()), change.State.Props())
+	}
+	return buf
+}
+
+func (p *MultiBlockChange) DecodePayload(d *Decoder) error {
+	header, err := d.bytes(12 + 2)
+	if err != nil {
+		return err
+	}
+	p.X, p.Y, p.Z = getCoords(header)
+	if err := checkChunkCoords(p.X, p.Y, p.Z); err != nil {
+		return err
+	}
+	count := int(binary.BigEndian.Uint16(header[12:]))
+	if count > ChunkVolume {
+		return fmt.Errorf("%w: multi block change of %d blocks exceeds the chunk volume", ErrInvalidLength, count)
+	}
+
+	buf, err := d.bytes(count * localBlockChangeSize)
+	if err != nil {
+		return err
+	}
+	p.Changes = make([]LocalBlockChange, count)
+	for i := range p.Changes {
+		entry := buf[i*localBlockChangeSize:]
+		if entry[0] >= ChunkSize || entry[1] >= ChunkSize || entry[2] >= ChunkSize {
+			return fmt.Errorf("%w: block (%d, %d, %d) is outside the chunk", ErrInvalidPosition, entry[0], entry[1], entry[2])
+		}
+		p.Changes[i] = LocalBlockChange{
+			X: entry[0], Y: entry[1], Z: entry[2],
+			State: voxel.NewBlockState(voxel.BlockType(entry[3]), entry[4]),
+		}
+		if err := checkBlockState(p.Changes[i].State); err != nil {
+			return fmt.Errorf("block (%d, %d, %d): %w", entry[0], entry[1], entry[2], err)
+		}
+	}
+	return nil
+}
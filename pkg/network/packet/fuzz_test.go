@@ -0,0 +1,137 @@
+package packet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+// decodeErrors are the failures a decoder may report for malformed input
+var decodeErrors = []error{
+	io.EOF,
+	io.ErrUnexpectedEOF,
+	ErrUnknownPacket,
+	ErrInvalidBlockType,
+	ErrInvalidBlockState,
+	ErrInvalidText,
+	ErrInvalidChunkCoords,
+	ErrInvalidPosition,
+	ErrInvalidLength,
+}
+
+// checkDecodeError fails unless err is one of decodeErrors or, when decompressing, ErrInvalidCompressedChunk
+func checkDecodeError(t *testing.T, err error, decompressing bool) {
+	t.Helper()
+	if decompressing && errors.Is(err, ErrInvalidCompressedChunk) {
+		return
+	}
+	for _, target := range decodeErrors {
+		if errors.Is(err, target) {
+			return
+		}
+	}
+	t.Fatalf("unexpected error type: %v", err)
+}
+
+// Decoding may allocate allocPerByte bytes per input byte, plus allocBudget for its buffers,
+// a pooled chunk and the packet itself
+const (
+	allocBudget  = 64 << 10
+	allocPerByte = 16
+)
+
+// checkAllocated runs decode and fails if it allocated more than an input of inputSize bytes
+// justifies, as when a length field is trusted before the bytes it announces arrive
+func checkAllocated(t *testing.T, inputSize int, decode func()) {
+	t.Helper()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	decode()
+	runtime.ReadMemStats(&after)
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > allocBudget+allocPerByte*uint64(inputSize) {
+		t.Fatalf("allocated %d bytes decoding %d", allocated, inputSize)
+	}
+}
+
+// registryOf picks the registry a fuzz input is decoded with
+func registryOf(serverBound bool) *Registry {
+	if serverBound {
+		return ServerBound
+	}
+	return ClientBound
+}
+
+// addSeeds adds every sample packet of both registries, encoded with or without framing,
+// and a bulk edit announcing the most blocks allowed without sending them
+func addSeeds(f *testing.F, framed bool) {
+	for _, registry := range []*Registry{ClientBound, ServerBound} {
+		for _, p := range samplePackets(registry) {
+			if framed {
+				f.Add(EncodeFramed(p), registry == ServerBound)
+			} else {
+				f.Add(Encode(p), registry == ServerBound)
+			}
+		}
+	}
+
+	truncated := binary.BigEndian.AppendUint32([]byte{IDBlockBulkEdit}, MaxBulkEdit)
+	if framed {
+		truncated = append(binary.BigEndian.AppendUint32(nil, MaxFrameLength), truncated...)
+	}
+	f.Add(truncated, true)
+}
+
+// checkPacket decompresses chunk data and checks that the packet survives a round trip
+func checkPacket(t *testing.T, p Packet, registry *Registry) {
+	t.Helper()
+	if chunk, ok := p.(*SendCompressedChunk); ok {
+		if _, _, err := chunk.Decompress(); err != nil {
+			checkDecodeError(t, err, true)
+		}
+	}
+	got, err := Read(bytes.NewReader(Encode(p)), registry)
+	if err != nil {
+		t.Fatalf("decoded %+v, which fails to decode once encoded: %v", p, err)
+	}
+	if !reflect.DeepEqual(got, p) {
+		t.Fatalf("decoded %+v, which decodes as %+v once encoded", p, got)
+	}
+}
+
+func FuzzRead(f *testing.F) {
+	addSeeds(f, false)
+	f.Fuzz(func(t *testing.T, data []byte, serverBound bool) {
+		registry := registryOf(serverBound)
+		var p Packet
+		var err error
+		checkAllocated(t, len(data), func() { p, err = Read(bytes.NewReader(data), registry) })
+		if err != nil {
+			checkDecodeError(t, err, false)
+			return
+		}
+		checkPacket(t, p, registry)
+	})
+}
+
+func FuzzDecoder(f *testing.F) {
+	addSeeds(f, true)
+	f.Fuzz(func(t *testing.T, data []byte, serverBound bool) {
+		registry := registryOf(serverBound)
+		d := NewDecoder(bytes.NewReader(data), registry)
+		d.SetFramed(true)
+		for {
+			var p Packet
+			var err error
+			checkAllocated(t, len(data), func() { p, err = d.Next() })
+			if err != nil {
+				checkDecodeError(t, err, false)
+				return
+			}
+			checkPacket(t, p, registry)
+		}
+	})
+}
@@ -0,0 +1,148 @@
+package packet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Protocol versions spoken by this package. Peers that predate the Hello exchange speak
+// LegacyProtocolVersion, without capabilities or framing.
+const (
+	ProtocolVersion       uint16 = 1
+	MinProtocolVersion    uint16 = 1
+	LegacyProtocolVersion uint16 = 0
+)
+
+// Packet IDs added with the Hello exchange. Hello packets keep their ID and layout in every version.
+const (
+	IDServerHello         uint8 = 0x09 // Clientbound
+	IDSendCompressedChunk uint8 = 0x0A // Clientbound
+	IDClientHello         uint8 = 0x06 // Serverbound
+)
+
+// Capability flags exchanged in Server Hello and Client Hello
+const (
+	CapabilityChunkRLE     uint32 = 1 << 0 // Send Compressed Chunk with run-length encoding
+	CapabilityChunkDeflate uint32 = 1 << 1 // Send Compressed Chunk with DEFLATE
+	CapabilityFramed       uint32 = 1 << 2 // Packets after the sender's Hello are length-prefixed
+	CapabilityBlockDeltas  uint32 = 1 << 3 // Block Change and Multi Block Change after small edits
+	CapabilityChunkUnload  uint32 = 1 << 4 // Unload Chunk when a chunk leaves the render distance
+	CapabilityPing         uint32 = 1 << 5 // Ping is answered with Pong
+
+	// SupportedCapabilities is every capability implemented by this package
+	SupportedCapabilities = CapabilityChunkRLE | CapabilityChunkDeflate | CapabilityFramed |
+		CapabilityBlockDeltas | CapabilityChunkUnload | CapabilityPing
+)
+
+// ErrIncompatibleVersion is returned when the peer doesn't speak a supported protocol version
+var ErrIncompatibleVersion = errors.New("incompatible protocol version")
+
+// NegotiateVersion returns the version to speak with a peer supporting minVersion to version
+func NegotiateVersion(version, minVersion uint16) (uint16, error) {
+	if version < MinProtocolVersion || minVersion > ProtocolVersion || minVersion > version {
+		return 0, fmt.Errorf("%w: peer speaks versions %d to %d, supported are %d to %d",
+			ErrIncompatibleVersion, minVersion, version, MinProtocolVersion, ProtocolVersion)
+	}
+	return min(version, ProtocolVersion), nil
+}
+
+// ServerHello is the server's reply to Client Hello. It announces the version picked for the
+// connection, or the newest supported one if there is none, and the optional features enabled.
+type ServerHello struct {
+	Version    uint16 // Version picked, or the newest supported one
+	MinVersion uint16 // Oldest supported version
+	Flags      uint32
+}
+
+func (p *ServerHello) ID() uint8 { return IDServerHello }
+
+func (p *ServerHello) AppendPayload(buf []byte) []byte {
+	buf = binary.BigEndian.AppendUint16(buf, p.Version)
+	buf = binary.BigEndian.AppendUint16(buf, p.MinVersion)
+	return binary.BigEndian.AppendUint32(buf, p.Flags)
+}
+
+func (p *ServerHello) DecodePayload(d *Decoder) error {
+	buf, err := d.bytes(2 + 2 + 4)
+	if err != nil {
+		return err
+	}
+	p.Version = binary.BigEndian.Uint16(buf)
+	p.MinVersion = binary.BigEndian.Uint16(buf[2:])
+	p.Flags = binary.BigEndian.Uint32(buf[4:])
+	return nil
+}
+
+// ClientHello is the first packet sent by the client. It announces the supported protocol
+// versions and the optional features the client accepts, plus framing of its own packets.
+type ClientHello struct {
+	Version    uint16 // Newest supported version
+	MinVersion uint16 // Oldest supported version
+	Flags      uint32
+}
+
+func (p *ClientHello) ID() uint8 { return IDClientHello }
+
+func (p *ClientHello) AppendPayload(buf []byte) []byte {
+	buf = binary.BigEndian.AppendUint16(buf, p.Version)
+	buf = binary.BigEndian.AppendUint16(buf, p.MinVersion)
+	return binary.BigEndian.AppendUint32(buf, p.Flags)
+}
+
+func (p *ClientHello) DecodePayload(d *Decoder) error {
+	buf, err := d.bytes(2 + 2 + 4)
+	if err != nil {
+		return err
+	}
+	p.Version = binary.BigEndian.Uint16(buf)
+	p.MinVersion = binary.BigEndian.Uint16(buf[2:])
+	p.Flags = binary.BigEndian.Uint32(buf[4:])
+	return nil
+}
+
+// SendCompressedChunk carries a chunk like Send Chunk or Send Chunk States, compressed.
+// Use CompressChunk to build one and Decompress to read the blocks back.
+type SendCompressedChunk struct {
+	X, Y, Z  int32
+	Encoding uint8 // EncodingRLE or EncodingDeflate
+	HasProps bool  // Data holds properties after the blocks
+	Data     []byte
+}
+
+func (p *SendCompressedChunk) ID() uint8 { return IDSendCompressedChunk }
+
+func (p *SendCompressedChunk) AppendPayload(buf []byte) []byte {
+	buf = appendCoords(buf, p.X, p.Y, p.Z)
+	var flags uint8
+	if p.HasProps {
+		flags = 1
+	}
+	buf = append(buf, p.Encoding, flags)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(p.Data)))
+	return append(buf, p.Data...)
+}
+
+func (p *SendCompressedChunk) DecodePayload(d *Decoder) error {
+	header, err := d.bytes(12 + 2 + 4)
+	if err != nil {
+		return err
+	}
+	p.X, p.Y, p.Z = getCoords(header)
+	if err := checkChunkCoords(p.X, p.Y, p.Z); err != nil {
+		return err
+	}
+	p.Encoding = header[12]
+	p.HasProps = header[13]&1 != 0
+	length := binary.BigEndian.Uint32(header[14:])
+	if length > maxCompressedChunk {
+		return fmt.Errorf("%w: compressed chunk of %d bytes exceeds the limit of %d", ErrInvalidLength, length, maxCompressedChunk)
+	}
+
+	data, err := d.bytes(int(length))
+	if err != nil {
+		return err
+	}
+	p.Data = append([]byte(nil), data...)
+	return nil
+}
@@ -0,0 +1,68 @@
+package packet
+
+// Packet IDs added with chunk interest management
+const (
+	IDUnloadChunk  uint8 = 0x0D // Clientbound
+	IDDropChunk    uint8 = 0x07 // Serverbound
+	IDRequestChunk uint8 = 0x08 // Serverbound
+)
+
+// UnloadChunk tells the client a chunk left its render distance and won't be updated anymore
+type UnloadChunk struct {
+	X, Y, Z int32
+}
+
+func (p *UnloadChunk) ID() uint8 { return IDUnloadChunk }
+
+func (p *UnloadChunk) AppendPayload(buf []byte) []byte {
+	return appendCoords(buf, p.X, p.Y, p.Z)
+}
+
+func (p *UnloadChunk) DecodePayload(d *Decoder) error {
+	buf, err := d.bytes(12)
+	if err != nil {
+		return err
+	}
+	p.X, p.Y, p.Z = getCoords(buf)
+	return checkChunkCoords(p.X, p.Y, p.Z)
+}
+
+// DropChunk tells the server the client discarded a chunk, which is not streamed again until requested
+type DropChunk struct {
+	X, Y, Z int32
+}
+
+func (p *DropChunk) ID() uint8 { return IDDropChunk }
+
+func (p *DropChunk) AppendPayload(buf []byte) []byte {
+	return appendCoords(buf, p.X, p.Y, p.Z)
+}
+
+func (p *DropChunk) DecodePayload(d *Decoder) error {
+	buf, err := d.bytes(12)
+	if err != nil {
+		return err
+	}
+	p.X, p.Y, p.Z = getCoords(buf)
+	return checkChunkCoords(p.X, p.Y, p.Z)
+}
+
+// RequestChunk asks the server to send a chunk within the render distance again
+type RequestChunk struct {
+	X, Y, Z int32
+}
+
+func (p *RequestChunk) ID() uint8 { return IDRequestChunk }
+
+func (p *RequestChunk) AppendPayload(buf []byte) []byte {
+	return appendCoords(buf, p.X, p.Y, p.Z)
+}
+
+func (p *RequestChunk) DecodePayload(d *Decoder) error {
+	buf, err := d.bytes(12)
+	if err != nil {
+		return err
+	}
+	p.X, p.Y, p.Z = getCoords(buf)
+	return checkChunkCoords(p.X, p.Y, p.Z)
+}
@@ -0,0 +1,213 @@
+// Package packet defines one Go type per packet of the protocol in pkg/network/protocol.md,
+// with symmetric encoding and decoding shared by the client, the server and tooling.
+package packet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// Protocol constants from protocol.md
+const (
+	ChunkSize     = 16
+	ChunkVolume   = ChunkSize * ChunkSize * ChunkSize
+	NameLength    = 64   // Fixed length of entity name fields
+	MessageLength = 4096 // Fixed length of chat message fields
+	MaxBulkEdit   = 1 << 18
+
+	// MaxFrameLength bounds the length prefix of framed packets, above the largest bulk edit
+	MaxFrameLength = 1 << 24
+)
+
+// Direction tells which side sends a packet
+type Direction uint8
+
+const (
+	ClientBoundDirection Direction = iota // Server to client
+	ServerBoundDirection                  // Client to server
+)
+
+// String returns the direction's name
+func (d Direction) String() string {
+	if d == ServerBoundDirection {
+		return "serverbound"
+	}
+	return "clientbound"
+}
+
+// Packet is a single protocol message
+type Packet interface {
+	// ID returns the packet ID sent before the payload
+	ID() uint8
+	// AppendPayload appends the encoded fields, without the packet ID
+	AppendPayload(buf []byte) []byte
+	// DecodePayload reads the fields following the packet ID
+	DecodePayload(d *Decoder) error
+}
+
+// Registry maps packet IDs to constructors for one direction
+type Registry struct {
+	Direction Direction
+	packets   map[uint8]func() Packet
+}
+
+// ClientBound holds every packet the server sends
+var ClientBound = newRegistry(ClientBoundDirection,
+	func() Packet { return &Identification{} },
+	func() Packet { return &AddEntity{} },
+	func() Packet { return &RemoveEntity{} },
+	func() Packet { return &UpdateEntityPosition{} },
+	func() Packet { return &SendChunk{} },
+	func() Packet { return &SendMonoTypeChunk{} },
+	func() Packet { return &Chat{} },
+	func() Packet { return &UpdateEntityMetadata{} },
+	func() Packet { return &SendChunkStates{} },
+	func() Packet { return &ServerHello{} },
+	func() Packet { return &SendCompressedChunk{} },
+	func() Packet { return &BlockChange{} },
+	func() Packet { return &MultiBlockChange{} },
+	func() Packet { return &UnloadChunk{} },
+	func() Packet { return &Pong{} },
+)
+
+// ServerBound holds every packet the client sends
+var ServerBound = newRegistry(ServerBoundDirection,
+	func() Packet { return &UpdateEntity{} },
+	func() Packet { return &UpdateBlock{} },
+	func() Packet { return &BlockBulkEdit{} },
+	func() Packet { return &ChatMessage{} },
+	func() Packet { return &ClientMetadata{} },
+	func() Packet { return &UpdateBlockState{} },
+	func() Packet { return &ClientHello{} },
+	func() Packet { return &DropChunk{} },
+	func() Packet { return &RequestChunk{} },
+	func() Packet { return &Ping{} },
+)
+
+func newRegistry(direction Direction, constructors ...func() Packet) *Registry {
+	r := &Registry{
+		Direction: direction,
+		packets:   make(map[uint8]func() Packet, len(constructors)),
+	}
+	for _, constructor := range constructors {
+		id := constructor().ID()
+		if _, ok := r.packets[id]; ok {
+			panic(fmt.Sprintf("duplicate %s packet ID 0x%02x", direction, id))
+		}
+		r.packets[id] = constructor
+	}
+	return r
+}
+
+// New returns an empty packet for the ID, or false if the ID is unknown
+func (r *Registry) New(id uint8) (Packet, bool) {
+	constructor, ok := r.packets[id]
+	if !ok {
+		return nil, false
+	}
+	return constructor(), true
+}
+
+// IDs returns every registered packet ID in ascending order
+func (r *Registry) IDs() []uint8 {
+	ids := make([]uint8, 0, len(r.packets))
+	for id := range 256 {
+		if _, ok := r.packets[uint8(id)]; ok {
+			ids = append(ids, uint8(id))
+		}
+	}
+	return ids
+}
+
+// Encode returns the packet ID followed by the payload
+func Encode(p Packet) []byte {
+	return p.AppendPayload([]byte{p.ID()})
+}
+
+// Write encodes the packet and writes it in a single call
+func Write(w io.Writer, p Packet) error {
+	_, err := w.Write(Encode(p))
+	return err
+}
+
+// AppendFramed appends the packet prefixed with its length (U32), as sent once framing is announced
+func AppendFramed(buf []byte, p Packet) []byte {
+	start := len(buf)
+	buf = append(buf, 0, 0, 0, 0, p.ID())
+	buf = p.AppendPayload(buf)
+	binary.BigEndian.PutUint32(buf[start:], uint32(len(buf)-start-4))
+	return buf
+}
+
+// EncodeFramed returns the length prefix followed by the packet ID and the payload
+func EncodeFramed(p Packet) []byte {
+	return AppendFramed(nil, p)
+}
+
+// Position is an entity's location and orientation
+type Position struct {
+	X, Y, Z    float32
+	Yaw, Pitch float32
+}
+
+const positionSize = 4 * 5
+
+func appendPosition(buf []byte, pos Position) []byte {
+	for _, v := range [5]float32{pos.X, pos.Y, pos.Z, pos.Yaw, pos.Pitch} {
+		buf = binary.BigEndian.AppendUint32(buf, math.Float32bits(v))
+	}
+	return buf
+}
+
+func getPosition(buf []byte) Position {
+	f := func(i int) float32 { return math.Float32frombits(binary.BigEndian.Uint32(buf[i*4:])) }
+	return Position{X: f(0), Y: f(1), Z: f(2), Yaw: f(3), Pitch: f(4)}
+}
+
+func appendCoords(buf []byte, x, y, z int32) []byte {
+	buf = binary.BigEndian.AppendUint32(buf, uint32(x))
+	buf = binary.BigEndian.AppendUint32(buf, uint32(y))
+	return binary.BigEndian.AppendUint32(buf, uint32(z))
+}
+
+func getCoords(buf []byte) (x, y, z int32) {
+	return int32(binary.BigEndian.Uint32(buf[0:])),
+		int32(binary.BigEndian.Uint32(buf[4:])),
+		int32(binary.BigEndian.Uint32(buf[8:]))
+}
+
+// appendString appends s as a zero-padded fixed-length field. Invalid UTF-8 is replaced
+// and s is truncated at a rune boundary if needed, so decoders accept the field.
+func appendString(buf []byte, s string, length int) []byte {
+	s = strings.ToValidUTF8(s, "\uFFFD")
+	if len(s) > length {
+		cut := length
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		s = s[:cut]
+	}
+	return appendFixed(buf, []byte(s), length)
+}
+
+// appendFixed appends data zero-padded or truncated to length bytes
+func appendFixed(buf, data []byte, length int) []byte {
+	if len(data) > length {
+		data = data[:length]
+	}
+	buf = append(buf, data...)
+	return append(buf, make([]byte, length-len(data))...)
+}
+
+// getString extracts a null-terminated string from a fixed-length field
+func getString(field []byte) string {
+	if idx := bytes.IndexByte(field, 0); idx >= 0 {
+		field = field[:idx]
+	}
+	return string(field)
+}
@@ -0,0 +1,184 @@
+package packet
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/leterax/go-voxels/pkg/voxel"
+)
+
+// testChunk returns a chunk of varied blocks with valid properties
+func testChunk() ([]voxel.BlockType, []uint8) {
+	blocks := make([]voxel.BlockType, ChunkVolume)
+	props := make([]uint8, ChunkVolume)
+	for i := range blocks {
+		switch {
+		case i < ChunkVolume/4:
+			blocks[i] = voxel.Stone
+		case i%7 == 0:
+			blocks[i] = voxel.OakLog
+			props[i] = uint8(voxel.AxisZ)
+		case i%5 == 0:
+			blocks[i] = voxel.Water
+			props[i] = uint8(i % 16)
+		case i%3 == 0:
+			blocks[i] = voxel.Grass
+		}
+	}
+	return blocks, props
+}
+
+// samplePackets returns a packet with every field set for each registered packet ID of the registry
+func samplePackets(registry *Registry) map[uint8]Packet {
+	blocks, props := testChunk()
+	pos := Position{X: 1.5, Y: -64.25, Z: 1e6, Yaw: 270, Pitch: -89.5}
+	stairs := voxel.NewBlockState(voxel.OakStairs, 3|1<<3)
+
+	var packets []Packet
+	if registry == ClientBound {
+		compressed := CompressChunk(-3, 4, 5, blocks, props, SupportedCapabilities)
+		packets = []Packet{
+			&Identification{EntityID: 42},
+			&AddEntity{EntityID: 7, Position: pos, Name: "Bøb"},
+			&RemoveEntity{EntityID: 7},
+			&UpdateEntityPosition{EntityID: 7, Position: pos},
+			&SendChunk{X: -1, Y: 2, Z: MaxChunkCoord, Blocks: blocks},
+			&SendMonoTypeChunk{X: MinChunkCoord, Y: 0, Z: 3, BlockType: voxel.Flower},
+			&Chat{Message: "bob: hello, wörld"},
+			&UpdateEntityMetadata{EntityID: 7, Name: "Bob the builder"},
+			&SendChunkStates{X: 1, Y: -2, Z: 3, Blocks: blocks, Props: props},
+			&ServerHello{Version: ProtocolVersion, MinVersion: MinProtocolVersion, Flags: SupportedCapabilities},
+			compressed,
+			&BlockChange{X: -17, Y: 64, Z: 1 << 20, State: stairs},
+			&MultiBlockChange{X: -2, Y: 0, Z: 9, Changes: []LocalBlockChange{
+				{X: 0, Y: 0, Z: 0, State: voxel.BlockState(voxel.Glass)},
+				{X: 15, Y: 15, Z: 15, State: stairs},
+			}},
+			&UnloadChunk{X: 5, Y: -6, Z: 7},
+			&Pong{Token: 0xDEADBEEF},
+		}
+	} else {
+		packets = []Packet{
+			&UpdateEntity{Position: pos},
+			&UpdateBlock{BlockType: voxel.GoldBlock, X: -1, Y: 2, Z: -3},
+			&BlockBulkEdit{Updates: []BlockUpdate{
+				{BlockType: voxel.Air, X: 0, Y: 1, Z: 2},
+				{BlockType: voxel.Sand, X: -100, Y: 200, Z: -300},
+			}},
+			&ChatMessage{Message: "/tp ~ ~10 ~"},
+			&ClientMetadata{RenderDistance: 12, Name: "bot-7"},
+			&UpdateBlockState{State: stairs, X: 4, Y: 5, Z: 6},
+			&ClientHello{Version: ProtocolVersion, MinVersion: MinProtocolVersion, Flags: CapabilityFramed | CapabilityChunkRLE},
+			&DropChunk{X: 1, Y: 2, Z: 3},
+			&RequestChunk{X: -1, Y: -2, Z: -3},
+			&Ping{Token: 7},
+		}
+	}
+
+	samples := make(map[uint8]Packet, len(packets))
+	for _, p := range packets {
+		samples[p.ID()] = p
+	}
+	return samples
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, registry := range []*Registry{ClientBound, ServerBound} {
+		samples := samplePackets(registry)
+		for _, id := range registry.IDs() {
+			want, ok := samples[id]
+			if !ok {
+				t.Errorf("no sample %s packet for ID 0x%02x", registry.Direction, id)
+				continue
+			}
+			t.Run(reflect.TypeOf(want).Elem().Name(), func(t *testing.T) {
+				got, err := Read(bytes.NewReader(Encode(want)), registry)
+				if err != nil {
+					t.Fatalf("Read: %v", err)
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("Read returned %+v, want %+v", got, want)
+				}
+
+				d := NewDecoder(bytes.NewReader(EncodeFramed(want)), registry)
+				d.SetFramed(true)
+				got, err = d.Next()
+				if err != nil {
+					t.Fatalf("framed: %v", err)
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("framed decoding returned %+v, want %+v", got, want)
+				}
+				if d.Size() != len(EncodeFramed(want)) {
+					t.Errorf("Size %d, want %d", d.Size(), len(EncodeFramed(want)))
+				}
+			})
+		}
+	}
+}
+
+func TestDecompressRoundTrip(t *testing.T) {
+	blocks, props := testChunk()
+	for _, encoding := range []uint8{EncodingRLE, EncodingDeflate} {
+		for _, withProps := range []bool{false, true} {
+			var chunkProps []uint8
+			if withProps {
+				chunkProps = props
+			}
+			p := &SendCompressedChunk{Encoding: encoding, HasProps: withProps, Data: Compress(ChunkData(blocks, chunkProps), encoding)}
+			gotBlocks, gotProps, err := p.Decompress()
+			if err != nil {
+				t.Fatalf("encoding %d, props %v: %v", encoding, withProps, err)
+			}
+			if !reflect.DeepEqual(gotBlocks, blocks) || !reflect.DeepEqual(gotProps, chunkProps) {
+				t.Errorf("encoding %d, props %v: chunk changed in the round trip", encoding, withProps)
+			}
+		}
+	}
+}
+
+func TestFramedSkipsUnknownAndTrailing(t *testing.T) {
+	var stream []byte
+	// An unknown packet, then a known one carrying a field from a newer version
+	stream = append(stream, 0, 0, 0, 4, 0x7f, 1, 2, 3)
+	chat := AppendFramed(nil, &Chat{Message: "hi"})
+	chat = append(chat, 0xAA, 0xBB)
+	chat[3] += 2
+	stream = append(stream, chat...)
+
+	d := NewDecoder(bytes.NewReader(stream), ClientBound)
+	d.SetFramed(true)
+	p, err := d.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := p.(*Chat); !ok || got.Message != "hi" {
+		t.Errorf("got %+v, want the chat message", p)
+	}
+}
+
+func TestAppendStringKeepsUTF8(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"plain", "plain"},
+		{strings.Repeat("a", NameLength-1) + "é", strings.Repeat("a", NameLength-1)},
+		{strings.Repeat("é", NameLength), strings.Repeat("é", NameLength/2)},
+		{"bad \xff byte", "bad � byte"},
+	}
+	for _, tt := range tests {
+		field := appendString(nil, tt.in, NameLength)
+		if len(field) != NameLength {
+			t.Fatalf("field of %d bytes, want %d", len(field), NameLength)
+		}
+		got, err := getText(field)
+		if err != nil {
+			t.Errorf("%q: %v", tt.in, err)
+		}
+		if got != tt.want {
+			t.Errorf("%q encoded as %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
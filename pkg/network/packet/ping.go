@@ -0,0 +1,49 @@
+package packet
+
+import "encoding/binary"
+
+// Packet IDs added with round trip measurement
+const (
+	IDPong uint8 = 0x0E // Clientbound
+	IDPing uint8 = 0x09 // Serverbound
+)
+
+// Ping asks the server for a Pong carrying the same token
+type Ping struct {
+	Token uint32
+}
+
+func (p *Ping) ID() uint8 { return IDPing }
+
+func (p *Ping) AppendPayload(buf []byte) []byte {
+	return binary.BigEndian.AppendUint32(buf, p.Token)
+}
+
+func (p *Ping) DecodePayload(d *Decoder) error {
+	buf, err := d.bytes(4)
+	if err != nil {
+		return err
+	}
+	p.Token = binary.BigEndian.Uint32(buf)
+	return nil
+}
+
+// Pong answers a Ping with its token
+type Pong struct {
+	Token uint32
+}
+
+func (p *Pong) ID() uint8 { return IDPong }
+
+func (p *Pong) AppendPayload(buf []byte) []byte {
+	return binary.BigEndian.AppendUint32(buf, p.Token)
+}
+
+func (p *Pong) DecodePayload(d *Decoder) error {
+	buf, err := d.bytes(4)
+	if err != nil {
+		return err
+	}
+	p.Token = binary.BigEndian.Uint32(buf)
+	return nil
+}
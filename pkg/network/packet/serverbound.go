@@ -0,0 +1,177 @@
+package packet
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/leterax/go-voxels/pkg/voxel"
+)
+
+// ServerBound packet IDs
+const (
+	IDUpdateEntity     uint8 = 0x00
+	IDUpdateBlock      uint8 = 0x01
+	IDBlockBulkEdit    uint8 = 0x02
+	IDChatMessage      uint8 = 0x03
+	IDClientMetadata   uint8 = 0x04
+	IDUpdateBlockState uint8 = 0x05
+)
+
+// UpdateEntity reports the client's own position
+type UpdateEntity struct {
+	Position Position
+}
+
+func (p *UpdateEntity) ID() uint8 { return IDUpdateEntity }
+
+func (p *UpdateEntity) AppendPayload(buf []byte) []byte {
+	return appendPosition(buf, p.Position)
+}
+
+func (p *UpdateEntity) DecodePayload(d *Decoder) error {
+	buf, err := d.bytes(positionSize)
+	if err != nil {
+		return err
+	}
+	p.Position = getPosition(buf)
+	return checkPosition(p.Position)
+}
+
+// UpdateBlock places or removes a single block
+type UpdateBlock struct {
+	BlockType voxel.BlockType
+	X, Y, Z   int32
+}
+
+func (p *UpdateBlock) ID() uint8 { return IDUpdateBlock }
+
+func (p *UpdateBlock) AppendPayload(buf []byte) []byte {
+	buf = append(buf, uint8(p.BlockType))
+	return appendCoords(buf, p.X, p.Y, p.Z)
+}
+
+func (p *UpdateBlock) DecodePayload(d *Decoder) error {
+	buf, err := d.bytes(1 + 12)
+	if err != nil {
+		return err
+	}
+	p.BlockType = voxel.BlockType(buf[0])
+	p.X, p.Y, p.Z = getCoords(buf[1:])
+	return checkBlockType(p.BlockType)
+}
+
+// BlockUpdate is a single entry of a Block Bulk Edit
+type BlockUpdate struct {
+	BlockType voxel.BlockType
+	X, Y, Z   int32
+}
+
+const blockUpdateSize = 1 + 12
+
+// BlockBulkEdit changes many blocks at once
+type BlockBulkEdit struct {
+	Updates []BlockUpdate
+}
+
+func (p *BlockBulkEdit) ID() uint8 { return IDBlockBulkEdit }
+
+func (p *BlockBulkEdit) AppendPayload(buf []byte) []byte {
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(p.Updates)))
+	for _, update := range p.Updates {
+		buf = append(buf, uint8(update.BlockType))
+		buf = appendCoords(buf, update.X, update.Y, update.Z)
+	}
+	return buf
+}
+
+func (p *BlockBulkEdit) DecodePayload(d *Decoder) error {
+	header, err := d.bytes(4)
+	if err != nil {
+		return err
+	}
+	count := binary.BigEndian.Uint32(header)
+	if count > MaxBulkEdit {
+		return fmt.Errorf("%w: bulk edit of %d blocks exceeds the limit of %d", ErrInvalidLength, count, MaxBulkEdit)
+	}
+
+	buf, err := d.bytes(int(count) * blockUpdateSize)
+	if err != nil {
+		return err
+	}
+	p.Updates = make([]BlockUpdate, count)
+	for i := range p.Updates {
+		entry := buf[i*blockUpdateSize:]
+		p.Updates[i].BlockType = voxel.BlockType(entry[0])
+		p.Updates[i].X, p.Updates[i].Y, p.Updates[i].Z = getCoords(entry[1:])
+		if err := checkBlockType(p.Updates[i].BlockType); err != nil {
+			return fmt.Errorf("update %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// ChatMessage sends a chat message to the server
+type ChatMessage struct {
+	Message string
+}
+
+func (p *ChatMessage) ID() uint8 { return IDChatMessage }
+
+func (p *ChatMessage) AppendPayload(buf []byte) []byte {
+	return appendString(buf, p.Message, MessageLength)
+}
+
+func (p *ChatMessage) DecodePayload(d *Decoder) error {
+	buf, err := d.bytes(MessageLength)
+	if err != nil {
+		return err
+	}
+	p.Message, err = getText(buf)
+	return err
+}
+
+// ClientMetadata sets the client's render distance and name
+type ClientMetadata struct {
+	RenderDistance uint8
+	Name           string
+}
+
+func (p *ClientMetadata) ID() uint8 { return IDClientMetadata }
+
+func (p *ClientMetadata) AppendPayload(buf []byte) []byte {
+	buf = append(buf, p.RenderDistance)
+	return appendString(buf, p.Name, NameLength)
+}
+
+func (p *ClientMetadata) DecodePayload(d *Decoder) error {
+	buf, err := d.bytes(1 + NameLength)
+	if err != nil {
+		return err
+	}
+	p.RenderDistance = buf[0]
+	p.Name, err = getText(buf[1:])
+	return err
+}
+
+// UpdateBlockState places a block together with its properties
+type UpdateBlockState struct {
+	State   voxel.BlockState
+	X, Y, Z int32
+}
+
+func (p *UpdateBlockState) ID() uint8 { return IDUpdateBlockState }
+
+func (p *UpdateBlockState) AppendPayload(buf []byte) []byte {
+	buf = append(buf, uint8(p.State.Type()), p.State.Props())
+	return appendCoords(buf, p.X, p.Y, p.Z)
+}
+
+func (p *UpdateBlockState) DecodePayload(d *Decoder) error {
+	buf, err := d.bytes(2 + 12)
+	if err != nil {
+		return err
+	}
+	p.State = voxel.NewBlockState(voxel.BlockType(buf[0]), buf[1])
+	p.X, p.Y, p.Z = getCoords(buf[2:])
+	return checkBlockState(p.State)
+}
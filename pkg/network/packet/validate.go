@@ -0,0 +1,102 @@
+package packet
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"unicode/utf8"
+
+	"github.com/leterax/go-voxels/pkg/voxel"
+)
+
+// Chunk coordinates are limited so the world coordinates of every block in the chunk fit an int32
+const (
+	MinChunkCoord = math.MinInt32 / ChunkSize
+	MaxChunkCoord = math.MaxInt32 / ChunkSize
+)
+
+// Errors returned when a decoded packet carries values outside the protocol's rules.
+// They are wrapped with the offending value, test for them with errors.Is.
+var (
+	ErrInvalidBlockType   = errors.New("invalid block type")
+	ErrInvalidBlockState  = errors.New("invalid block state")
+	ErrInvalidText        = errors.New("invalid UTF-8 text")
+	ErrInvalidChunkCoords = errors.New("chunk coordinates out of range")
+	ErrInvalidPosition    = errors.New("invalid position")
+	ErrInvalidLength      = errors.New("length out of range")
+)
+
+// checkChunkCoords fails with ErrInvalidChunkCoords unless every coordinate is within range
+func checkChunkCoords(x, y, z int32) error {
+	for _, c := range [3]int32{x, y, z} {
+		if c < MinChunkCoord || c > MaxChunkCoord {
+			return fmt.Errorf("%w: (%d, %d, %d)", ErrInvalidChunkCoords, x, y, z)
+		}
+	}
+	return nil
+}
+
+// validBlockTypes holds BlockType.Valid for every block type, to check whole chunks without map lookups
+var validBlockTypes = func() (valid [256]bool) {
+	for i := range valid {
+		valid[i] = voxel.BlockType(i).Valid()
+	}
+	return valid
+}()
+
+// checkBlockType fails with ErrInvalidBlockType unless the block type is named
+func checkBlockType(blockType voxel.BlockType) error {
+	if !validBlockTypes[blockType] {
+		return fmt.Errorf("%w: %d", ErrInvalidBlockType, blockType)
+	}
+	return nil
+}
+
+// checkBlockState fails with ErrInvalidBlockType or ErrInvalidBlockState unless the state is valid
+func checkBlockState(state voxel.BlockState) error {
+	if err := checkBlockType(state.Type()); err != nil {
+		return err
+	}
+	if !state.Valid() {
+		return fmt.Errorf("%w: properties %d for %s", ErrInvalidBlockState, state.Props(), state.Type())
+	}
+	return nil
+}
+
+// checkChunkStates validates the blocks of a chunk and, if not nil, their properties
+func checkChunkStates(blocks []voxel.BlockType, props []uint8) error {
+	for i, blockType := range blocks {
+		// Most blocks carry no properties, which is valid for every named block type
+		if validBlockTypes[blockType] && (props == nil || props[i] == 0) {
+			continue
+		}
+		if props != nil {
+			if err := checkBlockState(voxel.NewBlockState(blockType, props[i])); err != nil {
+				return fmt.Errorf("block %d: %w", i, err)
+			}
+		} else if err := checkBlockType(blockType); err != nil {
+			return fmt.Errorf("block %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// checkPosition fails with ErrInvalidPosition if a component is NaN or infinite
+func checkPosition(pos Position) error {
+	for _, v := range [5]float32{pos.X, pos.Y, pos.Z, pos.Yaw, pos.Pitch} {
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			return fmt.Errorf("%w: %+v", ErrInvalidPosition, pos)
+		}
+	}
+	return nil
+}
+
+// getText extracts a null-terminated string like getString, failing with ErrInvalidText
+// unless it is valid UTF-8
+func getText(field []byte) (string, error) {
+	s := getString(field)
+	if !utf8.ValidString(s) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidText, s)
+	}
+	return s, nil
+}
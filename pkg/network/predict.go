@@ -0,0 +1,288 @@
+package network
+
+import (
+	"sync"
+	"time"
+
+	"github.com/leterax/go-voxels/pkg/voxel"
+)
+
+// DefaultConfirmTimeout is how long a Predictor waits for the server to confirm an edit by default
+const DefaultConfirmTimeout = time.Second
+
+// BlockSender sends block edits to the server, implemented by Client and ReconnectingClient
+type BlockSender interface {
+	SendUpdateBlock(blockType voxel.BlockType, x, y, z int32) error
+	SendUpdateBlockState(state voxel.BlockState, x, y, z int32) error
+}
+
+// Predictor keeps a local copy of the chunks received from the server and applies the client's
+// own block edits to it immediately, before the server confirms them.
+//
+// Edits stay pending until a chunk or block change covering them arrives. If it agrees, the edit
+// is confirmed. Otherwise it may have been sent before the server applied the edit, so the
+// prediction is kept on top of it, until the timeout has passed and the server's version wins.
+// Feed it the client's events with Follow or Apply, and call Expire regularly to roll back edits
+// the server never answered.
+type Predictor struct {
+	sender  BlockSender
+	world   *voxel.World
+	timeout time.Duration
+
+	// OnChunkChanged is called after the contents of a chunk changed, through a received chunk,
+	// a prediction or a rollback, so it can be remeshed. Set it before use. It runs on the
+	// goroutine that caused the change, which may be the one applying events or the one editing.
+	OnChunkChanged func(coord voxel.ChunkCoord)
+
+	mu      sync.Mutex
+	pending map[voxel.ChunkCoord]map[blockPos]*pendingEdit
+}
+
+// blockPos is a block position in world coordinates
+type blockPos struct {
+	X, Y, Z int32
+}
+
+// pendingEdit is a predicted block the server hasn't confirmed yet
+type pendingEdit struct {
+	state     voxel.BlockState // Predicted state
+	previous  voxel.BlockState // Last state known from the server
+	predicted time.Time        // When the latest prediction was made
+}
+
+// NewPredictor creates a predictor sending edits through sender and mirroring chunks into world,
+// a new world if nil. Edits unconfirmed after timeout are rolled back, DefaultConfirmTimeout if zero.
+func NewPredictor(sender BlockSender, world *voxel.World, timeout time.Duration) *Predictor {
+	if world == nil {
+		world = voxel.NewWorld(ChunkSize)
+	}
+	if timeout <= 0 {
+		timeout = DefaultConfirmTimeout
+	}
+	return &Predictor{
+		sender:  sender,
+		world:   world,
+		timeout: timeout,
+		pending: make(map[voxel.ChunkCoord]map[blockPos]*pendingEdit),
+	}
+}
+
+// World returns the local world, including predicted edits.
+// Chunks are only modified by the goroutines calling Predictor methods.
+func (p *Predictor) World() *voxel.World {
+	return p.world
+}
+
+// Pending returns the number of edits waiting for confirmation
+func (p *Predictor) Pending() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	count := 0
+	for _, edits := range p.pending {
+		count += len(edits)
+	}
+	return count
+}
+
+// SetBlock places a block locally and sends it to the server.
+// Blocks in chunks not received yet are only sent.
+func (p *Predictor) SetBlock(x, y, z int32, blockType voxel.BlockType) error {
+	return p.predict(x, y, z, voxel.BlockState(blockType), func() error {
+		return p.sender.SendUpdateBlock(blockType, x, y, z)
+	})
+}
+
+// SetState places a block state locally and sends it to the server.
+// Blocks in chunks not received yet are only sent.
+func (p *Predictor) SetState(x, y, z int32, state voxel.BlockState) error {
+	return p.predict(x, y, z, state, func() error {
+		return p.sender.SendUpdateBlockState(state, x, y, z)
+	})
+}
+
+// predict applies an edit locally and sends it, undoing it if the send fails.
+// Edits in chunks the predictor doesn't hold are only sent.
+func (p *Predictor) predict(x, y, z int32, state voxel.BlockState, send func() error) error {
+	coord := voxel.WorldToChunkCoord(x, y, z, p.world.ChunkSize())
+	pos := blockPos{X: x, Y: y, Z: z}
+
+	p.mu.Lock()
+	// Predicting would create an empty chunk, which no rollback removes
+	if p.world.Chunk(coord) == nil {
+		p.mu.Unlock()
+		return send()
+	}
+	edits := p.pending[coord]
+	if edits == nil {
+		edits = make(map[blockPos]*pendingEdit)
+		p.pending[coord] = edits
+	}
+	edit, existed := edits[pos]
+	var undo pendingEdit
+	if existed {
+		undo = *edit
+	} else {
+		edit = &pendingEdit{previous: p.world.GetState(x, y, z)}
+		edits[pos] = edit
+	}
+	edit.state, edit.predicted = state, time.Now()
+	p.world.SetState(x, y, z, state)
+	p.mu.Unlock()
+	p.changed(coord)
+
+	if err := send(); err != nil {
+		p.mu.Lock()
+		// Unless a chunk from the server already settled it
+		if p.pending[coord][pos] == edit {
+			if existed {
+				*edit = undo
+				p.world.SetState(x, y, z, undo.state)
+			} else {
+				p.world.SetState(x, y, z, edit.previous)
+				p.forget(coord, pos)
+			}
+		}
+		p.mu.Unlock()
+		p.changed(coord)
+		return err
+	}
+	return nil
+}
+
+// forget drops a pending edit. The caller must hold p.mu.
+func (p *Predictor) forget(coord voxel.ChunkCoord, pos blockPos) {
+	delete(p.pending[coord], pos)
+	if len(p.pending[coord]) == 0 {
+		delete(p.pending, coord)
+	}
+}
+
+// Follow applies every event of the subscription until its channel is closed.
+// Run it in its own goroutine: go predictor.Follow(client.Subscribe(SubscribeOptions{}))
+func (p *Predictor) Follow(sub *Subscription) {
+	for ev := range sub.Events() {
+		p.Apply(ev)
+	}
+}
+
+// Apply stores chunks and block changes received from the server and reconciles
+// the pending edits they cover, and forgets unloaded chunks. Other events are ignored.
+func (p *Predictor) Apply(ev Event) {
+	size := p.world.ChunkSize()
+	var chunk *voxel.Chunk
+	switch ev := ev.(type) {
+	case BlocksChangedEvent:
+		p.applyChanges(ev.Chunk, ev.Changes)
+		return
+	case ChunkUnloadedEvent:
+		p.unload(voxel.ChunkCoord{X: ev.X, Y: ev.Y, Z: ev.Z})
+		return
+	case ChunkEvent:
+		// The event's slices are shared with other subscribers, and predictions modify the chunk
+		blocks := append([]voxel.BlockType(nil), ev.Blocks...)
+		var props []uint8
+		if ev.Props != nil {
+			props = append([]uint8(nil), ev.Props...)
+		}
+		chunk = voxel.NewChunkFromStates(ev.X, ev.Y, ev.Z, size, blocks, props)
+	case MonoChunkEvent:
+		chunk = voxel.NewChunk(ev.X, ev.Y, ev.Z, size)
+		chunk.FillWithBlockType(ev.BlockType)
+	default:
+		return
+	}
+	coord := voxel.ChunkCoord{X: chunk.X, Y: chunk.Y, Z: chunk.Z}
+
+	p.mu.Lock()
+	now := time.Now()
+	for pos := range p.pending[coord] {
+		lx, ly, lz := voxel.WorldToLocalCoord(pos.X, pos.Y, pos.Z, size)
+		actual := chunk.GetState(lx, ly, lz)
+		if state := p.settle(coord, pos, actual, now); state != actual {
+			chunk.SetState(lx, ly, lz, state)
+		}
+	}
+	p.world.SetChunk(chunk)
+	p.mu.Unlock()
+	p.changed(coord)
+}
+
+// applyChanges writes block changes into a chunk the predictor holds
+func (p *Predictor) applyChanges(coord voxel.ChunkCoord, changes []BlockChange) {
+	// The server only sends changes for chunks it sent before, so a missing chunk
+	// means the predictor started following late
+	if p.world.Chunk(coord) == nil {
+		return
+	}
+
+	p.mu.Lock()
+	now := time.Now()
+	for _, change := range changes {
+		pos := blockPos{X: change.X, Y: change.Y, Z: change.Z}
+		p.world.SetState(change.X, change.Y, change.Z, p.settle(coord, pos, change.State, now))
+	}
+	p.mu.Unlock()
+	p.changed(coord)
+}
+
+// unload removes a chunk and its pending edits, which the server won't confirm anymore
+func (p *Predictor) unload(coord voxel.ChunkCoord) {
+	p.mu.Lock()
+	delete(p.pending, coord)
+	p.world.RemoveChunk(coord)
+	p.mu.Unlock()
+	p.changed(coord)
+}
+
+// settle reconciles the pending edit of a block, if any, with the state the server sent for it
+// and returns the state the block should show. The caller must hold p.mu.
+func (p *Predictor) settle(coord voxel.ChunkCoord, pos blockPos, actual voxel.BlockState, now time.Time) voxel.BlockState {
+	edit, ok := p.pending[coord][pos]
+	switch {
+	case !ok:
+		return actual
+	case actual == edit.state:
+		p.forget(coord, pos)
+		return actual
+	case now.Sub(edit.predicted) < p.timeout:
+		// Probably sent before the server applied the edit
+		edit.previous = actual
+		return edit.state
+	default:
+		p.forget(coord, pos)
+		return actual
+	}
+}
+
+// Expire rolls back the edits the server hasn't confirmed within the timeout,
+// restoring the last state it sent for them
+func (p *Predictor) Expire() {
+	var changed []voxel.ChunkCoord
+
+	p.mu.Lock()
+	now := time.Now()
+	for coord, edits := range p.pending {
+		for pos, edit := range edits {
+			if now.Sub(edit.predicted) < p.timeout {
+				continue
+			}
+			p.world.SetState(pos.X, pos.Y, pos.Z, edit.previous)
+			p.forget(coord, pos)
+			if len(changed) == 0 || changed[len(changed)-1] != coord {
+				changed = append(changed, coord)
+			}
+		}
+	}
+	p.mu.Unlock()
+
+	for _, coord := range changed {
+		p.changed(coord)
+	}
+}
+
+// changed calls OnChunkChanged if set
+func (p *Predictor) changed(coord voxel.ChunkCoord) {
+	if p.OnChunkChanged != nil {
+		p.OnChunkChanged(coord)
+	}
+}
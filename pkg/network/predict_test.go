@@ -0,0 +1,68 @@
+package network
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/leterax/go-voxels/pkg/voxel"
+)
+
+// recordingSender remembers the states sent, failing with err if set
+type recordingSender struct {
+	sent []voxel.BlockState
+	err  error
+}
+
+func (s *recordingSender) SendUpdateBlock(blockType voxel.BlockType, x, y, z int32) error {
+	return s.SendUpdateBlockState(voxel.BlockState(blockType), x, y, z)
+}
+
+func (s *recordingSender) SendUpdateBlockState(state voxel.BlockState, x, y, z int32) error {
+	s.sent = append(s.sent, state)
+	return s.err
+}
+
+func TestPredictorSkipsUnloadedChunks(t *testing.T) {
+	sender := &recordingSender{}
+	p := NewPredictor(sender, nil, time.Millisecond)
+	if err := p.SetBlock(40, 5, -3, voxel.Stone); err != nil {
+		t.Fatal(err)
+	}
+	if len(sender.sent) != 1 {
+		t.Errorf("%d edits sent, want the edit sent anyway", len(sender.sent))
+	}
+	if p.Pending() != 0 {
+		t.Errorf("%d pending edits, want none outside loaded chunks", p.Pending())
+	}
+	if chunks := p.World().ChunkCoords(); len(chunks) != 0 {
+		t.Errorf("prediction created chunks %v", chunks)
+	}
+}
+
+func TestPredictorRollsBack(t *testing.T) {
+	sender := &recordingSender{}
+	p := NewPredictor(sender, nil, time.Millisecond)
+	p.Apply(MonoChunkEvent{BlockType: voxel.Dirt})
+
+	if err := p.SetBlock(1, 2, 3, voxel.Stone); err != nil {
+		t.Fatal(err)
+	}
+	if got := p.World().GetBlock(1, 2, 3); got != voxel.Stone || p.Pending() != 1 {
+		t.Fatalf("block %v with %d pending edits, want the predicted stone", got, p.Pending())
+	}
+	time.Sleep(2 * time.Millisecond)
+	p.Expire()
+	if got := p.World().GetBlock(1, 2, 3); got != voxel.Dirt || p.Pending() != 0 {
+		t.Errorf("block %v with %d pending edits after the timeout, want dirt back", got, p.Pending())
+	}
+
+	// A failed send is undone right away
+	sender.err = errors.New("offline")
+	if err := p.SetBlock(1, 2, 3, voxel.Glass); !errors.Is(err, sender.err) {
+		t.Fatalf("got %v, want the send error", err)
+	}
+	if got := p.World().GetBlock(1, 2, 3); got != voxel.Dirt || p.Pending() != 0 {
+		t.Errorf("block %v with %d pending edits after a failed send, want dirt", got, p.Pending())
+	}
+}
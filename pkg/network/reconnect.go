@@ -0,0 +1,432 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/leterax/go-voxels/pkg/network/packet"
+	"github.com/leterax/go-voxels/pkg/voxel"
+)
+
+// ErrNotConnected is returned when sending while the reconnecting client has no connection
+var ErrNotConnected = errors.New("not connected")
+
+// ConnectedEvent is published by ReconnectingClient after every successful (re)connection,
+// once the metadata and last known position were sent
+type ConnectedEvent struct {
+	Attempt int // Failed attempts before this connection
+}
+
+// DisconnectedEvent is published by ReconnectingClient when the connection drops.
+// Before it, an EntityRemovedEvent is published for every entity the server had announced.
+type DisconnectedEvent struct {
+	Err error
+}
+
+// ChunksStaleEvent lists the chunks received before a reconnect.
+// Their contents may be outdated until the server sends them again.
+type ChunksStaleEvent struct {
+	Chunks []voxel.ChunkCoord
+}
+
+func (ConnectedEvent) event()    {}
+func (DisconnectedEvent) event() {}
+func (ChunksStaleEvent) event()  {}
+
+// ReconnectOptions configures the backoff between connection attempts
+type ReconnectOptions struct {
+	MinBackoff  time.Duration // First retry delay, 250ms if zero
+	MaxBackoff  time.Duration // Upper bound on the delay, 30s if zero
+	Multiplier  float64       // Growth factor per failed attempt, 2 if zero
+	Jitter      float64       // Random spread as a fraction of the delay, 0.2 if zero
+	MaxAttempts int           // Consecutive failures before giving up, unlimited if zero
+	StableAfter time.Duration // Connection time after which failures are forgotten, 10s if zero
+}
+
+// DefaultReconnectOptions returns the options used for zero fields
+func DefaultReconnectOptions() ReconnectOptions {
+	return ReconnectOptions{
+		MinBackoff:  250 * time.Millisecond,
+		MaxBackoff:  30 * time.Second,
+		Multiplier:  2,
+		Jitter:      0.2,
+		StableAfter: 10 * time.Second,
+	}
+}
+
+// backoff returns the delay before the given retry, starting at 0
+func (o ReconnectOptions) backoff(attempt int) time.Duration {
+	delay := float64(o.MinBackoff) * math.Pow(o.Multiplier, float64(attempt))
+	delay = min(delay, float64(o.MaxBackoff))
+	delay *= 1 + o.Jitter*(2*rand.Float64()-1)
+	return time.Duration(delay)
+}
+
+// ReconnectingClient keeps a Client connected to a server, redialling with exponential backoff
+// and jitter whenever the connection drops. After reconnecting it resends the client metadata and
+// the last known position. Subscribers see EntityRemovedEvent for every known entity, followed by
+// DisconnectedEvent, then ChunksStaleEvent and ConnectedEvent once the new connection is up.
+type ReconnectingClient struct {
+	eventHub // Subscribe and Err
+
+	address string
+	options ReconnectOptions
+
+	mu         sync.Mutex
+	client     *Client
+	name       string
+	renderDist uint8
+	position   *packet.Position
+	running    bool
+	cancel     context.CancelFunc
+	closed     bool
+
+	// Only touched by the Run goroutine
+	entities map[uint32]struct{}
+	chunks   map[voxel.ChunkCoord]struct{}
+	stale    map[voxel.ChunkCoord]struct{}
+}
+
+// NewReconnectingClient creates a client for the server at address. It connects once Run is called.
+func NewReconnectingClient(address string, options ReconnectOptions) *ReconnectingClient {
+	defaults := DefaultReconnectOptions()
+	if options.MinBackoff <= 0 {
+		options.MinBackoff = defaults.MinBackoff
+	}
+	if options.MaxBackoff <= 0 {
+		options.MaxBackoff = defaults.MaxBackoff
+	}
+	if options.Multiplier <= 0 {
+		options.Multiplier = defaults.Multiplier
+	}
+	if options.Jitter <= 0 {
+		options.Jitter = defaults.Jitter
+	}
+	if options.StableAfter <= 0 {
+		options.StableAfter = defaults.StableAfter
+	}
+
+	return &ReconnectingClient{
+		address:    address,
+		options:    options,
+		renderDist: 8, // Default render distance
+		entities:   make(map[uint32]struct{}),
+		chunks:     make(map[voxel.ChunkCoord]struct{}),
+		stale:      make(map[voxel.ChunkCoord]struct{}),
+	}
+}
+
+// SetEntityName sets the name sent on every connection
+func (c *ReconnectingClient) SetEntityName(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.name = name
+}
+
+// SetRenderDistance sets the render distance sent on every connection
+func (c *ReconnectingClient) SetRenderDistance(distance uint8) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.renderDist = distance
+}
+
+// Client returns the current connection, or nil while disconnected
+func (c *ReconnectingClient) Client() *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.client
+}
+
+// EntityID returns the entity ID of the current connection, 0 while disconnected
+func (c *ReconnectingClient) EntityID() uint32 {
+	if client := c.Client(); client != nil {
+		return client.EntityID()
+	}
+	return 0
+}
+
+// Stats returns the statistics of the current connection, zero while disconnected
+func (c *ReconnectingClient) Stats() Stats {
+	if client := c.Client(); client != nil {
+		return client.Stats()
+	}
+	return Stats{}
+}
+
+// Run connects and keeps reconnecting until ctx is cancelled, Close is called
+// or MaxAttempts consecutive attempts failed. It can only be called once.
+func (c *ReconnectingClient) Run(ctx context.Context) error {
+	c.mu.Lock()
+	if c.running {
+		c.mu.Unlock()
+		return ErrAlreadyRunning
+	}
+	if c.closed {
+		c.mu.Unlock()
+		c.finish(ErrClientClosed)
+		return ErrClientClosed
+	}
+	c.running = true
+	ctx, c.cancel = context.WithCancel(ctx)
+	c.mu.Unlock()
+
+	err := c.run(ctx)
+
+	c.mu.Lock()
+	if c.closed {
+		err = ErrClientClosed
+	}
+	c.cancel()
+	c.mu.Unlock()
+
+	c.finish(err)
+	return err
+}
+
+func (c *ReconnectingClient) run(ctx context.Context) error {
+	failures := 0
+	for {
+		client, err := c.connect(ctx)
+		failed := true
+		if err == nil {
+			if failures > 0 {
+				c.markChunksStale(ctx)
+			}
+			c.publish(ctx, ConnectedEvent{Attempt: failures})
+			connected := time.Now()
+
+			err = c.serve(ctx, client)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			c.disconnected(ctx, err)
+			// A connection dropped soon after the handshake is one more failed attempt,
+			// one that stayed up counts as the first failure of the next round
+			if time.Since(connected) >= c.options.StableAfter {
+				failures, failed = 0, false
+			}
+		} else {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			// Retrying won't change the server's protocol version
+			if errors.Is(err, ErrIncompatibleVersion) {
+				return err
+			}
+		}
+		failures++
+		if failed && c.options.MaxAttempts > 0 && failures >= c.options.MaxAttempts {
+			return fmt.Errorf("giving up after %d attempts: %w", failures, err)
+		}
+
+		timer := time.NewTimer(c.options.backoff(failures - 1))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// connect dials the server and sends the metadata and last known position
+func (c *ReconnectingClient) connect(ctx context.Context) (*Client, error) {
+	client, err := NewClientContext(ctx, c.address, packet.SupportedCapabilities)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	client.SetEntityName(c.name)
+	client.SetRenderDistance(c.renderDist)
+	position := c.position
+	c.mu.Unlock()
+
+	if err := client.SendClientMetadata(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to send client metadata: %w", err)
+	}
+	if position != nil {
+		err := client.SendUpdateEntity(position.X, position.Y, position.Z, position.Yaw, position.Pitch)
+		if err != nil {
+			client.Close()
+			return nil, fmt.Errorf("failed to send position: %w", err)
+		}
+	}
+
+	c.mu.Lock()
+	c.client = client
+	c.mu.Unlock()
+	return client, nil
+}
+
+// serve runs the connection, forwarding its events until it ends
+func (c *ReconnectingClient) serve(ctx context.Context, client *Client) error {
+	sub := client.Subscribe(SubscribeOptions{Backpressure: BackpressureBlock})
+	done := make(chan error, 1)
+	go func() { done <- client.Run(ctx) }()
+
+	for ev := range sub.Events() {
+		c.track(ev)
+		c.publish(ctx, ev)
+	}
+	err := <-done
+
+	c.mu.Lock()
+	c.client = nil
+	c.mu.Unlock()
+	return err
+}
+
+// track remembers announced entities and received chunks for the next reconnect
+func (c *ReconnectingClient) track(ev Event) {
+	switch ev := ev.(type) {
+	case EntityAddedEvent:
+		c.entities[ev.EntityID] = struct{}{}
+	case EntityRemovedEvent:
+		delete(c.entities, ev.EntityID)
+	case ChunkEvent:
+		coord := voxel.ChunkCoord{X: ev.X, Y: ev.Y, Z: ev.Z}
+		c.chunks[coord] = struct{}{}
+		delete(c.stale, coord)
+	case MonoChunkEvent:
+		coord := voxel.ChunkCoord{X: ev.X, Y: ev.Y, Z: ev.Z}
+		c.chunks[coord] = struct{}{}
+		delete(c.stale, coord)
+	case ChunkUnloadedEvent:
+		coord := voxel.ChunkCoord{X: ev.X, Y: ev.Y, Z: ev.Z}
+		delete(c.chunks, coord)
+		delete(c.stale, coord)
+	}
+}
+
+// disconnected clears every known entity and reports the dropped connection
+func (c *ReconnectingClient) disconnected(ctx context.Context, err error) {
+	for entityID := range c.entities {
+		c.publish(ctx, EntityRemovedEvent{EntityID: entityID})
+	}
+	clear(c.entities)
+	c.publish(ctx, DisconnectedEvent{Err: err})
+}
+
+// markChunksStale reports every chunk received so far as outdated
+func (c *ReconnectingClient) markChunksStale(ctx context.Context) {
+	for coord := range c.chunks {
+		c.stale[coord] = struct{}{}
+	}
+	clear(c.chunks)
+	if len(c.stale) == 0 {
+		return
+	}
+
+	chunks := make([]voxel.ChunkCoord, 0, len(c.stale))
+	for coord := range c.stale {
+		chunks = append(chunks, coord)
+	}
+	c.publish(ctx, ChunksStaleEvent{Chunks: chunks})
+}
+
+// Close stops Run and closes the current connection
+func (c *ReconnectingClient) Close() error {
+	c.mu.Lock()
+	c.closed = true
+	cancel := c.cancel
+	client := c.client
+	c.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
+	if client != nil {
+		return client.Close()
+	}
+	return nil
+}
+
+// send runs fn on the current connection
+func (c *ReconnectingClient) send(fn func(client *Client) error) error {
+	client := c.Client()
+	if client == nil {
+		return ErrNotConnected
+	}
+	return fn(client)
+}
+
+// SendClientMetadata sends the current name and render distance
+func (c *ReconnectingClient) SendClientMetadata() error {
+	c.mu.Lock()
+	name, renderDist := c.name, c.renderDist
+	c.mu.Unlock()
+
+	return c.send(func(client *Client) error {
+		client.SetEntityName(name)
+		client.SetRenderDistance(renderDist)
+		return client.SendClientMetadata()
+	})
+}
+
+// SendUpdateEntity sends the position and remembers it for the next reconnect,
+// even while disconnected
+func (c *ReconnectingClient) SendUpdateEntity(x, y, z, yaw, pitch float32) error {
+	c.mu.Lock()
+	c.position = &packet.Position{X: x, Y: y, Z: z, Yaw: yaw, Pitch: pitch}
+	c.mu.Unlock()
+
+	return c.send(func(client *Client) error {
+		return client.SendUpdateEntity(x, y, z, yaw, pitch)
+	})
+}
+
+// SendUpdateBlock sends a block update on the current connection
+func (c *ReconnectingClient) SendUpdateBlock(blockType voxel.BlockType, x, y, z int32) error {
+	return c.send(func(client *Client) error {
+		return client.SendUpdateBlock(blockType, x, y, z)
+	})
+}
+
+// SendUpdateBlockState sends a block state update on the current connection
+func (c *ReconnectingClient) SendUpdateBlockState(state voxel.BlockState, x, y, z int32) error {
+	return c.send(func(client *Client) error {
+		return client.SendUpdateBlockState(state, x, y, z)
+	})
+}
+
+// SendBlockBulkEdit sends multiple block updates on the current connection
+func (c *ReconnectingClient) SendBlockBulkEdit(updates []BlockUpdate) error {
+	return c.send(func(client *Client) error {
+		return client.SendBlockBulkEdit(updates)
+	})
+}
+
+// DropChunk discards a chunk on the current connection
+func (c *ReconnectingClient) DropChunk(x, y, z int32) error {
+	return c.send(func(client *Client) error {
+		return client.DropChunk(x, y, z)
+	})
+}
+
+// Ping measures the round trip of the current connection
+func (c *ReconnectingClient) Ping() error {
+	return c.send(func(client *Client) error {
+		return client.Ping()
+	})
+}
+
+// RequestChunk asks for a chunk on the current connection
+func (c *ReconnectingClient) RequestChunk(x, y, z int32) error {
+	return c.send(func(client *Client) error {
+		return client.RequestChunk(x, y, z)
+	})
+}
+
+// SendChat sends a chat message on the current connection
+func (c *ReconnectingClient) SendChat(message string) error {
+	return c.send(func(client *Client) error {
+		return client.SendChat(message)
+	})
+}
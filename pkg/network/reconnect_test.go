@@ -0,0 +1,172 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/leterax/go-voxels/pkg/network/packet"
+)
+
+// standIn runs a TCP server handing every connection to handle, and returns its address
+func standIn(t *testing.T, handle func(conn net.Conn)) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				handle(conn)
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// acceptHello does the server's side of the handshake, enabling no capabilities
+func acceptHello(conn net.Conn) error {
+	if _, err := packet.Read(conn, packet.ServerBound); err != nil {
+		return err
+	}
+	return packet.Write(conn, &packet.ServerHello{Version: packet.ProtocolVersion, MinVersion: packet.MinProtocolVersion})
+}
+
+// fastBackoff returns options retrying within milliseconds, without jitter
+func fastBackoff() ReconnectOptions {
+	return ReconnectOptions{
+		MinBackoff: 5 * time.Millisecond,
+		MaxBackoff: time.Second,
+		Jitter:     1e-9,
+	}
+}
+
+// connectedAttempts runs c and returns the Attempt of the first n ConnectedEvents
+func connectedAttempts(t *testing.T, c *ReconnectingClient, n int) []int {
+	t.Helper()
+	sub := c.Subscribe(SubscribeOptions{})
+	go c.Run(context.Background())
+	t.Cleanup(func() { c.Close() })
+
+	var attempts []int
+	for len(attempts) < n {
+		attempts = append(attempts, nextEvent[ConnectedEvent](t, sub).Attempt)
+	}
+	return attempts
+}
+
+func TestReconnectBacksOffWhenDroppedAfterHandshake(t *testing.T) {
+	address := standIn(t, func(conn net.Conn) {
+		acceptHello(conn)
+	})
+	c := NewReconnectingClient(address, fastBackoff())
+
+	attempts := connectedAttempts(t, c, 4)
+	for i, attempt := range attempts {
+		if attempt != i {
+			t.Fatalf("connections followed %v failed attempts, want 0, 1, 2, 3", attempts)
+		}
+	}
+}
+
+func TestReconnectResetsAfterStableConnection(t *testing.T) {
+	address := standIn(t, func(conn net.Conn) {
+		if acceptHello(conn) == nil {
+			time.Sleep(50 * time.Millisecond)
+		}
+	})
+	options := fastBackoff()
+	options.StableAfter = 20 * time.Millisecond
+	c := NewReconnectingClient(address, options)
+
+	attempts := connectedAttempts(t, c, 3)
+	if want := []int{0, 1, 1}; attempts[0] != want[0] || attempts[1] != want[1] || attempts[2] != want[2] {
+		t.Errorf("connections followed %v failed attempts, want %v", attempts, want)
+	}
+}
+
+func TestReconnectGivesUp(t *testing.T) {
+	address := standIn(t, func(conn net.Conn) {
+		acceptHello(conn)
+	})
+	options := fastBackoff()
+	options.MaxAttempts = 3
+	c := NewReconnectingClient(address, options)
+
+	done := make(chan error, 1)
+	go func() { done <- c.Run(context.Background()) }()
+	select {
+	case err := <-done:
+		if err == nil || !strings.HasPrefix(err.Error(), "giving up after 3 attempts") {
+			t.Errorf("got %v, want to give up after 3 attempts", err)
+		}
+	case <-time.After(5 * time.Second):
+		c.Close()
+		t.Fatal("still retrying a server that drops every connection")
+	}
+}
+
+func TestReconnectStopsDuringHandshake(t *testing.T) {
+	tests := []struct {
+		name string
+		stop func(c *ReconnectingClient, cancel context.CancelFunc)
+		want error
+	}{
+		{"close", func(c *ReconnectingClient, _ context.CancelFunc) { c.Close() }, ErrClientClosed},
+		{"cancel", func(_ *ReconnectingClient, cancel context.CancelFunc) { cancel() }, context.Canceled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			accepted := make(chan struct{}, 1)
+			// A server that never says hello
+			address := standIn(t, func(conn net.Conn) {
+				accepted <- struct{}{}
+				io.Copy(io.Discard, conn)
+			})
+			c := NewReconnectingClient(address, fastBackoff())
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			done := make(chan error, 1)
+			go func() { done <- c.Run(ctx) }()
+			<-accepted
+			tt.stop(c, cancel)
+			select {
+			case err := <-done:
+				if !errors.Is(err, tt.want) {
+					t.Errorf("got %v, want %v", err, tt.want)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("Run waits for the handshake timeout")
+			}
+		})
+	}
+}
+
+func TestNewClientContextCancelled(t *testing.T) {
+	// A server that never says hello
+	address := standIn(t, func(conn net.Conn) {
+		io.Copy(io.Discard, conn)
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := NewClientContext(ctx, address, packet.SupportedCapabilities); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want the context's error", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("returned after %v", elapsed)
+	}
+}
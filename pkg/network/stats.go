@@ -0,0 +1,239 @@
+package network
+
+import (
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/leterax/go-voxels/pkg/network/packet"
+	"github.com/leterax/go-voxels/pkg/voxel"
+)
+
+// Statistics tuning
+const (
+	chunkRateWindow = 5                // Seconds averaged by Stats.ChunkRate
+	maxEchoProbes   = 64               // Sent packets waiting for their echo, per kind
+	echoTimeout     = 30 * time.Second // Probes unanswered for this long are forgotten
+)
+
+// PacketStats is the traffic of one packet ID
+type PacketStats struct {
+	Packets uint64
+	Bytes   uint64 // Encoded, including length prefixes
+	// DecodeTime is the total time spent decoding received packets, including decompressing chunks.
+	// It is zero for sent packets.
+	DecodeTime time.Duration
+}
+
+// Stats is a snapshot of a client's connection, see Client.Stats
+type Stats struct {
+	Uptime time.Duration // Since the connection was established
+
+	Received        map[uint8]PacketStats // Per clientbound packet ID
+	Sent            map[uint8]PacketStats // Per serverbound packet ID
+	PacketsReceived uint64
+	PacketsSent     uint64
+	BytesReceived   uint64
+	BytesSent       uint64
+	DecodeTime      time.Duration // Total over every received packet
+
+	Chunks    uint64  // Full and single-type chunks received
+	ChunkRate float64 // Chunks per second over the last few seconds
+
+	// Round trips are measured with Client.Ping and estimated from packets the server answers:
+	// the client's entity placed after the first Client Metadata and block edits confirmed by
+	// Block Change or Multi Block Change. RTT is smoothed like TCP's, both are 0 until measured.
+	RTT        time.Duration
+	LastRTT    time.Duration
+	RTTSamples uint64
+}
+
+// echoProbe is a sent packet waiting for the server to echo it
+type echoProbe struct {
+	sent  time.Time
+	state voxel.BlockState // Of block edits
+}
+
+// statsTracker counts a client's traffic. It is safe for concurrent use.
+type statsTracker struct {
+	mu    sync.Mutex
+	start time.Time
+	stats Stats
+
+	chunkBuckets [chunkRateWindow]struct {
+		second int64
+		count  int
+	}
+
+	spawned   bool      // The server placed the client's entity
+	spawnSent time.Time // First Client Metadata, zero if not waiting for the spawn
+	pings     map[uint32]echoProbe
+	blocks    map[[3]int32]echoProbe
+}
+
+func newStatsTracker() *statsTracker {
+	return &statsTracker{
+		start: time.Now(),
+		stats: Stats{
+			Received: make(map[uint8]PacketStats),
+			Sent:     make(map[uint8]PacketStats),
+		},
+		pings:  make(map[uint32]echoProbe),
+		blocks: make(map[[3]int32]echoProbe),
+	}
+}
+
+// sent counts a packet written to the connection and remembers it if the server may echo it
+func (t *statsTracker) sent(p packet.Packet, size int, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	count := t.stats.Sent[p.ID()]
+	count.Packets++
+	count.Bytes += uint64(size)
+	t.stats.Sent[p.ID()] = count
+	t.stats.PacketsSent++
+	t.stats.BytesSent += uint64(size)
+
+	switch p := p.(type) {
+	case *packet.ClientMetadata:
+		if !t.spawned && t.spawnSent.IsZero() {
+			t.spawnSent = now
+		}
+	case *packet.Ping:
+		addProbe(t.pings, p.Token, echoProbe{sent: now})
+	case *packet.UpdateBlock:
+		t.probeBlock(p.X, p.Y, p.Z, voxel.BlockState(p.BlockType), now)
+	case *packet.UpdateBlockState:
+		t.probeBlock(p.X, p.Y, p.Z, p.State, now)
+	case *packet.BlockBulkEdit:
+		// Larger edits come back as whole chunks, one probe is enough to catch the small ones
+		if len(p.Updates) > 0 {
+			update := p.Updates[0]
+			t.probeBlock(update.X, update.Y, update.Z, voxel.BlockState(update.BlockType), now)
+		}
+	}
+}
+
+func (t *statsTracker) probeBlock(x, y, z int32, state voxel.BlockState, now time.Time) {
+	addProbe(t.blocks, [3]int32{x, y, z}, echoProbe{sent: now, state: state})
+}
+
+// addProbe remembers a sent packet, forgetting expired probes when there are too many
+func addProbe[K comparable](probes map[K]echoProbe, key K, probe echoProbe) {
+	if len(probes) >= maxEchoProbes {
+		for key, old := range probes {
+			if probe.sent.Sub(old.sent) > echoTimeout {
+				delete(probes, key)
+			}
+		}
+		if len(probes) >= maxEchoProbes {
+			return
+		}
+	}
+	probes[key] = probe
+}
+
+// received counts a packet read from the connection and matches echoes of sent packets.
+// self is the client's entity ID.
+func (t *statsTracker) received(p packet.Packet, size int, decodeTime time.Duration, self uint32, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	count := t.stats.Received[p.ID()]
+	count.Packets++
+	count.Bytes += uint64(size)
+	count.DecodeTime += decodeTime
+	t.stats.Received[p.ID()] = count
+	t.stats.PacketsReceived++
+	t.stats.BytesReceived += uint64(size)
+	t.stats.DecodeTime += decodeTime
+
+	switch p := p.(type) {
+	case *packet.SendChunk, *packet.SendChunkStates, *packet.SendCompressedChunk, *packet.SendMonoTypeChunk:
+		t.countChunk(now)
+	case *packet.UpdateEntityPosition:
+		if p.EntityID == self && !t.spawned {
+			t.spawned = true
+			if !t.spawnSent.IsZero() {
+				t.sample(now.Sub(t.spawnSent))
+			}
+		}
+	case *packet.Pong:
+		if probe, ok := t.pings[p.Token]; ok {
+			delete(t.pings, p.Token)
+			t.sample(now.Sub(probe.sent))
+		}
+	case *packet.BlockChange:
+		t.confirmBlock([3]int32{p.X, p.Y, p.Z}, p.State, now)
+	case *packet.MultiBlockChange:
+		for _, change := range p.Changes {
+			t.confirmBlock([3]int32{
+				p.X*ChunkSize + int32(change.X),
+				p.Y*ChunkSize + int32(change.Y),
+				p.Z*ChunkSize + int32(change.Z),
+			}, change.State, now)
+		}
+	}
+}
+
+// addDecodeTime adds work done on a packet after it was counted, like decompressing a chunk
+func (t *statsTracker) addDecodeTime(id uint8, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	count := t.stats.Received[id]
+	count.DecodeTime += d
+	t.stats.Received[id] = count
+	t.stats.DecodeTime += d
+}
+
+func (t *statsTracker) confirmBlock(pos [3]int32, state voxel.BlockState, now time.Time) {
+	if probe, ok := t.blocks[pos]; ok && probe.state == state {
+		delete(t.blocks, pos)
+		t.sample(now.Sub(probe.sent))
+	}
+}
+
+// sample adds a round trip measurement. The caller must hold t.mu.
+func (t *statsTracker) sample(rtt time.Duration) {
+	if t.stats.RTTSamples == 0 {
+		t.stats.RTT = rtt
+	} else {
+		t.stats.RTT += (rtt - t.stats.RTT) / 8
+	}
+	t.stats.LastRTT = rtt
+	t.stats.RTTSamples++
+}
+
+// countChunk adds a chunk to the current second. The caller must hold t.mu.
+func (t *statsTracker) countChunk(now time.Time) {
+	t.stats.Chunks++
+	second := now.Unix()
+	bucket := &t.chunkBuckets[second%chunkRateWindow]
+	if bucket.second != second {
+		bucket.second, bucket.count = second, 0
+	}
+	bucket.count++
+}
+
+// snapshot returns a copy of the statistics
+func (t *statsTracker) snapshot(now time.Time) Stats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats := t.stats
+	stats.Received = maps.Clone(t.stats.Received)
+	stats.Sent = maps.Clone(t.stats.Sent)
+	stats.Uptime = now.Sub(t.start)
+
+	// Average over the window, or the time connected if that is shorter
+	chunks := 0
+	for _, bucket := range t.chunkBuckets {
+		if now.Unix()-bucket.second < chunkRateWindow {
+			chunks += bucket.count
+		}
+	}
+	window := min(stats.Uptime.Seconds(), chunkRateWindow)
+	stats.ChunkRate = float64(chunks) / max(window, 1)
+	return stats
+}
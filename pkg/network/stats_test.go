@@ -0,0 +1,32 @@
+package network
+
+import (
+	"testing"
+	"time"
+
+	"github.com/leterax/go-voxels/pkg/network/packet"
+)
+
+func TestStatsRoundTrip(t *testing.T) {
+	tracker := newStatsTracker()
+	start := time.Now()
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+
+	tracker.sent(&packet.ClientMetadata{}, 70, at(0))
+	tracker.received(&packet.UpdateEntityPosition{EntityID: 3}, 25, 0, 3, at(40))
+	tracker.sent(&packet.Ping{Token: 1}, 5, at(100))
+	tracker.sent(&packet.Ping{Token: 2}, 5, at(110))
+	tracker.received(&packet.Pong{Token: 2}, 5, 0, 3, at(130))
+	tracker.received(&packet.Pong{Token: 9}, 5, 0, 3, at(140)) // Never sent
+
+	stats := tracker.snapshot(at(200))
+	if stats.RTTSamples != 2 || stats.LastRTT != 20*time.Millisecond {
+		t.Errorf("%d samples, last %v, want the spawn and one pong of 20ms", stats.RTTSamples, stats.LastRTT)
+	}
+	if want := 40*time.Millisecond + (20*time.Millisecond-40*time.Millisecond)/8; stats.RTT != want {
+		t.Errorf("smoothed RTT %v, want %v", stats.RTT, want)
+	}
+	if stats.PacketsSent != 3 || stats.Sent[packet.IDPing].Bytes != 10 {
+		t.Errorf("%d packets sent, %d bytes of pings", stats.PacketsSent, stats.Sent[packet.IDPing].Bytes)
+	}
+}
@@ -0,0 +1,52 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/leterax/go-voxels/pkg/network/websocket"
+)
+
+// Dial opens the transport to a server. ws:// and wss:// addresses connect over WebSocket,
+// anything else over TCP, with ServerPort if the address has no port. A tcp:// prefix is allowed.
+func Dial(address string) (net.Conn, error) {
+	return DialContext(context.Background(), address)
+}
+
+// DialContext is Dial giving up when ctx is done
+func DialContext(ctx context.Context, address string) (net.Conn, error) {
+	if strings.HasPrefix(address, "ws://") || strings.HasPrefix(address, "wss://") {
+		return websocket.DialContext(ctx, address)
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", withDefaultPort(strings.TrimPrefix(address, "tcp://")))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to server: %w", err)
+	}
+	return conn, nil
+}
+
+// withDefaultPort adds ServerPort to a host or IP address without a port, IPv6 literals included
+func withDefaultPort(address string) string {
+	_, _, err := net.SplitHostPort(address)
+	var addrErr *net.AddrError
+	if err == nil || !errors.As(err, &addrErr) {
+		return address
+	}
+	port := strconv.Itoa(ServerPort)
+	if addrErr.Err == "missing port in address" {
+		host := strings.TrimSuffix(strings.TrimPrefix(address, "["), "]")
+		return net.JoinHostPort(host, port)
+	}
+	// An IPv6 address without brackets has too many colons to hold a port
+	if _, err := netip.ParseAddr(address); err == nil {
+		return net.JoinHostPort(address, port)
+	}
+	return address
+}
@@ -0,0 +1,26 @@
+package network
+
+import "testing"
+
+func TestWithDefaultPort(t *testing.T) {
+	tests := []struct {
+		address string
+		want    string
+	}{
+		{"localhost", "localhost:20000"},
+		{"localhost:1234", "localhost:1234"},
+		{"192.0.2.1", "192.0.2.1:20000"},
+		{"192.0.2.1:1234", "192.0.2.1:1234"},
+		{"::1", "[::1]:20000"},
+		{"[::1]", "[::1]:20000"},
+		{"[::1]:1234", "[::1]:1234"},
+		{"2001:db8::1", "[2001:db8::1]:20000"},
+		{"fe80::1%eth0", "[fe80::1%eth0]:20000"},
+		{"[fe80::1%eth0]:1234", "[fe80::1%eth0]:1234"},
+	}
+	for _, tt := range tests {
+		if got := withDefaultPort(tt.address); got != tt.want {
+			t.Errorf("withDefaultPort(%q) = %q, want %q", tt.address, got, tt.want)
+		}
+	}
+}
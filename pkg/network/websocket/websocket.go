@@ -0,0 +1,389 @@
+// Package websocket carries a byte stream over WebSocket binary messages (RFC 6455),
+// so the game protocol can pass through HTTP-only proxies and reach browsers.
+// Conn implements net.Conn: every Write sends one binary message and Read returns
+// message payloads back to back, ignoring message boundaries.
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// acceptGUID is appended to the client key to build Sec-WebSocket-Accept
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// Frame opcodes
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+// closeTimeout bounds how long Close waits to send the close frame
+const closeTimeout = time.Second
+
+// ErrProtocol is returned when the peer violates the WebSocket protocol
+var ErrProtocol = errors.New("websocket protocol error")
+
+// Conn is a WebSocket connection exchanging binary messages
+type Conn struct {
+	conn   net.Conn
+	r      *bufio.Reader
+	client bool // Clients mask the frames they send
+
+	readMu    sync.Mutex
+	remaining uint64 // Payload bytes left in the current data frame
+	masked    bool
+	mask      [4]byte
+	maskPos   int
+
+	writeMu   sync.Mutex
+	buf       []byte
+	closeSent bool // Nothing may follow a close frame
+
+	closeOnce sync.Once
+}
+
+// Dial opens a WebSocket connection to a ws:// or wss:// URL
+func Dial(rawURL string) (*Conn, error) {
+	return DialContext(context.Background(), rawURL)
+}
+
+// DialContext is Dial giving up when ctx is done, during the opening handshake too
+func DialContext(ctx context.Context, rawURL string) (*Conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse websocket URL: %w", err)
+	}
+
+	host := u.Host
+	var conn net.Conn
+	switch u.Scheme {
+	case "ws":
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "80")
+		}
+		var dialer net.Dialer
+		conn, err = dialer.DialContext(ctx, "tcp", host)
+	case "wss":
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "443")
+		}
+		dialer := tls.Dialer{Config: &tls.Config{ServerName: u.Hostname()}}
+		conn, err = dialer.DialContext(ctx, "tcp", host)
+	default:
+		return nil, fmt.Errorf("unsupported websocket scheme %q", u.Scheme)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to server: %w", err)
+	}
+
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	c, err := clientHandshake(conn, u)
+	if !stop() {
+		err = ctx.Err()
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// clientHandshake sends the upgrade request and checks the server's answer
+func clientHandshake(conn net.Conn, u *url.URL) (*Conn, error) {
+	var nonce [16]byte
+	rand.Read(nonce[:])
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+
+	req := &http.Request{
+		Method:     http.MethodGet,
+		URL:        &url.URL{Path: u.Path, RawQuery: u.RawQuery},
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Host:       u.Host,
+		Header: http.Header{
+			"Upgrade":               {"websocket"},
+			"Connection":            {"Upgrade"},
+			"Sec-WebSocket-Key":     {key},
+			"Sec-WebSocket-Version": {"13"},
+		},
+	}
+	if req.URL.Path == "" {
+		req.URL.Path = "/"
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, fmt.Errorf("failed to send websocket handshake: %w", err)
+	}
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read websocket handshake: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return nil, fmt.Errorf("websocket handshake refused: %s", resp.Status)
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		return nil, fmt.Errorf("%w: invalid Sec-WebSocket-Accept", ErrProtocol)
+	}
+	return &Conn{conn: conn, r: r, client: true}, nil
+}
+
+// Upgrade answers a WebSocket handshake request and takes over its connection.
+// On failure an error response has already been sent.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	switch {
+	case r.Method != http.MethodGet:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return nil, fmt.Errorf("%w: unexpected method %s", ErrProtocol, r.Method)
+	case !headerContains(r.Header, "Upgrade", "websocket") || !headerContains(r.Header, "Connection", "upgrade"):
+		http.Error(w, "websocket upgrade required", http.StatusUpgradeRequired)
+		return nil, fmt.Errorf("%w: not an upgrade request", ErrProtocol)
+	case r.Header.Get("Sec-WebSocket-Version") != "13":
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusUpgradeRequired)
+		return nil, fmt.Errorf("%w: unsupported version %q", ErrProtocol, r.Header.Get("Sec-WebSocket-Version"))
+	case key == "":
+		http.Error(w, "missing Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil, fmt.Errorf("%w: missing key", ErrProtocol)
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket not supported", http.StatusInternalServerError)
+		return nil, errors.New("response writer doesn't support hijacking")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("failed to take over connection: %w", err)
+	}
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n"
+	if _, err := conn.Write([]byte(response)); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to send websocket handshake: %w", err)
+	}
+	return &Conn{conn: conn, r: rw.Reader}, nil
+}
+
+// acceptKey computes Sec-WebSocket-Accept for a client key
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// headerContains reports whether a comma-separated header lists token, ignoring case
+func headerContains(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, field := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(field), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Read reads message payloads, answering pings along the way.
+// It returns io.EOF once the peer closed the connection.
+func (c *Conn) Read(p []byte) (int, error) {
+	c.readMu.Lock()
+	defer c.readMu.Unlock()
+
+	for c.remaining == 0 {
+		if err := c.nextFrame(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := int(min(uint64(len(p)), c.remaining))
+	n, err := c.r.Read(p[:n])
+	if c.masked {
+		for i := range p[:n] {
+			p[i] ^= c.mask[c.maskPos&3]
+			c.maskPos++
+		}
+	}
+	c.remaining -= uint64(n)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// nextFrame reads frame headers until a data frame starts, handling control frames in between
+func (c *Conn) nextFrame() error {
+	var header [2]byte
+	if _, err := io.ReadFull(c.r, header[:]); err != nil {
+		return err
+	}
+	opcode := header[0] & 0x0F
+	masked := header[1]&0x80 != 0
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+			return unexpectedEOF(err)
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+			return unexpectedEOF(err)
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+
+	// Clients must mask their frames and servers must not
+	if masked == c.client {
+		return fmt.Errorf("%w: unexpected frame masking", ErrProtocol)
+	}
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.r, mask[:]); err != nil {
+			return unexpectedEOF(err)
+		}
+	}
+
+	switch opcode {
+	case opBinary, opContinuation:
+		c.remaining, c.masked, c.mask, c.maskPos = length, masked, mask, 0
+		return nil
+	case opClose, opPing, opPong:
+		if length > 125 {
+			return fmt.Errorf("%w: control frame of %d bytes", ErrProtocol, length)
+		}
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(c.r, payload); err != nil {
+			return unexpectedEOF(err)
+		}
+		for i := range payload {
+			payload[i] ^= mask[i&3]
+		}
+		switch opcode {
+		case opPing:
+			return c.writeFrame(opPong, payload)
+		case opClose:
+			// Echo the status code, then report the end of the stream
+			c.writeFrame(opClose, payload[:min(len(payload), 2)])
+			return io.EOF
+		}
+		return nil
+	case opText:
+		return fmt.Errorf("%w: text messages are not supported", ErrProtocol)
+	default:
+		return fmt.Errorf("%w: unknown opcode 0x%x", ErrProtocol, opcode)
+	}
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// Write sends p as a single binary message
+func (c *Conn) Write(p []byte) (int, error) {
+	if err := c.writeFrame(opBinary, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// writeFrame sends a final frame with the given opcode and payload
+func (c *Conn) writeFrame(opcode byte, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closeSent {
+		return net.ErrClosed
+	}
+	c.closeSent = opcode == opClose
+
+	buf := append(c.buf[:0], 0x80|opcode)
+	var maskBit byte
+	if c.client {
+		maskBit = 0x80
+	}
+	switch length := len(payload); {
+	case length <= 125:
+		buf = append(buf, maskBit|byte(length))
+	case length <= 0xFFFF:
+		buf = append(buf, maskBit|126)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(length))
+	default:
+		buf = append(buf, maskBit|127)
+		buf = binary.BigEndian.AppendUint64(buf, uint64(length))
+	}
+
+	if c.client {
+		var mask [4]byte
+		rand.Read(mask[:])
+		buf = append(buf, mask[:]...)
+		start := len(buf)
+		buf = append(buf, payload...)
+		for i := range buf[start:] {
+			buf[start+i] ^= mask[i&3]
+		}
+	} else {
+		buf = append(buf, payload...)
+	}
+
+	// Only keep the buffer around for regular sizes
+	if cap(buf) <= 64<<10 {
+		c.buf = buf
+	}
+	_, err := c.conn.Write(buf)
+	return err
+}
+
+// Close sends a close frame, without waiting for the answer, and closes the connection
+func (c *Conn) Close() error {
+	err := net.ErrClosed
+	c.closeOnce.Do(func() {
+		c.conn.SetWriteDeadline(time.Now().Add(closeTimeout))
+		c.writeFrame(opClose, binary.BigEndian.AppendUint16(nil, 1000))
+		err = c.conn.Close()
+	})
+	return err
+}
+
+// LocalAddr returns the local network address
+func (c *Conn) LocalAddr() net.Addr { return c.conn.LocalAddr() }
+
+// RemoteAddr returns the remote network address
+func (c *Conn) RemoteAddr() net.Addr { return c.conn.RemoteAddr() }
+
+// SetDeadline sets the read and write deadlines of the underlying connection
+func (c *Conn) SetDeadline(t time.Time) error { return c.conn.SetDeadline(t) }
+
+// SetReadDeadline sets the read deadline of the underlying connection
+func (c *Conn) SetReadDeadline(t time.Time) error { return c.conn.SetReadDeadline(t) }
+
+// SetWriteDeadline sets the write deadline of the underlying connection
+func (c *Conn) SetWriteDeadline(t time.Time) error { return c.conn.SetWriteDeadline(t) }
@@ -0,0 +1,272 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// payloadSizes cross every boundary of the frame length encoding
+var payloadSizes = []int{0, 1, 125, 126, 65535, 65536, 200000}
+
+func payload(size int) []byte {
+	p := make([]byte, size)
+	for i := range p {
+		p[i] = byte(i * 7)
+	}
+	return p
+}
+
+// echoServer serves WebSocket clients echoing every byte, and returns its ws:// URL and a
+// channel receiving the error ending each echo
+func echoServer(t *testing.T) (string, <-chan error) {
+	t.Helper()
+	done := make(chan error, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			done <- err
+			return
+		}
+		defer conn.Close()
+		_, err = io.Copy(conn, conn)
+		done <- err
+	}))
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http") + "/play", done
+}
+
+func TestLoopback(t *testing.T) {
+	url, done := echoServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := DialContext(ctx, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	for _, size := range payloadSizes {
+		want := payload(size)
+		if _, err := conn.Write(want); err != nil {
+			t.Fatalf("writing %d bytes: %v", size, err)
+		}
+		got := make([]byte, size)
+		if _, err := io.ReadFull(conn, got); err != nil {
+			t.Fatalf("reading %d bytes: %v", size, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%d bytes changed in the round trip", size)
+		}
+	}
+
+	// The close frame ends the server's stream cleanly
+	if err := conn.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("echo ended with %v, want a clean end of stream", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server still reading after the close frame")
+	}
+	if _, err := conn.Write([]byte{1}); err == nil {
+		t.Error("Write succeeded after Close")
+	}
+}
+
+// pipe returns a client Conn and the server's raw end of an in-memory connection
+func pipe(t *testing.T) (*Conn, net.Conn) {
+	t.Helper()
+	clientEnd, serverEnd := net.Pipe()
+	t.Cleanup(func() {
+		clientEnd.Close()
+		serverEnd.Close()
+	})
+	clientEnd.SetDeadline(time.Now().Add(5 * time.Second))
+	serverEnd.SetDeadline(time.Now().Add(5 * time.Second))
+	return &Conn{conn: clientEnd, r: bufio.NewReader(clientEnd), client: true}, serverEnd
+}
+
+// frame is a frame read from the raw connection, unmasked
+type frame struct {
+	opcode  byte
+	masked  bool
+	header  []byte // Up to the mask
+	payload []byte
+}
+
+func readFrame(t *testing.T, r io.Reader) frame {
+	t.Helper()
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		t.Fatal(err)
+	}
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		header = append(header, 0, 0)
+		io.ReadFull(r, header[2:])
+		length = uint64(binary.BigEndian.Uint16(header[2:]))
+	case 127:
+		header = append(header, make([]byte, 8)...)
+		io.ReadFull(r, header[2:])
+		length = binary.BigEndian.Uint64(header[2:])
+	}
+	f := frame{opcode: header[0] & 0x0F, masked: header[1]&0x80 != 0, header: header}
+	var mask [4]byte
+	if f.masked {
+		io.ReadFull(r, mask[:])
+	}
+	f.payload = make([]byte, length)
+	if _, err := io.ReadFull(r, f.payload); err != nil {
+		t.Fatal(err)
+	}
+	for i := range f.payload {
+		f.payload[i] ^= mask[i&3]
+	}
+	return f
+}
+
+func TestFrameLengths(t *testing.T) {
+	tests := []struct {
+		size   int
+		header []byte // Second byte and extended length, without the mask bit
+	}{
+		{0, []byte{0}},
+		{125, []byte{125}},
+		{126, []byte{126, 0, 126}},
+		{65535, []byte{126, 0xFF, 0xFF}},
+		{65536, []byte{127, 0, 0, 0, 0, 0, 1, 0, 0}},
+	}
+	for _, tt := range tests {
+		conn, raw := pipe(t)
+		want := payload(tt.size)
+		go conn.Write(want)
+
+		f := readFrame(t, raw)
+		if f.header[0] != 0x80|opBinary {
+			t.Errorf("%d bytes: first byte 0x%02x, want a final binary frame", tt.size, f.header[0])
+		}
+		if !f.masked {
+			t.Errorf("%d bytes: client frame not masked", tt.size)
+		}
+		header := append([]byte{f.header[1] &^ 0x80}, f.header[2:]...)
+		if !bytes.Equal(header, tt.header) {
+			t.Errorf("%d bytes: length encoded as %v, want %v", tt.size, header, tt.header)
+		}
+		if !bytes.Equal(f.payload, want) {
+			t.Errorf("%d bytes: payload changed", tt.size)
+		}
+	}
+}
+
+func TestReadAnswersPings(t *testing.T) {
+	conn, raw := pipe(t)
+	pong := make(chan frame, 1)
+	go func() {
+		raw.Write([]byte{0x80 | opPing, 2, 'h', 'i'})
+		pong <- readFrame(t, raw)
+		// A message split over a continuation frame
+		raw.Write([]byte{opBinary, 2, 'd', 'a'})
+		raw.Write([]byte{0x80 | opContinuation, 2, 't', 'a'})
+	}()
+
+	got := make([]byte, 4)
+	if _, err := io.ReadFull(conn, got); err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "data" {
+		t.Errorf("read %q, want the message after the ping", got)
+	}
+	if f := <-pong; f.opcode != opPong || string(f.payload) != "hi" || !f.masked {
+		t.Errorf("answered with opcode 0x%x %q, want a masked pong echoing the ping", f.opcode, f.payload)
+	}
+}
+
+func TestReadEndsOnClose(t *testing.T) {
+	conn, raw := pipe(t)
+	reply := make(chan frame, 1)
+	go func() {
+		raw.Write([]byte{0x80 | opClose, 2, 0x03, 0xE8})
+		reply <- readFrame(t, raw)
+	}()
+
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("got %v, want io.EOF", err)
+	}
+	if f := <-reply; f.opcode != opClose || !bytes.Equal(f.payload, []byte{0x03, 0xE8}) {
+		t.Errorf("answered with opcode 0x%x %v, want a close frame echoing the status", f.opcode, f.payload)
+	}
+	if _, err := conn.Write([]byte{1}); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Write after the close frame: got %v, want net.ErrClosed", err)
+	}
+}
+
+func TestReadRejectsProtocolErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame []byte
+	}{
+		{"masked server frame", []byte{0x80 | opBinary, 0x80 | 1, 0, 0, 0, 0, 'x'}},
+		{"text message", []byte{0x80 | opText, 1, 'x'}},
+		{"unknown opcode", []byte{0x80 | 0x3, 1, 'x'}},
+		{"long control frame", []byte{0x80 | opPing, 126, 0, 126}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, raw := pipe(t)
+			go raw.Write(tt.frame)
+			if _, err := conn.Read(make([]byte, 1)); !errors.Is(err, ErrProtocol) {
+				t.Errorf("got %v, want ErrProtocol", err)
+			}
+		})
+	}
+}
+
+func TestAcceptKey(t *testing.T) {
+	// Example from RFC 6455, section 1.3
+	if got := acceptKey("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestDialChecksAccept(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Upgrade", "websocket")
+		w.Header().Set("Connection", "Upgrade")
+		w.Header().Set("Sec-WebSocket-Accept", acceptKey("another key"))
+		w.WriteHeader(http.StatusSwitchingProtocols)
+	}))
+	defer srv.Close()
+
+	if _, err := Dial("ws" + strings.TrimPrefix(srv.URL, "http")); !errors.Is(err, ErrProtocol) {
+		t.Errorf("got %v, want ErrProtocol", err)
+	}
+}
+
+func TestUpgradeRejectsPlainRequests(t *testing.T) {
+	url, done := echoServer(t)
+	resp, err := http.Get("http" + strings.TrimPrefix(url, "ws"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUpgradeRequired {
+		t.Errorf("status %s, want 426", resp.Status)
+	}
+	if err := <-done; !errors.Is(err, ErrProtocol) {
+		t.Errorf("Upgrade returned %v, want ErrProtocol", err)
+	}
+}
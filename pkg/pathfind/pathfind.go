@@ -0,0 +1,294 @@
+// Package pathfind finds walkable routes through a voxel world using A* search.
+// A cell is walkable when the entity fits into non-solid blocks there and the
+// block directly below it is solid.
+package pathfind
+
+import (
+	"container/heap"
+	"errors"
+	"math"
+
+	"github.com/leterax/go-voxels/pkg/voxel"
+)
+
+// Search defaults
+const (
+	DefaultHeight   = 2
+	DefaultMaxFall  = 3
+	DefaultMaxNodes = 10000
+)
+
+// Movement costs
+const (
+	straightCost = 1.0
+	diagonalCost = math.Sqrt2
+	jumpCost     = 0.5 // Extra cost for stepping up a block
+	fallCost     = 0.25
+)
+
+var (
+	// ErrStartNotWalkable is returned when the start position cannot be stood on
+	ErrStartNotWalkable = errors.New("start position is not walkable")
+	// ErrGoalNotWalkable is returned when the goal position cannot be stood on
+	ErrGoalNotWalkable = errors.New("goal position is not walkable")
+	// ErrNoPath is returned when every reachable cell was searched without finding the goal
+	ErrNoPath = errors.New("no path to goal")
+	// ErrBudgetExceeded is returned when the search expanded MaxNodes nodes without finding the goal
+	ErrBudgetExceeded = errors.New("search node budget exceeded")
+)
+
+// Pos is a block position in world coordinates.
+// For a walker it is the cell occupied by the entity's feet.
+type Pos struct {
+	X, Y, Z int32
+}
+
+// Add returns the component-wise sum of two positions
+func (p Pos) Add(o Pos) Pos {
+	return Pos{X: p.X + o.X, Y: p.Y + o.Y, Z: p.Z + o.Z}
+}
+
+// Options configures the movement rules of a search
+type Options struct {
+	Height        int  // Number of stacked cells the entity occupies (0 means 1)
+	MaxFall       int  // Largest drop in blocks the entity may walk off
+	AllowDiagonal bool // Whether diagonal moves on level ground are allowed
+	MaxNodes      int  // Maximum number of nodes to expand (0 means DefaultMaxNodes)
+}
+
+// DefaultOptions returns options suited to a player-sized entity
+func DefaultOptions() Options {
+	return Options{
+		Height:        DefaultHeight,
+		MaxFall:       DefaultMaxFall,
+		AllowDiagonal: true,
+		MaxNodes:      DefaultMaxNodes,
+	}
+}
+
+// Finder runs path searches against a world
+type Finder struct {
+	world voxel.BlockGetter
+	opts  Options
+}
+
+// NewFinder creates a path finder for the given world and movement rules
+func NewFinder(world voxel.BlockGetter, opts Options) *Finder {
+	if opts.Height <= 0 {
+		opts.Height = 1
+	}
+	if opts.MaxFall < 0 {
+		opts.MaxFall = 0
+	}
+	if opts.MaxNodes <= 0 {
+		opts.MaxNodes = DefaultMaxNodes
+	}
+	return &Finder{world: world, opts: opts}
+}
+
+// FindPath is a convenience wrapper around NewFinder(world, opts).FindPath(start, goal)
+func FindPath(world voxel.BlockGetter, start, goal Pos, opts Options) ([]Pos, error) {
+	return NewFinder(world, opts).FindPath(start, goal)
+}
+
+// FindPath returns the waypoints from start to goal, both included.
+// Consecutive waypoints are adjacent cells, possibly one block higher (a jump)
+// or up to MaxFall blocks lower (a fall).
+func (f *Finder) FindPath(start, goal Pos) ([]Pos, error) {
+	if !f.Walkable(start) {
+		return nil, ErrStartNotWalkable
+	}
+	if !f.Walkable(goal) {
+		return nil, ErrGoalNotWalkable
+	}
+	if start == goal {
+		return []Pos{start}, nil
+	}
+
+	open := &nodeHeap{}
+	nodes := map[Pos]*node{}
+
+	startNode := &node{pos: start, h: heuristic(start, goal)}
+	nodes[start] = startNode
+	heap.Push(open, startNode)
+
+	expanded := 0
+	for open.Len() > 0 {
+		current := heap.Pop(open).(*node)
+		if current.pos == goal {
+			return reconstruct(current), nil
+		}
+		current.closed = true
+
+		expanded++
+		if expanded > f.opts.MaxNodes {
+			return nil, ErrBudgetExceeded
+		}
+
+		f.forEachNeighbor(current.pos, func(next Pos, cost float64) {
+			g := current.g + cost
+			n, seen := nodes[next]
+			if !seen {
+				n = &node{pos: next, h: heuristic(next, goal), index: -1}
+				nodes[next] = n
+			} else if n.closed || g >= n.g {
+				return
+			}
+
+			n.g = g
+			n.parent = current
+			if n.index < 0 {
+				heap.Push(open, n)
+			} else {
+				heap.Fix(open, n.index)
+			}
+		})
+	}
+
+	return nil, ErrNoPath
+}
+
+// Walkable reports whether an entity can stand at p
+func (f *Finder) Walkable(p Pos) bool {
+	return f.isSolid(Pos{X: p.X, Y: p.Y - 1, Z: p.Z}) && f.fits(p)
+}
+
+// fits reports whether the entity's whole height is free of solid blocks at p
+func (f *Finder) fits(p Pos) bool {
+	for h := range f.opts.Height {
+		if f.isSolid(Pos{X: p.X, Y: p.Y + int32(h), Z: p.Z}) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *Finder) isSolid(p Pos) bool {
+	return f.world.GetBlock(p.X, p.Y, p.Z).IsSolid()
+}
+
+// Horizontal step offsets, cardinal directions first
+var steps = [8]Pos{
+	{X: 1}, {X: -1}, {Z: 1}, {Z: -1},
+	{X: 1, Z: 1}, {X: 1, Z: -1}, {X: -1, Z: 1}, {X: -1, Z: -1},
+}
+
+// forEachNeighbor calls fn for every cell reachable from p in a single move
+func (f *Finder) forEachNeighbor(p Pos, fn func(next Pos, cost float64)) {
+	// Room above the head is needed to jump
+	canJump := !f.isSolid(Pos{X: p.X, Y: p.Y + int32(f.opts.Height), Z: p.Z})
+
+	for i, step := range steps {
+		diagonal := i >= 4
+		if diagonal {
+			if !f.opts.AllowDiagonal {
+				break
+			}
+			// Corner check: both orthogonal cells must be passable so we don't clip an edge
+			if !f.fits(p.Add(Pos{X: step.X})) || !f.fits(p.Add(Pos{Z: step.Z})) {
+				continue
+			}
+		}
+
+		next := p.Add(step)
+		if f.Walkable(next) {
+			if diagonal {
+				fn(next, diagonalCost)
+			} else {
+				fn(next, straightCost)
+			}
+			continue
+		}
+
+		// Diagonal moves are only allowed on level ground
+		if diagonal {
+			continue
+		}
+
+		// Jump onto a block one higher
+		if up := next.Add(Pos{Y: 1}); canJump && f.Walkable(up) {
+			fn(up, straightCost+jumpCost)
+			continue
+		}
+
+		// Walk off an edge and fall until we land or exceed MaxFall
+		if !f.fits(next) {
+			continue
+		}
+		for drop := 1; drop <= f.opts.MaxFall; drop++ {
+			below := next.Add(Pos{Y: -int32(drop)})
+			if f.isSolid(below) {
+				break
+			}
+			if f.Walkable(below) {
+				fn(below, straightCost+fallCost*float64(drop))
+				break
+			}
+		}
+	}
+}
+
+// heuristic estimates the remaining cost using octile distance on the ground plane.
+// Every block of height is at least one jump or one block of falling, each charged at
+// the cheaper of the two, so the estimate never exceeds the real cost.
+func heuristic(a, b Pos) float64 {
+	dx := math.Abs(float64(a.X - b.X))
+	dy := math.Abs(float64(a.Y - b.Y))
+	dz := math.Abs(float64(a.Z - b.Z))
+	return dx + dz + (diagonalCost-2)*math.Min(dx, dz) + math.Min(jumpCost, fallCost)*dy
+}
+
+// reconstruct walks parent links back to the start
+func reconstruct(n *node) []Pos {
+	var path []Pos
+	for ; n != nil; n = n.parent {
+		path = append(path, n.pos)
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// node is a search state in the open or closed set
+type node struct {
+	pos    Pos
+	g, h   float64
+	parent *node
+	closed bool
+	index  int // Position in the open heap, -1 when not queued
+}
+
+// nodeHeap is a min-heap of nodes ordered by estimated total cost
+type nodeHeap []*node
+
+func (h nodeHeap) Len() int { return len(h) }
+
+func (h nodeHeap) Less(i, j int) bool {
+	fi, fj := h[i].g+h[i].h, h[j].g+h[j].h
+	if fi == fj {
+		return h[i].h < h[j].h
+	}
+	return fi < fj
+}
+
+func (h nodeHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *nodeHeap) Push(x any) {
+	n := x.(*node)
+	n.index = len(*h)
+	*h = append(*h, n)
+}
+
+func (h *nodeHeap) Pop() any {
+	old := *h
+	n := old[len(old)-1]
+	old[len(old)-1] = nil
+	n.index = -1
+	*h = old[:len(old)-1]
+	return n
+}
@@ -0,0 +1,287 @@
+package pathfind
+
+import (
+	"container/heap"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/leterax/go-voxels/pkg/voxel"
+)
+
+// newFloor returns a world with a stone floor at y = 0 spanning -size..size on x and z
+func newFloor(size int32) *voxel.World {
+	world := voxel.NewWorld(16)
+	for x := -size; x <= size; x++ {
+		for z := -size; z <= size; z++ {
+			world.SetBlock(x, 0, z, voxel.Stone)
+		}
+	}
+	return world
+}
+
+// pillar stacks stone from y = from to y = to, both included
+func pillar(world *voxel.World, x, z, from, to int32) {
+	for y := from; y <= to; y++ {
+		world.SetBlock(x, y, z, voxel.Stone)
+	}
+}
+
+// checkPath fails unless the path runs from start to goal in single moves
+func checkPath(t *testing.T, f *Finder, path []Pos, start, goal Pos) {
+	t.Helper()
+	if len(path) == 0 || path[0] != start || path[len(path)-1] != goal {
+		t.Fatalf("path %v doesn't run from %v to %v", path, start, goal)
+	}
+	for i := 1; i < len(path); i++ {
+		if _, ok := moveCost(f, path[i-1], path[i]); !ok {
+			t.Fatalf("step %v -> %v is not a single move", path[i-1], path[i])
+		}
+		if !f.Walkable(path[i]) {
+			t.Fatalf("waypoint %v is not walkable", path[i])
+		}
+	}
+}
+
+// moveCost returns the cost of moving from a to b in a single move
+func moveCost(f *Finder, a, b Pos) (float64, bool) {
+	cost, ok := 0.0, false
+	f.forEachNeighbor(a, func(next Pos, c float64) {
+		if next == b {
+			cost, ok = c, true
+		}
+	})
+	return cost, ok
+}
+
+// pathCost sums the cost of every move of a path
+func pathCost(f *Finder, path []Pos) float64 {
+	total := 0.0
+	for i := 1; i < len(path); i++ {
+		cost, _ := moveCost(f, path[i-1], path[i])
+		total += cost
+	}
+	return total
+}
+
+func TestFindPathLevel(t *testing.T) {
+	f := NewFinder(newFloor(8), DefaultOptions())
+	start, goal := Pos{X: 0, Y: 1, Z: 0}, Pos{X: 3, Y: 1, Z: 3}
+	path, err := f.FindPath(start, goal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPath(t, f, path, start, goal)
+	if got, want := pathCost(f, path), 3*diagonalCost; math.Abs(got-want) > 1e-9 {
+		t.Errorf("cost %.3f, want %.3f for three diagonal steps", got, want)
+	}
+}
+
+func TestFindPathJump(t *testing.T) {
+	world := newFloor(8)
+	// A raised platform one block higher from x = 3 on
+	for x := int32(3); x <= 8; x++ {
+		for z := int32(-8); z <= 8; z++ {
+			world.SetBlock(x, 1, z, voxel.Stone)
+		}
+	}
+	f := NewFinder(world, DefaultOptions())
+	start, goal := Pos{X: 0, Y: 1, Z: 0}, Pos{X: 5, Y: 2, Z: 0}
+	path, err := f.FindPath(start, goal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPath(t, f, path, start, goal)
+	if got, want := pathCost(f, path), 5*straightCost+jumpCost; math.Abs(got-want) > 1e-9 {
+		t.Errorf("cost %.3f, want %.3f for a single jump", got, want)
+	}
+
+	// Two blocks up can't be jumped
+	for z := int32(-8); z <= 8; z++ {
+		world.SetBlock(3, 2, z, voxel.Stone)
+	}
+	if _, err := f.FindPath(start, Pos{X: 3, Y: 3, Z: 0}); !errors.Is(err, ErrNoPath) {
+		t.Errorf("two-block step: got %v, want ErrNoPath", err)
+	}
+}
+
+func TestFindPathJumpNeedsHeadroom(t *testing.T) {
+	world := newFloor(2)
+	// Walls all around, except a step up at x = 1
+	for x := int32(-2); x <= 2; x++ {
+		for z := int32(-2); z <= 2; z++ {
+			if x != 0 || z != 0 {
+				pillar(world, x, z, 1, 3)
+			}
+		}
+	}
+	world.SetBlock(1, 2, 0, voxel.Air)
+	world.SetBlock(1, 3, 0, voxel.Air)
+	world.SetBlock(0, 3, 0, voxel.Stone) // Ceiling right above a two-block entity
+	f := NewFinder(world, DefaultOptions())
+
+	start, goal := Pos{X: 0, Y: 1, Z: 0}, Pos{X: 1, Y: 2, Z: 0}
+	if _, err := f.FindPath(start, goal); !errors.Is(err, ErrNoPath) {
+		t.Errorf("got %v, want ErrNoPath without headroom", err)
+	}
+	world.SetBlock(0, 3, 0, voxel.Air)
+	path, err := f.FindPath(start, goal)
+	if err != nil {
+		t.Fatalf("with headroom: %v", err)
+	}
+	checkPath(t, f, path, start, goal)
+}
+
+func TestFindPathFall(t *testing.T) {
+	world := newFloor(8)
+	// A tower to start on, three blocks above the floor
+	pillar(world, 0, 0, 1, 3)
+	start, goal := Pos{X: 0, Y: 4, Z: 0}, Pos{X: 3, Y: 1, Z: 0}
+
+	f := NewFinder(world, DefaultOptions())
+	path, err := f.FindPath(start, goal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPath(t, f, path, start, goal)
+	if got, want := pathCost(f, path), 3*straightCost+3*fallCost; math.Abs(got-want) > 1e-9 {
+		t.Errorf("cost %.3f, want %.3f for a three-block fall", got, want)
+	}
+
+	opts := DefaultOptions()
+	opts.MaxFall = 2
+	if _, err := FindPath(world, start, goal, opts); !errors.Is(err, ErrNoPath) {
+		t.Errorf("MaxFall 2: got %v, want ErrNoPath", err)
+	}
+}
+
+func TestFindPathNoCornerCutting(t *testing.T) {
+	world := newFloor(4)
+	pillar(world, 1, 0, 1, 2) // Wall next to the diagonal
+	f := NewFinder(world, DefaultOptions())
+
+	start, goal := Pos{X: 0, Y: 1, Z: 0}, Pos{X: 1, Y: 1, Z: 1}
+	path, err := f.FindPath(start, goal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPath(t, f, path, start, goal)
+	want := []Pos{start, {X: 0, Y: 1, Z: 1}, goal}
+	if len(path) != len(want) || path[1] != want[1] {
+		t.Errorf("path %v, want %v around the corner", path, want)
+	}
+}
+
+func TestFindPathDiagonalDisabled(t *testing.T) {
+	opts := DefaultOptions()
+	opts.AllowDiagonal = false
+	f := NewFinder(newFloor(4), opts)
+	path, err := f.FindPath(Pos{X: 0, Y: 1, Z: 0}, Pos{X: 2, Y: 1, Z: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < len(path); i++ {
+		if path[i].X != path[i-1].X && path[i].Z != path[i-1].Z {
+			t.Fatalf("diagonal step %v -> %v", path[i-1], path[i])
+		}
+	}
+}
+
+func TestFindPathErrors(t *testing.T) {
+	world := newFloor(8)
+	// Cut the floor in two with a trench deeper than MaxFall and wider than a step
+	for z := int32(-8); z <= 8; z++ {
+		world.SetBlock(0, 0, z, voxel.Air)
+	}
+	f := NewFinder(world, DefaultOptions())
+
+	tests := []struct {
+		name        string
+		start, goal Pos
+		opts        *Options
+		want        error
+	}{
+		{name: "no path", start: Pos{X: -3, Y: 1}, goal: Pos{X: 3, Y: 1}, want: ErrNoPath},
+		{name: "start in the air", start: Pos{X: -3, Y: 5}, goal: Pos{X: -1, Y: 1}, want: ErrStartNotWalkable},
+		{name: "goal in the ground", start: Pos{X: -3, Y: 1}, goal: Pos{X: -1, Y: 0}, want: ErrGoalNotWalkable},
+		{name: "budget", start: Pos{X: -8, Y: 1, Z: -8}, goal: Pos{X: -1, Y: 1, Z: 8},
+			opts: &Options{Height: 2, MaxNodes: 5}, want: ErrBudgetExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			finder := f
+			if tt.opts != nil {
+				finder = NewFinder(world, *tt.opts)
+			}
+			if _, err := finder.FindPath(tt.start, tt.goal); !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// TestHeuristicAdmissible checks the heuristic never overestimates the cheapest path,
+// using distances from a plain Dijkstra search over uneven terrain
+func TestHeuristicAdmissible(t *testing.T) {
+	const size = 6
+	// Terraces rising by one block every few cells and dropping three at once
+	height := func(x, z int32) int32 { return ((x+size)/2 + (z+size)/3) % 4 }
+	world := voxel.NewWorld(16)
+	for x := int32(-size); x <= size; x++ {
+		for z := int32(-size); z <= size; z++ {
+			pillar(world, x, z, 0, height(x, z))
+		}
+	}
+	f := NewFinder(world, DefaultOptions())
+	start := Pos{X: 0, Y: height(0, 0) + 1, Z: 0}
+	if !f.Walkable(start) {
+		t.Fatalf("start %v is not walkable", start)
+	}
+
+	dist := dijkstra(f, start)
+	if len(dist) < 50 {
+		t.Fatalf("only %d cells reachable, the terrain is too steep to test", len(dist))
+	}
+	for pos, d := range dist {
+		if h := heuristic(start, pos); h > d+1e-9 {
+			t.Errorf("heuristic %.3f exceeds the cost %.3f to %v", h, d, pos)
+		}
+		path, err := f.FindPath(start, pos)
+		if err != nil {
+			t.Fatalf("path to %v: %v", pos, err)
+		}
+		if got := pathCost(f, path); math.Abs(got-d) > 1e-9 {
+			t.Errorf("path to %v costs %.3f, the cheapest costs %.3f", pos, got, d)
+		}
+	}
+}
+
+// dijkstra returns the cost of the cheapest path from start to every reachable cell
+func dijkstra(f *Finder, start Pos) map[Pos]float64 {
+	dist := map[Pos]float64{}
+	open := &nodeHeap{}
+	nodes := map[Pos]*node{start: {pos: start}}
+	heap.Push(open, nodes[start])
+	for open.Len() > 0 {
+		current := heap.Pop(open).(*node)
+		current.closed = true
+		dist[current.pos] = current.g
+		f.forEachNeighbor(current.pos, func(next Pos, cost float64) {
+			g := current.g + cost
+			n, seen := nodes[next]
+			if !seen {
+				n = &node{pos: next, index: -1}
+				nodes[next] = n
+			} else if n.closed || g >= n.g {
+				return
+			}
+			n.g = g
+			if n.index < 0 {
+				heap.Push(open, n)
+			} else {
+				heap.Fix(open, n.index)
+			}
+		})
+	}
+	return dist
+}
@@ -17,7 +17,7 @@ type DrawElementsIndirectCommand struct {
 	Count         uint32 // Actual number of indices for this chunk.
 	InstanceCount uint32 // Always 1.
 	FirstIndex    uint32 // Offset in the index buffer (in units of 4 bytes).
-	BaseVertex    int32  // Offset in the vertex buffer (in units of 4 bytes).
+	BaseVertex    int32  // Offset in the vertex buffer (in vertices).
 	BaseInstance  uint32 // The chunk index.
 }
 
@@ -27,11 +27,18 @@ type Vec3i = mgl32.Vec3
 // GLSync is a type alias for OpenGL sync objects
 type GLSync = uintptr
 
+// Bytes per vertex of each mesh format
+const (
+	PackedVertexSize = 4 // voxel.Mesh.PackedVertices, one word per vertex
+	DetailVertexSize = 8 // voxel.Mesh.DetailVertices, two words per vertex
+)
+
 // ChunkBufferManager is responsible for managing GPU buffers for voxel chunks.
 // It handles the allocation, updating, and rendering of chunk data using persistent
 // mapped buffers and triple buffering for optimal performance.
 type ChunkBufferManager struct {
-	maxChunks int // Total number of chunks that can be stored.
+	maxChunks  int // Total number of chunks that can be stored.
+	vertexSize int // Bytes per vertex, PackedVertexSize or DetailVertexSize.
 
 	// Maximum allocated bytes per chunk for vertex and index data.
 	chunkSizeBytes     int // Maximum bytes allocated for vertex data per chunk.
@@ -68,11 +75,24 @@ type ChunkBufferManager struct {
 //
 // Returns a new ChunkBufferManager ready for use.
 func NewChunkBufferManager(maxChunks, chunkSizeBytes, maxQuadsPerChunk int) *ChunkBufferManager {
+	return newChunkBufferManager(maxChunks, chunkSizeBytes, maxQuadsPerChunk, PackedVertexSize)
+}
+
+// NewDetailChunkBufferManager creates a ChunkBufferManager for the detail vertices of
+// partial block shapes like slabs, stairs, fences and plants (voxel.Mesh.DetailVertices).
+// Chunks are drawn by a second pass with the shader's detail uniform set, see SetVertexAttributes.
+// chunkSizeBytes must be a multiple of DetailVertexSize.
+func NewDetailChunkBufferManager(maxChunks, chunkSizeBytes, maxQuadsPerChunk int) *ChunkBufferManager {
+	return newChunkBufferManager(maxChunks, chunkSizeBytes, maxQuadsPerChunk, DetailVertexSize)
+}
+
+func newChunkBufferManager(maxChunks, chunkSizeBytes, maxQuadsPerChunk, vertexSize int) *ChunkBufferManager {
 	// Each quad uses 6 indices (two triangles)
 	maxIndicesPerChunk := maxQuadsPerChunk * 6
 
 	m := &ChunkBufferManager{
 		maxChunks:          maxChunks,
+		vertexSize:         vertexSize,
 		chunkSizeBytes:     chunkSizeBytes,
 		maxQuadsPerChunk:   maxQuadsPerChunk,
 		maxIndicesPerChunk: maxIndicesPerChunk,
@@ -183,7 +203,7 @@ func (m *ChunkBufferManager) waitForFence() {
 //
 // Parameters:
 //   - chunkPos: The world position of the chunk
-//   - packedVertexData: The vertex data in a packed format
+//   - packedVertexData: The vertex data in the manager's format, PackedVertices or DetailVertices
 //   - numQuads: The number of quads (faces) in this chunk's mesh
 //
 // Panics if the data exceeds the maximum allocated size per chunk.
@@ -230,10 +250,10 @@ func (m *ChunkBufferManager) AddChunk(chunkPos Vec3i, packedVertexData []uint32,
 
 	// Update the indirect draw command for this chunk.
 	cmd := openglhelper.DrawElementsIndirectCommand{
-		Count:         uint32(numIndices),      // Number of indices (6 per quad)
-		InstanceCount: 1,                       // One instance
-		FirstIndex:    0,                       // Start at beginning of the shared index buffer
-		BaseVertex:    int32(vertexOffset / 4), // Convert byte offset to element offset
+		Count:         uint32(numIndices),                 // Number of indices (6 per quad)
+		InstanceCount: 1,                                  // One instance
+		FirstIndex:    0,                                  // Start at beginning of the shared index buffer
+		BaseVertex:    int32(vertexOffset / m.vertexSize), // Convert byte offset to vertex offset
 		BaseInstance:  uint32(chunkIndex),
 	}
 	m.indirectCommands[chunkIndex] = cmd
@@ -304,6 +324,25 @@ func (m *ChunkBufferManager) RemoveChunk(chunkPos Vec3i) {
 	m.chunkPosSSBO.UpdateSubData(posOffset, int(unsafe.Sizeof(zeroVec)), unsafe.Pointer(&zeroVec[0]))
 }
 
+// Detail reports whether the manager holds detail vertices, which the shader's detail uniform must be set for
+func (m *ChunkBufferManager) Detail() bool {
+	return m.vertexSize == DetailVertexSize
+}
+
+// SetVertexAttributes configures vao to read the manager's vertex buffer: attribute 0 is
+// the first word of each vertex and attribute 1 the second word of detail vertices, 0 otherwise.
+func (m *ChunkBufferManager) SetVertexAttributes(vao *openglhelper.VertexArrayObject) {
+	vao.Bind()
+	m.vertexBuffer.Bind()
+	vao.SetVertexAttribIPointer(0, 1, gl.UNSIGNED_INT, int32(m.vertexSize), 0)
+	if m.Detail() {
+		vao.SetVertexAttribIPointer(1, 1, gl.UNSIGNED_INT, int32(m.vertexSize), 4)
+	} else {
+		gl.DisableVertexAttribArray(1)
+		gl.VertexAttribI1ui(1, 0)
+	}
+}
+
 // Bind binds all the necessary buffers for rendering.
 // This should be called before rendering chunks.
 func (m *ChunkBufferManager) Bind() {
@@ -1,9 +1,13 @@
 #version 460 core
 layout (location = 0) in uint a_packedVertex;
+// Second word of detail vertices (see PackDetailVertex), unused for packed vertices
+layout (location = 1) in uint a_detailWord;
 
 uniform mat4 model;
 uniform mat4 view;
 uniform mat4 projection;
+// Whether the vertices are detail vertices of partial block shapes, in 1/16 block units
+uniform bool detail;
 
 // Add buffer for chunk positions (one entry per chunk)
 // This will be indexed by gl_DrawID when using MultiDrawElementsIndirect
@@ -28,7 +32,7 @@ const vec3 NORMALS[6] = vec3[6](
 
 // Lookup table for block colors based on texture ID
 // In a real implementation, you'd use a texture atlas instead
-const vec3 BLOCK_COLORS[18] = vec3[18](
+const vec3 BLOCK_COLORS[25] = vec3[25](
     vec3(1.0, 1.0, 1.0),   // Air (white, should never be rendered)
     vec3(0.2, 0.8, 0.2),   // Grass
     vec3(0.6, 0.4, 0.2),   // Dirt
@@ -46,7 +50,14 @@ const vec3 BLOCK_COLORS[18] = vec3[18](
     vec3(0.8, 0.9, 1.0),   // PackedIce
     vec3(1.0, 0.3, 0.0),   // Lava
     vec3(0.5, 0.3, 0.1),   // Barrel
-    vec3(0.4, 0.2, 0.0)    // Bookshelf
+    vec3(0.4, 0.2, 0.0),   // Bookshelf
+    vec3(0.8, 0.5, 0.3),   // OakSlab
+    vec3(0.5, 0.5, 0.5),   // StoneSlab
+    vec3(0.8, 0.5, 0.3),   // OakStairs
+    vec3(0.4, 0.4, 0.4),   // StoneBrickStairs
+    vec3(0.7, 0.45, 0.25), // OakFence
+    vec3(0.3, 0.7, 0.2),   // TallGrass
+    vec3(0.9, 0.2, 0.2)    // Flower
 );
 
 // Function to apply ambient occlusion factor
@@ -59,14 +70,28 @@ void main()
 {
     // Unpack vertex data
     // Note: X and Z are swapped in the packed format to match server coordinates
-    int a_z =                   int((a_packedVertex >> 0)  & 31);  // This is actually the Z coordinate
-    int a_y =                   int((a_packedVertex >> 5)  & 31);
-    int a_x =                   int((a_packedVertex >> 10) & 31);  // This is actually the X coordinate
-    int a_u =                   int((a_packedVertex >> 15) & 1);
-    int a_v =                   int((a_packedVertex >> 16) & 1);
-    uint a_orientation =           ((a_packedVertex >> 17) & 7);
-    uint a_texture_id =            ((a_packedVertex >> 20) & 255);
-    uint a_ambient_occlusion =     ((a_packedVertex >> 28) & 7);
+    vec3 localPosition;
+    uint a_orientation;
+    uint a_texture_id;
+    uint a_ambient_occlusion;
+    if (detail) {
+        // Detail vertices keep the position in the first word and the rest in the second
+        int d_z =               int((a_packedVertex >> 0)  & 511u);
+        int d_y =               int((a_packedVertex >> 9)  & 511u);
+        int d_x =               int((a_packedVertex >> 18) & 511u);
+        localPosition = vec3(d_x, d_y, d_z) / 16.0;
+        a_orientation =            ((a_detailWord >> 10) & 7u);
+        a_texture_id =             ((a_detailWord >> 13) & 255u);
+        a_ambient_occlusion =      ((a_detailWord >> 21) & 7u);
+    } else {
+        int a_z =               int((a_packedVertex >> 0)  & 31u);  // This is actually the Z coordinate
+        int a_y =               int((a_packedVertex >> 5)  & 31u);
+        int a_x =               int((a_packedVertex >> 10) & 31u);  // This is actually the X coordinate
+        localPosition = vec3(a_x, a_y, a_z);
+        a_orientation =            ((a_packedVertex >> 17) & 7u);
+        a_texture_id =             ((a_packedVertex >> 20) & 255u);
+        a_ambient_occlusion =      ((a_packedVertex >> 28) & 7u);
+    }
     
     // Get chunk-specific position from the buffer using gl_DrawID
     // This is the magic that makes multi-draw indirect work properly
@@ -78,13 +103,13 @@ void main()
     }
     
     // Get position in world space by combining local vertex position with chunk position
-    vec3 position = vec3(a_x, a_y, a_z) + currentChunkPosition;
+    vec3 position = localPosition + currentChunkPosition;
     
     // Get normal from orientation
     vec3 normal = NORMALS[a_orientation];
     
     // Get base color from texture ID
-    vec3 baseColor = BLOCK_COLORS[min(a_texture_id, 17u)];
+    vec3 baseColor = BLOCK_COLORS[min(a_texture_id, 24u)];
     
     // Apply ambient occlusion
     float aoFactor = getAmbientOcclusionFactor(a_ambient_occlusion);
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"github.com/leterax/go-voxels/pkg/network/packet"
+	"github.com/leterax/go-voxels/pkg/voxel"
+)
+
+// chunkPacket returns the smallest packet describing a chunk:
+// Send Mono Type Chunk, Send Chunk or Send Chunk States.
+// It returns nil for empty chunks, which are never streamed.
+func chunkPacket(chunk *voxel.Chunk) packet.Packet {
+	mono, blockType := chunk.IsMono()
+	if !mono {
+		return chunkDataPacket(chunk)
+	}
+	if blockType == voxel.Air {
+		return nil
+	}
+	return &packet.SendMonoTypeChunk{X: chunk.X, Y: chunk.Y, Z: chunk.Z, BlockType: blockType}
+}
+
+// chunkDataPacket returns a Send Chunk or Send Chunk States packet
+// carrying every block, even if the chunk is empty
+func chunkDataPacket(chunk *voxel.Chunk) packet.Packet {
+	if chunk.HasProps() {
+		return &packet.SendChunkStates{X: chunk.X, Y: chunk.Y, Z: chunk.Z, Blocks: chunk.Blocks, Props: chunk.Props}
+	}
+	return &packet.SendChunk{X: chunk.X, Y: chunk.Y, Z: chunk.Z, Blocks: chunk.Blocks}
+}
+
+// compressChunkPacket replaces a Send Chunk or Send Chunk States packet with a
+// Send Compressed Chunk if the client accepts one and it is smaller
+func compressChunkPacket(p packet.Packet, capabilities uint32) packet.Packet {
+	var compressed *packet.SendCompressedChunk
+	switch p := p.(type) {
+	case *packet.SendChunk:
+		compressed = packet.CompressChunk(p.X, p.Y, p.Z, p.Blocks, nil, capabilities)
+	case *packet.SendChunkStates:
+		compressed = packet.CompressChunk(p.X, p.Y, p.Z, p.Blocks, p.Props, capabilities)
+	}
+	if compressed == nil {
+		return p
+	}
+	return compressed
+}
+
+// blockChangePacket returns a Block Change for a single changed block of the chunk,
+// or a Multi Block Change with the current state of every changed block
+func blockChangePacket(chunk *voxel.Chunk, changed map[blockPos]bool) packet.Packet {
+	changes := make([]packet.LocalBlockChange, 0, len(changed))
+	for pos := range changed {
+		lx, ly, lz := voxel.WorldToLocalCoord(pos.X, pos.Y, pos.Z, chunk.Size)
+		state := chunk.GetState(lx, ly, lz)
+		if len(changed) == 1 {
+			return &packet.BlockChange{X: pos.X, Y: pos.Y, Z: pos.Z, State: state}
+		}
+		changes = append(changes, packet.LocalBlockChange{X: uint8(lx), Y: uint8(ly), Z: uint8(lz), State: state})
+	}
+	return &packet.MultiBlockChange{X: chunk.X, Y: chunk.Y, Z: chunk.Z, Changes: changes}
+}
@@ -0,0 +1,160 @@
+package server
+
+import (
+	"math"
+
+	"github.com/leterax/go-voxels/pkg/voxel"
+)
+
+// Generator produces the initial contents of chunks that were never edited
+type Generator interface {
+	// Generate returns the chunk at the given chunk coordinates, or nil if it is empty
+	Generate(coord voxel.ChunkCoord, chunkSize int) *voxel.Chunk
+}
+
+// Terrain heights of the default generator, in blocks
+const (
+	seaLevel   = 4
+	snowLevel  = 28
+	minHeight  = -24
+	maxHeight  = 36
+	dirtDepth  = 3
+	plantDepth = 1
+)
+
+// TerrainGenerator generates rolling hills with beaches, lakes and snowy peaks
+type TerrainGenerator struct {
+	Seed int64
+}
+
+// NewTerrainGenerator creates a terrain generator for the given seed
+func NewTerrainGenerator(seed int64) *TerrainGenerator {
+	return &TerrainGenerator{Seed: seed}
+}
+
+// Generate returns the terrain chunk at coord, or nil for chunks of pure Air
+func (g *TerrainGenerator) Generate(coord voxel.ChunkCoord, chunkSize int) *voxel.Chunk {
+	size := int32(chunkSize)
+	bottom := coord.Y * size
+	top := bottom + size - 1
+
+	// Entirely above the highest terrain and water: nothing to generate
+	if bottom > maxHeight+plantDepth && bottom > seaLevel {
+		return nil
+	}
+
+	chunk := voxel.NewChunk(coord.X, coord.Y, coord.Z, chunkSize)
+
+	// Entirely below the lowest terrain: solid stone
+	if top < minHeight-dirtDepth {
+		chunk.FillWithBlockType(voxel.Stone)
+		return chunk
+	}
+
+	empty := true
+	for lx := range chunkSize {
+		for lz := range chunkSize {
+			wx := coord.X*size + int32(lx)
+			wz := coord.Z*size + int32(lz)
+			height := g.Height(wx, wz)
+
+			for ly := range chunkSize {
+				wy := bottom + int32(ly)
+				blockType := g.blockAt(wx, wy, wz, height)
+				if blockType != voxel.Air {
+					chunk.SetBlock(lx, ly, lz, blockType)
+					empty = false
+				}
+			}
+		}
+	}
+
+	if empty {
+		return nil
+	}
+	return chunk
+}
+
+// Height returns the terrain surface height of the column at (x, z)
+func (g *TerrainGenerator) Height(x, z int32) int32 {
+	fx, fz := float64(x), float64(z)
+	h := 10 * g.noise(fx/64, fz/64, 0)
+	h += 5 * g.noise(fx/24, fz/24, 1)
+	h += 2 * g.noise(fx/8, fz/8, 2)
+	return int32(math.Round(seaLevel + 2 + h*1.6))
+}
+
+// blockAt returns the block at a world position given its column height
+func (g *TerrainGenerator) blockAt(x, y, z, height int32) voxel.BlockType {
+	switch {
+	case y > height:
+		if y <= seaLevel {
+			return voxel.Water
+		}
+		if y == height+plantDepth && height > seaLevel+1 && height < snowLevel {
+			return g.plantAt(x, z)
+		}
+		return voxel.Air
+	case y == height:
+		switch {
+		case height >= snowLevel:
+			return voxel.Snow
+		case height <= seaLevel+1:
+			return voxel.Sand
+		default:
+			return voxel.Grass
+		}
+	case y > height-dirtDepth:
+		if height <= seaLevel+1 {
+			return voxel.Sand
+		}
+		return voxel.Dirt
+	default:
+		return voxel.Stone
+	}
+}
+
+// plantAt scatters tall grass and flowers on grass columns
+func (g *TerrainGenerator) plantAt(x, z int32) voxel.BlockType {
+	switch r := g.hash(x, z, 3) % 100; {
+	case r < 8:
+		return voxel.TallGrass
+	case r < 10:
+		return voxel.Flower
+	default:
+		return voxel.Air
+	}
+}
+
+// noise returns smooth 2D value noise in [-1, 1]
+func (g *TerrainGenerator) noise(x, z float64, octave uint32) float64 {
+	x0, z0 := math.Floor(x), math.Floor(z)
+	tx, tz := smoothstep(x-x0), smoothstep(z-z0)
+	ix, iz := int32(x0), int32(z0)
+
+	corner := func(dx, dz int32) float64 {
+		return float64(g.hash(ix+dx, iz+dz, octave)%2048)/1023.5 - 1
+	}
+	top := lerp(corner(0, 0), corner(1, 0), tx)
+	bottom := lerp(corner(0, 1), corner(1, 1), tx)
+	return lerp(top, bottom, tz)
+}
+
+// hash mixes a lattice position, octave and the seed into a pseudo-random value
+func (g *TerrainGenerator) hash(x, z int32, octave uint32) uint32 {
+	h := uint64(g.Seed) ^ uint64(octave)*0x9E3779B97F4A7C15
+	h ^= uint64(uint32(x)) * 0xBF58476D1CE4E5B9
+	h ^= uint64(uint32(z)) * 0x94D049BB133111EB
+	h ^= h >> 31
+	h *= 0xD6E8FEB86659FD93
+	h ^= h >> 32
+	return uint32(h)
+}
+
+func smoothstep(t float64) float64 {
+	return t * t * (3 - 2*t)
+}
+
+func lerp(a, b, t float64) float64 {
+	return a + (b-a)*t
+}
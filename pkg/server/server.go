@@ -0,0 +1,405 @@
+// Package server implements a reference game server speaking the protocol described in
+// pkg/network/protocol.md. It hands out entity IDs, relays entity movement and chat,
+// applies block edits and streams generated terrain to every client within its render distance.
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/leterax/go-voxels/pkg/network/packet"
+	"github.com/leterax/go-voxels/pkg/network/websocket"
+	"github.com/leterax/go-voxels/pkg/voxel"
+)
+
+// Server defaults
+const (
+	DefaultMaxRenderDistance = 16
+	defaultRenderDistance    = 8 // Used until a client sends its metadata
+
+	// maxDeltaBlocks is the most changed blocks sent as a delta, beyond which resending
+	// the chunk, usually compressed, is about as small and the client remeshes it anyway
+	maxDeltaBlocks = 128
+)
+
+// ErrServerClosed is returned by Serve after Close has been called
+var ErrServerClosed = errors.New("server closed")
+
+// Config configures a Server. The zero value is usable.
+type Config struct {
+	ChunkSize          int          // Chunk edge length, packet.ChunkSize if zero
+	Generator          Generator    // Terrain for chunks never edited, TerrainGenerator with seed 0 if nil
+	World              *voxel.World // Previously saved world to serve, a new world if nil
+	MaxRenderDistance  int          // Upper bound on client render distances, DefaultMaxRenderDistance if zero
+	Logger             *log.Logger  // Destination for server logs, log.Default() if nil
+	DisableCompression bool         // Never offer compressed chunks to clients
+}
+
+// Server accepts clients and keeps the shared world and entity list
+type Server struct {
+	config Config
+	world  *voxel.World
+
+	// worldMu guards chunk contents and which chunks have been generated
+	worldMu   sync.RWMutex
+	generated map[voxel.ChunkCoord]bool
+
+	mu           sync.Mutex
+	sessions     map[uint32]*session
+	nextEntityID uint32
+	listeners    map[net.Listener]struct{}
+	closed       bool
+	wg           sync.WaitGroup
+}
+
+// New creates a server with the given configuration
+func New(config Config) *Server {
+	if config.ChunkSize <= 0 {
+		config.ChunkSize = packet.ChunkSize
+	}
+	if config.Generator == nil {
+		config.Generator = NewTerrainGenerator(0)
+	}
+	if config.MaxRenderDistance <= 0 {
+		config.MaxRenderDistance = DefaultMaxRenderDistance
+	}
+	if config.Logger == nil {
+		config.Logger = log.Default()
+	}
+
+	world := config.World
+	if world == nil {
+		world = voxel.NewWorld(config.ChunkSize)
+	}
+
+	s := &Server{
+		config:    config,
+		world:     world,
+		generated: make(map[voxel.ChunkCoord]bool),
+		sessions:  make(map[uint32]*session),
+		listeners: make(map[net.Listener]struct{}),
+		// Clients treat entity ID 0 as not identified yet
+		nextEntityID: 1,
+	}
+
+	// Loaded chunks must not be overwritten by the generator
+	for _, coord := range world.ChunkCoords() {
+		s.generated[coord] = true
+	}
+	return s
+}
+
+// World returns the world served to clients.
+// Edits made directly to it are not broadcast.
+func (s *Server) World() *voxel.World {
+	return s.world
+}
+
+// ListenAndServe listens on the TCP address and serves clients until Close is called
+func (s *Server) ListenAndServe(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+	return s.Serve(listener)
+}
+
+// Serve accepts clients on the listener until it fails or Close is called
+func (s *Server) Serve(listener net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		listener.Close()
+		return ErrServerClosed
+	}
+	s.listeners[listener] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.listeners, listener)
+		s.mu.Unlock()
+	}()
+
+	s.config.Logger.Printf("Listening on %s", listener.Addr())
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			return fmt.Errorf("failed to accept connection: %w", err)
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.ServeConn(conn)
+		}()
+	}
+}
+
+// ListenAndServeWebSocket listens on the TCP address and serves WebSocket clients until Close is called
+func (s *Server) ListenAndServeWebSocket(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+	return s.ServeWebSocket(listener)
+}
+
+// ServeWebSocket accepts WebSocket upgrades on any path of the listener until it fails or Close is called.
+// Use the Server as an http.Handler to mount it next to other handlers instead.
+func (s *Server) ServeWebSocket(listener net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		listener.Close()
+		return ErrServerClosed
+	}
+	s.listeners[listener] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.listeners, listener)
+		s.mu.Unlock()
+	}()
+
+	s.config.Logger.Printf("Listening for WebSocket clients on %s", listener.Addr())
+	err := (&http.Server{Handler: s, ErrorLog: s.config.Logger}).Serve(listener)
+
+	s.mu.Lock()
+	closed := s.closed
+	s.mu.Unlock()
+	if closed {
+		return ErrServerClosed
+	}
+	return fmt.Errorf("failed to serve websocket: %w", err)
+}
+
+// ServeHTTP upgrades the request to a WebSocket and runs the protocol over binary messages
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := websocket.Upgrade(w, r)
+	if err != nil {
+		s.config.Logger.Printf("Rejected WebSocket client %s: %v", r.RemoteAddr, err)
+		return
+	}
+
+	s.wg.Add(1)
+	defer s.wg.Done()
+	s.ServeConn(conn)
+}
+
+// ServeConn runs the protocol on an established connection until the client leaves.
+// It closes conn before returning.
+func (s *Server) ServeConn(conn net.Conn) {
+	sess := s.addSession(conn)
+	if sess == nil {
+		conn.Close()
+		return
+	}
+	defer s.removeSession(sess)
+
+	s.config.Logger.Printf("Entity %d connected from %s", sess.entityID, conn.RemoteAddr())
+	if err := sess.run(); err != nil {
+		s.config.Logger.Printf("Entity %d disconnected: %v", sess.entityID, err)
+	}
+}
+
+// Close stops all listeners, disconnects every client and waits for their handlers to finish
+func (s *Server) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	for listener := range s.listeners {
+		listener.Close()
+	}
+	for _, sess := range s.sessions {
+		sess.close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return nil
+}
+
+// addSession registers a new connection and assigns it an entity ID.
+// It returns nil if the server is closed.
+func (s *Server) addSession(conn net.Conn) *session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+
+	entityID := s.nextEntityID
+	s.nextEntityID++
+
+	sess := newSession(s, conn, entityID)
+	s.sessions[entityID] = sess
+	return sess
+}
+
+// removeSession unregisters a session and tells the other clients its entity is gone
+func (s *Server) removeSession(sess *session) {
+	s.mu.Lock()
+	delete(s.sessions, sess.entityID)
+	s.mu.Unlock()
+
+	sess.close()
+	if sess.hasJoined() {
+		s.broadcast(&packet.RemoveEntity{EntityID: sess.entityID}, sess)
+	}
+}
+
+// joinedSessions returns every session that has sent its metadata, except skip
+func (s *Server) joinedSessions(skip *session) []*session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sessions := make([]*session, 0, len(s.sessions))
+	for _, sess := range s.sessions {
+		if sess != skip && sess.hasJoined() {
+			sessions = append(sessions, sess)
+		}
+	}
+	return sessions
+}
+
+// broadcast queues a packet for every joined session except skip
+func (s *Server) broadcast(p packet.Packet, skip *session) {
+	data := packet.EncodeFramed(p)
+	for _, sess := range s.joinedSessions(skip) {
+		sess.send(data)
+	}
+}
+
+// spawnPosition returns where new entities appear
+func (s *Server) spawnPosition() packet.Position {
+	y := float32(64)
+	if terrain, ok := s.config.Generator.(*TerrainGenerator); ok {
+		y = float32(max(terrain.Height(0, 0), seaLevel) + 2)
+	}
+	return packet.Position{X: 0.5, Y: y, Z: 0.5}
+}
+
+// clampRenderDistance limits a requested render distance to the server maximum
+func (s *Server) clampRenderDistance(distance int) int {
+	return min(distance, s.config.MaxRenderDistance)
+}
+
+// chunkCoordAt returns the chunk containing a world position
+func (s *Server) chunkCoordAt(pos packet.Position) voxel.ChunkCoord {
+	return voxel.WorldToChunkCoord(
+		int32(math.Floor(float64(pos.X))),
+		int32(math.Floor(float64(pos.Y))),
+		int32(math.Floor(float64(pos.Z))),
+		s.config.ChunkSize,
+	)
+}
+
+// ensureGenerated generates the chunk at coord if that hasn't happened yet.
+// The caller must hold worldMu for writing.
+func (s *Server) ensureGenerated(coord voxel.ChunkCoord) {
+	if s.generated[coord] {
+		return
+	}
+	s.generated[coord] = true
+	if chunk := s.config.Generator.Generate(coord, s.config.ChunkSize); chunk != nil {
+		s.world.SetChunk(chunk)
+	}
+}
+
+// applyBlockUpdates writes the updates into the world and tells the clients holding
+// the touched chunks about the changes
+func (s *Server) applyBlockUpdates(updates []blockUpdate) {
+	touched := make(map[voxel.ChunkCoord]map[blockPos]bool)
+
+	s.worldMu.Lock()
+	for _, update := range updates {
+		coord := voxel.WorldToChunkCoord(update.X, update.Y, update.Z, s.config.ChunkSize)
+		s.ensureGenerated(coord)
+		s.world.SetState(update.X, update.Y, update.Z, update.State)
+		if touched[coord] == nil {
+			touched[coord] = make(map[blockPos]bool)
+		}
+		touched[coord][blockPos{X: update.X, Y: update.Y, Z: update.Z}] = true
+	}
+	s.worldMu.Unlock()
+
+	for coord, changed := range touched {
+		s.sendChunkChanges(coord, changed)
+	}
+}
+
+// sendChunkChanges tells every session that was sent a chunk before about changed blocks in it.
+// Sessions accepting deltas get Block Change or Multi Block Change for up to maxDeltaBlocks
+// changes, everyone else the chunk's current contents.
+func (s *Server) sendChunkChanges(coord voxel.ChunkCoord, changed map[blockPos]bool) {
+	s.worldMu.RLock()
+	defer s.worldMu.RUnlock()
+
+	chunk := s.world.Chunk(coord)
+	if chunk == nil {
+		return
+	}
+
+	var delta []byte
+	if len(changed) <= maxDeltaBlocks {
+		delta = packet.EncodeFramed(blockChangePacket(chunk, changed))
+	}
+
+	// A chunk emptied by an edit still has to reach clients holding its old contents
+	p := chunkPacket(chunk)
+	emptied := p == nil
+	if emptied {
+		p = chunkDataPacket(chunk)
+	}
+	// Encode once per distinct set of client capabilities, legacy clients having their own
+	type encoding struct {
+		capabilities uint32
+		legacy       bool
+	}
+	encoded := make(map[encoding][]byte)
+
+	// Queue while holding the read lock so the packet can't overtake a newer version of the chunk
+	for _, sess := range s.joinedSessions(nil) {
+		delivered, known := sess.chunkState(coord)
+		capabilities := sess.capabilities.Load()
+		switch {
+		case delivered && delta != nil && capabilities&packet.CapabilityBlockDeltas != 0:
+			// The client keeps the chunk even if it is empty now, so later deltas still apply
+			sess.send(delta)
+		case known && (delivered || !emptied):
+			key := encoding{capabilities: capabilities, legacy: sess.legacy.Load()}
+			data, ok := encoded[key]
+			if !ok {
+				data = packet.EncodeFramed(sess.adaptChunkPacket(p))
+				encoded[key] = data
+			}
+			sess.send(data)
+			sess.markChunk(coord, !emptied)
+		}
+	}
+}
+
+// capabilities returns the optional features offered to clients, besides framing which is always on
+func (s *Server) capabilities() uint32 {
+	capabilities := packet.CapabilityBlockDeltas | packet.CapabilityChunkUnload | packet.CapabilityPing
+	if !s.config.DisableCompression {
+		capabilities |= packet.CapabilityChunkRLE | packet.CapabilityChunkDeflate
+	}
+	return capabilities
+}
@@ -0,0 +1,211 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/leterax/go-voxels/pkg/network"
+	"github.com/leterax/go-voxels/pkg/network/packet"
+	"github.com/leterax/go-voxels/pkg/voxel"
+)
+
+// deltaWorld generates the spawn chunk (0, 4, 0) with its lower half of stone, and a single
+// stone block at (16, 64, 0) in the chunk next to it. Every other chunk is empty.
+var deltaWorld = generatorFunc(func(coord voxel.ChunkCoord, chunkSize int) *voxel.Chunk {
+	chunk := voxel.NewChunk(coord.X, coord.Y, coord.Z, chunkSize)
+	switch coord {
+	case voxel.ChunkCoord{X: 0, Y: 4, Z: 0}:
+		for x := range chunkSize {
+			for y := range chunkSize / 2 {
+				for z := range chunkSize {
+					chunk.SetBlock(x, y, z, voxel.Stone)
+				}
+			}
+		}
+	case voxel.ChunkCoord{X: 1, Y: 4, Z: 0}:
+		chunk.SetBlock(0, 0, 0, voxel.Stone)
+	default:
+		return nil
+	}
+	return chunk
+})
+
+// layer returns updates setting n blocks of the spawn chunk at height y to the block type
+func layer(y int32, n int, blockType voxel.BlockType) *packet.BlockBulkEdit {
+	edit := &packet.BlockBulkEdit{}
+	for i := range int32(n) {
+		edit.Updates = append(edit.Updates, packet.BlockUpdate{BlockType: blockType, X: i % 16, Y: y, Z: i / 16})
+	}
+	return edit
+}
+
+// chunkBlock returns the block at world coordinates of a Send Chunk received
+func chunkBlock(p *packet.SendChunk, x, y, z int32) voxel.BlockType {
+	chunk := voxel.NewChunkFromBlocks(p.X, p.Y, p.Z, packet.ChunkSize, p.Blocks)
+	lx, ly, lz := voxel.WorldToLocalCoord(x, y, z, packet.ChunkSize)
+	return chunk.GetBlock(lx, ly, lz)
+}
+
+func TestBlockDeltas(t *testing.T) {
+	srv := newTestServer(t, deltaWorld)
+	deltas := join(t, srv, packet.CapabilityBlockDeltas)
+	full := join(t, srv, 0)
+	deltas.drain(t) // Add Entity for the second client
+
+	// edit sends p from the delta client and returns what each client received because of it
+	edit := func(t *testing.T, p packet.Packet) (fromDeltas, fromFull []packet.Packet) {
+		t.Helper()
+		deltas.send(t, p)
+		// The edit was applied and queued for everyone once the Pong arrives
+		fromDeltas = deltas.drain(t)
+		return fromDeltas, full.drain(t)
+	}
+
+	t.Run("single block", func(t *testing.T) {
+		glass := voxel.BlockState(voxel.Glass)
+		fromDeltas, fromFull := edit(t, &packet.UpdateBlockState{State: glass, X: 3, Y: 75, Z: 4})
+		if got := only[*packet.BlockChange](t, fromDeltas); *got != (packet.BlockChange{X: 3, Y: 75, Z: 4, State: glass}) {
+			t.Errorf("got %+v, want the glass block", got)
+		}
+		chunk := only[*packet.SendChunk](t, fromFull)
+		if chunk.X != 0 || chunk.Y != 4 || chunk.Z != 0 || chunkBlock(chunk, 3, 75, 4) != voxel.Glass {
+			t.Errorf("chunk (%d, %d, %d) resent without the glass block", chunk.X, chunk.Y, chunk.Z)
+		}
+	})
+
+	t.Run("at the delta limit", func(t *testing.T) {
+		fromDeltas, fromFull := edit(t, layer(76, maxDeltaBlocks, voxel.Dirt))
+		change := only[*packet.MultiBlockChange](t, fromDeltas)
+		if len(change.Changes) != maxDeltaBlocks {
+			t.Errorf("%d changes, want %d", len(change.Changes), maxDeltaBlocks)
+		}
+		for _, c := range change.Changes {
+			if c.Y != 12 || c.State != voxel.BlockState(voxel.Dirt) {
+				t.Fatalf("change %+v, want dirt at local height 12", c)
+			}
+		}
+		if chunkBlock(only[*packet.SendChunk](t, fromFull), 15, 76, 7) != voxel.Dirt {
+			t.Error("chunk resent without the dirt")
+		}
+	})
+
+	t.Run("beyond the delta limit", func(t *testing.T) {
+		fromDeltas, fromFull := edit(t, layer(77, maxDeltaBlocks+1, voxel.Sand))
+		for _, packets := range [][]packet.Packet{fromDeltas, fromFull} {
+			if chunkBlock(only[*packet.SendChunk](t, packets), 0, 77, 8) != voxel.Sand {
+				t.Error("chunk resent without the sand")
+			}
+		}
+	})
+
+	t.Run("chunk emptied", func(t *testing.T) {
+		fromDeltas, fromFull := edit(t, &packet.UpdateBlock{BlockType: voxel.Air, X: 16, Y: 64, Z: 0})
+		// Both clients hold the old block and must learn it is gone
+		if got := only[*packet.BlockChange](t, fromDeltas); got.X != 16 || got.State != voxel.BlockState(voxel.Air) {
+			t.Errorf("got %+v, want the block at (16, 64, 0) removed", got)
+		}
+		chunk := only[*packet.SendChunk](t, fromFull)
+		if chunk.X != 1 || chunkBlock(chunk, 16, 64, 0) != voxel.Air {
+			t.Errorf("got chunk (%d, %d, %d), want the emptied chunk (1, 4, 0)", chunk.X, chunk.Y, chunk.Z)
+		}
+
+		// The chunk was delivered, so its changes keep arriving
+		fromDeltas, fromFull = edit(t, &packet.UpdateBlock{BlockType: voxel.Stone, X: 17, Y: 64, Z: 0})
+		only[*packet.BlockChange](t, fromDeltas)
+		only[*packet.SendChunk](t, fromFull)
+	})
+
+	t.Run("empty chunk filled", func(t *testing.T) {
+		// Chunk (0, 5, 0) was empty, so neither client was sent a packet to apply a delta to
+		fromDeltas, fromFull := edit(t, &packet.UpdateBlock{BlockType: voxel.Glass, X: 0, Y: 80, Z: 0})
+		for _, packets := range [][]packet.Packet{fromDeltas, fromFull} {
+			if chunk := only[*packet.SendChunk](t, packets); chunk.Y != 5 || chunkBlock(chunk, 0, 80, 0) != voxel.Glass {
+				t.Errorf("got chunk (%d, %d, %d), want chunk (0, 5, 0) with the glass", chunk.X, chunk.Y, chunk.Z)
+			}
+		}
+	})
+
+	t.Run("empty chunk left empty", func(t *testing.T) {
+		// Air placed in chunk (0, 3, 0), which was never sent because it is empty
+		fromDeltas, fromFull := edit(t, &packet.UpdateBlock{BlockType: voxel.Air, X: 0, Y: 50, Z: 0})
+		if len(fromDeltas) != 0 || len(fromFull) != 0 {
+			t.Errorf("received %v and %v, want nothing", fromDeltas, fromFull)
+		}
+	})
+
+	t.Run("chunk out of range", func(t *testing.T) {
+		fromDeltas, fromFull := edit(t, &packet.UpdateBlock{BlockType: voxel.Stone, X: 100, Y: 64, Z: 0})
+		if len(fromDeltas) != 0 || len(fromFull) != 0 {
+			t.Errorf("received %v and %v, want nothing", fromDeltas, fromFull)
+		}
+	})
+}
+
+func TestServeTCPAndWebSocket(t *testing.T) {
+	srv := newTestServer(t, nil)
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 2)
+	go func() { served <- srv.Serve(tcp) }()
+	go func() { served <- srv.ServeWebSocket(ws) }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// connect joins over the address and returns the client with the entities it is told about
+	connect := func(address string) (*network.Client, <-chan uint32) {
+		t.Helper()
+		c, err := network.NewClientContext(ctx, address, packet.SupportedCapabilities)
+		if err != nil {
+			t.Fatalf("connecting to %s: %v", address, err)
+		}
+		t.Cleanup(func() { c.Close() })
+		if want := srv.capabilities() | packet.CapabilityFramed; c.Capabilities() != want {
+			t.Errorf("%s: capabilities %b, want %b", address, c.Capabilities(), want)
+		}
+		added := make(chan uint32, 1)
+		c.OnEntityAdd = func(entityID uint32, x, y, z, yaw, pitch float32, name string) { added <- entityID }
+		go c.Run(ctx)
+		// Joining takes the client's metadata
+		c.SetRenderDistance(1)
+		if err := c.SendClientMetadata(); err != nil {
+			t.Fatal(err)
+		}
+		return c, added
+	}
+
+	overTCP, addedToTCP := connect(tcp.Addr().String())
+	overWS, addedToWS := connect("ws://" + ws.Addr().String() + "/")
+
+	// Each client sees the other, whichever transport it joined over. Identification comes
+	// before Add Entity, so both clients know their own ID once both are added.
+	wait := func(added <-chan uint32) uint32 {
+		t.Helper()
+		select {
+		case id := <-added:
+			return id
+		case <-ctx.Done():
+			t.Fatal("no entity added")
+			return 0
+		}
+	}
+	seenByTCP, seenByWS := wait(addedToTCP), wait(addedToWS)
+	if seenByTCP != overWS.EntityID() || seenByWS != overTCP.EntityID() || seenByTCP == seenByWS {
+		t.Errorf("entities %d and %d added, want %d and %d", seenByTCP, seenByWS, overWS.EntityID(), overTCP.EntityID())
+	}
+
+	srv.Close()
+	for range 2 {
+		if err := <-served; !errors.Is(err, ErrServerClosed) {
+			t.Errorf("serving ended with %v, want ErrServerClosed", err)
+		}
+	}
+}
@@ -0,0 +1,546 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/leterax/go-voxels/pkg/network/packet"
+	"github.com/leterax/go-voxels/pkg/voxel"
+)
+
+// Outbound queue limits per client
+const (
+	streamQueueBytes = 1 << 20  // Chunk streaming pauses above this many queued bytes
+	maxQueueBytes    = 64 << 20 // Clients that fall this far behind are disconnected
+)
+
+// handshakeTimeout is the time allowed for the client's Hello
+const handshakeTimeout = 10 * time.Second
+
+// errSlowClient is reported when a client can't keep up with its outbound queue
+var errSlowClient = errors.New("client too slow, outbound queue full")
+
+// blockUpdate is a block edit in world coordinates
+type blockUpdate struct {
+	State   voxel.BlockState
+	X, Y, Z int32
+}
+
+// blockPos is a block position in world coordinates
+type blockPos struct {
+	X, Y, Z int32
+}
+
+// session is the server side of a single client connection
+type session struct {
+	server   *Server
+	conn     net.Conn
+	entityID uint32
+
+	// Optional features enabled by the client's Hello
+	capabilities atomic.Uint32
+	legacy       atomic.Bool // The client predates the handshake, its packets are sent unframed
+
+	// Outbound queue drained by the writer goroutine
+	outMu     sync.Mutex
+	outQueue  [][]byte
+	outBytes  int
+	outNotify chan struct{}
+
+	// Wakes the chunk streamer after moving or changing render distance
+	streamWake chan struct{}
+
+	mu             sync.Mutex
+	name           string
+	renderDistance int
+	pos            packet.Position
+	joined         bool
+	chunks         map[voxel.ChunkCoord]bool // Chunks considered sent, true if a packet was delivered
+	dropped        map[voxel.ChunkCoord]bool // Chunks the client dropped, not streamed until requested
+
+	done      chan struct{}
+	closeOnce sync.Once
+	closeErr  error
+}
+
+func newSession(server *Server, conn net.Conn, entityID uint32) *session {
+	return &session{
+		server:         server,
+		conn:           conn,
+		entityID:       entityID,
+		outNotify:      make(chan struct{}, 1),
+		streamWake:     make(chan struct{}, 1),
+		renderDistance: server.clampRenderDistance(defaultRenderDistance),
+		pos:            server.spawnPosition(),
+		chunks:         make(map[voxel.ChunkCoord]bool),
+		dropped:        make(map[voxel.ChunkCoord]bool),
+		done:           make(chan struct{}),
+	}
+}
+
+// run serves the session until the connection fails or the session is closed
+func (s *session) run() error {
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() { defer wg.Done(); s.writeLoop() }()
+	go func() { defer wg.Done(); s.streamLoop() }()
+
+	err := s.readLoop()
+	select {
+	case <-s.done:
+		// Closed by the server or the writer, the read error is just a consequence
+		err = s.closeErr
+	default:
+		s.closeWithError(err)
+	}
+	wg.Wait()
+	return err
+}
+
+// close disconnects the client
+func (s *session) close() {
+	s.closeWithError(nil)
+}
+
+// closeWithError disconnects the client, remembering the first error that caused it
+func (s *session) closeWithError(err error) {
+	s.closeOnce.Do(func() {
+		s.closeErr = err
+		close(s.done)
+		s.conn.Close()
+	})
+}
+
+// send queues a framed packet for the writer goroutine, without its length for legacy clients
+func (s *session) send(framed []byte) {
+	if s.legacy.Load() {
+		framed = framed[4:]
+	}
+	s.queue(framed)
+}
+
+// queue queues encoded packets for the writer goroutine
+func (s *session) queue(packet []byte) {
+	s.outMu.Lock()
+	if s.outBytes+len(packet) > maxQueueBytes {
+		s.outMu.Unlock()
+		s.closeWithError(errSlowClient)
+		return
+	}
+	s.outQueue = append(s.outQueue, packet)
+	s.outBytes += len(packet)
+	s.outMu.Unlock()
+
+	select {
+	case s.outNotify <- struct{}{}:
+	default:
+	}
+}
+
+// sendPacket encodes and queues a packet
+func (s *session) sendPacket(p packet.Packet) {
+	s.send(packet.EncodeFramed(p))
+}
+
+// queuedBytes returns how many bytes are waiting to be written
+func (s *session) queuedBytes() int {
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+	return s.outBytes
+}
+
+// writeLoop writes queued packets in order until the session closes
+func (s *session) writeLoop() {
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-s.outNotify:
+		}
+
+		s.outMu.Lock()
+		queue := s.outQueue
+		s.outQueue = nil
+		s.outMu.Unlock()
+
+		buffers := net.Buffers(queue)
+		written, err := buffers.WriteTo(s.conn)
+
+		s.outMu.Lock()
+		s.outBytes -= int(written)
+		s.outMu.Unlock()
+
+		if err != nil {
+			s.closeWithError(fmt.Errorf("failed to write: %w", err))
+			return
+		}
+	}
+}
+
+// readLoop handles serverbound packets until the connection fails
+func (s *session) readLoop() error {
+	decoder := packet.NewDecoder(s.conn, packet.ServerBound)
+	first, err := s.handshake(decoder)
+	if err != nil {
+		return err
+	}
+	// Sent once the handshake decided whether packets are framed
+	s.sendPacket(&packet.Identification{EntityID: s.entityID})
+	if first != nil {
+		s.handlePacket(first)
+	}
+	for {
+		p, err := decoder.Next()
+		if err != nil {
+			if err == io.EOF {
+				return fmt.Errorf("connection closed by client")
+			}
+			return err
+		}
+		s.handlePacket(p)
+	}
+}
+
+// handlePacket dispatches a serverbound packet
+func (s *session) handlePacket(p packet.Packet) {
+	switch p := p.(type) {
+	case *packet.UpdateEntity:
+		s.handleUpdateEntity(p.Position)
+	case *packet.UpdateBlock:
+		s.server.applyBlockUpdates([]blockUpdate{{
+			State: voxel.BlockState(p.BlockType),
+			X:     p.X, Y: p.Y, Z: p.Z,
+		}})
+	case *packet.UpdateBlockState:
+		s.server.applyBlockUpdates([]blockUpdate{{State: p.State, X: p.X, Y: p.Y, Z: p.Z}})
+	case *packet.BlockBulkEdit:
+		updates := make([]blockUpdate, len(p.Updates))
+		for i, update := range p.Updates {
+			updates[i] = blockUpdate{
+				State: voxel.BlockState(update.BlockType),
+				X:     update.X, Y: update.Y, Z: update.Z,
+			}
+		}
+		s.server.applyBlockUpdates(updates)
+	case *packet.ChatMessage:
+		s.handleChat(p.Message)
+	case *packet.ClientMetadata:
+		s.handleClientMetadata(p.RenderDistance, p.Name)
+	case *packet.DropChunk:
+		s.handleDropChunk(voxel.ChunkCoord{X: p.X, Y: p.Y, Z: p.Z})
+	case *packet.RequestChunk:
+		s.handleRequestChunk(voxel.ChunkCoord{X: p.X, Y: p.Y, Z: p.Z})
+	case *packet.Ping:
+		s.sendPacket(&packet.Pong{Token: p.Token})
+	}
+}
+
+// handshake reads the client's Hello, which must be its first packet, and replies with the
+// server's. Clients predating the handshake start with another packet, which is returned to be
+// handled; they never see a Server Hello.
+func (s *session) handshake(decoder *packet.Decoder) (packet.Packet, error) {
+	s.conn.SetReadDeadline(time.Now().Add(handshakeTimeout))
+	defer s.conn.SetReadDeadline(time.Time{})
+
+	p, err := decoder.Next()
+	switch {
+	case err == io.EOF:
+		return nil, fmt.Errorf("connection closed by client")
+	case errors.Is(err, packet.ErrUnknownPacket):
+		return nil, fmt.Errorf("%w: client sent no hello: %w", packet.ErrIncompatibleVersion, err)
+	case err != nil:
+		return nil, err
+	}
+	hello, ok := p.(*packet.ClientHello)
+	if !ok {
+		// Served without capabilities or framing, as packet.LegacyProtocolVersion
+		s.legacy.Store(true)
+		return p, nil
+	}
+
+	// The Hello is never framed, everything after it is
+	version, err := packet.NegotiateVersion(hello.Version, hello.MinVersion)
+	reply := &packet.ServerHello{Version: version, MinVersion: packet.MinProtocolVersion}
+	if err != nil {
+		// Announce the supported versions so the client can report the incompatibility. Nothing
+		// else was queued yet, so it is written right away, before the connection is closed.
+		reply.Version = packet.ProtocolVersion
+		s.conn.SetWriteDeadline(time.Now().Add(handshakeTimeout))
+		s.conn.Write(packet.Encode(reply))
+		return nil, err
+	}
+	capabilities := hello.Flags & s.server.capabilities()
+	reply.Flags = capabilities | packet.CapabilityFramed
+	s.queue(packet.Encode(reply))
+
+	decoder.SetFramed(hello.Flags&packet.CapabilityFramed != 0)
+	s.capabilities.Store(capabilities)
+	return nil, nil
+}
+
+func (s *session) handleUpdateEntity(pos packet.Position) {
+	s.mu.Lock()
+	moved := s.server.chunkCoordAt(s.pos) != s.server.chunkCoordAt(pos)
+	s.pos = pos
+	joined := s.joined
+	s.mu.Unlock()
+
+	if joined {
+		s.server.broadcast(&packet.UpdateEntityPosition{EntityID: s.entityID, Position: pos}, s)
+	}
+	if moved {
+		s.wakeStreamer()
+	}
+}
+
+func (s *session) handleChat(message string) {
+	s.mu.Lock()
+	name := s.displayName()
+	s.mu.Unlock()
+
+	s.server.config.Logger.Printf("<%s> %s", name, message)
+	s.server.broadcast(&packet.Chat{Message: fmt.Sprintf("%s: %s", name, message)}, nil)
+}
+
+func (s *session) handleClientMetadata(renderDistance uint8, name string) {
+	s.mu.Lock()
+	firstJoin := !s.joined
+	s.joined = true
+	s.name = name
+	s.renderDistance = s.server.clampRenderDistance(int(renderDistance))
+	pos := s.pos
+	s.mu.Unlock()
+
+	if firstJoin {
+		s.server.config.Logger.Printf("Entity %d joined as %q", s.entityID, name)
+
+		// Introduce everyone already here, then announce the newcomer
+		for _, other := range s.server.joinedSessions(s) {
+			other.mu.Lock()
+			add := &packet.AddEntity{EntityID: other.entityID, Position: other.pos, Name: other.name}
+			other.mu.Unlock()
+			s.sendPacket(add)
+		}
+		s.server.broadcast(&packet.AddEntity{EntityID: s.entityID, Position: pos, Name: name}, s)
+
+		// Tell the client where it spawned
+		s.sendPacket(&packet.UpdateEntityPosition{EntityID: s.entityID, Position: pos})
+	} else {
+		s.server.broadcast(&packet.UpdateEntityMetadata{EntityID: s.entityID, Name: name}, s)
+	}
+
+	s.wakeStreamer()
+}
+
+// handleDropChunk stops sending a chunk the client discarded, until it requests it again
+func (s *session) handleDropChunk(coord voxel.ChunkCoord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Only chunks that were sent, which keeps the set within the render distance
+	if _, sent := s.chunks[coord]; sent {
+		delete(s.chunks, coord)
+		s.dropped[coord] = true
+	}
+}
+
+// handleRequestChunk sends a chunk within the render distance again, even if it is empty
+func (s *session) handleRequestChunk(coord voxel.ChunkCoord) {
+	s.mu.Lock()
+	ok := s.joined && s.inRange(coord)
+	if ok {
+		delete(s.dropped, coord)
+	}
+	s.mu.Unlock()
+
+	if ok {
+		s.streamChunk(coord, true)
+	}
+}
+
+// hasJoined reports whether the client has sent its metadata
+func (s *session) hasJoined() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.joined
+}
+
+// displayName returns the client's name, or a placeholder before it sent one.
+// The caller must hold s.mu.
+func (s *session) displayName() string {
+	if s.name == "" {
+		return fmt.Sprintf("entity-%d", s.entityID)
+	}
+	return s.name
+}
+
+// chunkState reports whether the chunk was streamed to this client
+// and whether a packet for it was actually delivered
+func (s *session) chunkState(coord voxel.ChunkCoord) (delivered, known bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delivered, known = s.chunks[coord]
+	return
+}
+
+// markChunk records that the chunk was streamed, and whether a packet was delivered
+func (s *session) markChunk(coord voxel.ChunkCoord, delivered bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.chunks[coord] = delivered
+}
+
+// wakeStreamer asks the streamer to recompute the chunks in range
+func (s *session) wakeStreamer() {
+	select {
+	case s.streamWake <- struct{}{}:
+	default:
+	}
+}
+
+// streamLoop sends the chunks within render distance, nearest first,
+// restarting whenever the client moves to another chunk
+func (s *session) streamLoop() {
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-s.streamWake:
+		}
+
+		if !s.hasJoined() {
+			continue
+		}
+		missing, unloaded := s.updateChunks()
+		for _, coord := range unloaded {
+			s.sendPacket(&packet.UnloadChunk{X: coord.X, Y: coord.Y, Z: coord.Z})
+		}
+		for _, coord := range missing {
+			// Stop early if the client moved, the next pass uses the new center
+			select {
+			case <-s.done:
+				return
+			default:
+			}
+			if len(s.streamWake) > 0 {
+				break
+			}
+
+			// Don't bury other packets under chunk data
+			for s.queuedBytes() > streamQueueBytes {
+				select {
+				case <-s.done:
+					return
+				case <-time.After(5 * time.Millisecond):
+				}
+			}
+
+			s.streamChunk(coord, false)
+		}
+	}
+}
+
+// streamChunk queues the chunk at coord for the client unless it is empty,
+// as a Send Mono Type Chunk of air if it was requested
+func (s *session) streamChunk(coord voxel.ChunkCoord, requested bool) {
+	s.server.worldMu.RLock()
+	defer s.server.worldMu.RUnlock()
+
+	// Generation needs the write lock, so do it up front if needed
+	if !s.server.generated[coord] {
+		s.server.worldMu.RUnlock()
+		s.server.worldMu.Lock()
+		s.server.ensureGenerated(coord)
+		s.server.worldMu.Unlock()
+		s.server.worldMu.RLock()
+	}
+
+	// Encode and queue under the read lock so edits can't slip in between
+	var p packet.Packet
+	if chunk := s.server.world.Chunk(coord); chunk != nil {
+		p = chunkPacket(chunk)
+	}
+	if p == nil && requested {
+		p = &packet.SendMonoTypeChunk{X: coord.X, Y: coord.Y, Z: coord.Z, BlockType: voxel.Air}
+	}
+	if p != nil {
+		s.sendPacket(s.adaptChunkPacket(p))
+	}
+	s.markChunk(coord, p != nil)
+}
+
+// adaptChunkPacket returns a Send Chunk or Send Chunk States packet as the client understands it:
+// compressed if it accepts compression, or without properties if it predates the handshake
+func (s *session) adaptChunkPacket(p packet.Packet) packet.Packet {
+	if states, ok := p.(*packet.SendChunkStates); ok && s.legacy.Load() {
+		return &packet.SendChunk{X: states.X, Y: states.Y, Z: states.Z, Blocks: states.Blocks}
+	}
+	return compressChunkPacket(p, s.capabilities.Load())
+}
+
+// updateChunks forgets chunks that left render distance and returns those not yet sent,
+// ordered by distance from the client, and those to unload on a client accepting Unload Chunk
+func (s *session) updateChunks() (missing, unloaded []voxel.ChunkCoord) {
+	unload := s.capabilities.Load()&packet.CapabilityChunkUnload != 0
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for coord, delivered := range s.chunks {
+		if !s.inRange(coord) {
+			delete(s.chunks, coord)
+			if delivered && unload {
+				unloaded = append(unloaded, coord)
+			}
+		}
+	}
+	for coord := range s.dropped {
+		if !s.inRange(coord) {
+			delete(s.dropped, coord)
+		}
+	}
+
+	center := s.server.chunkCoordAt(s.pos)
+	distance := int32(s.renderDistance)
+	for x := center.X - distance; x <= center.X+distance; x++ {
+		for y := center.Y - distance; y <= center.Y+distance; y++ {
+			for z := center.Z - distance; z <= center.Z+distance; z++ {
+				coord := voxel.ChunkCoord{X: x, Y: y, Z: z}
+				if _, sent := s.chunks[coord]; !sent && !s.dropped[coord] {
+					missing = append(missing, coord)
+				}
+			}
+		}
+	}
+
+	sort.Slice(missing, func(i, j int) bool {
+		return distanceSq(missing[i], center) < distanceSq(missing[j], center)
+	})
+	return missing, unloaded
+}
+
+// inRange reports whether a chunk is within the render distance. The caller must hold s.mu.
+func (s *session) inRange(coord voxel.ChunkCoord) bool {
+	center := s.server.chunkCoordAt(s.pos)
+	distance := int32(s.renderDistance)
+	return abs32(coord.X-center.X) <= distance &&
+		abs32(coord.Y-center.Y) <= distance &&
+		abs32(coord.Z-center.Z) <= distance
+}
+
+func abs32(v int32) int32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func distanceSq(a, b voxel.ChunkCoord) int64 {
+	dx, dy, dz := int64(a.X-b.X), int64(a.Y-b.Y), int64(a.Z-b.Z)
+	return dx*dx + dy*dy + dz*dz
+}
@@ -0,0 +1,501 @@
+package server
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"log"
+	"maps"
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/leterax/go-voxels/pkg/network"
+	"github.com/leterax/go-voxels/pkg/network/packet"
+	"github.com/leterax/go-voxels/pkg/voxel"
+)
+
+// newTestServer returns a server with a maximum render distance of 1, generating chunks
+// with generator, or terrain if nil
+func newTestServer(t *testing.T, generator Generator) *Server {
+	t.Helper()
+	srv := New(Config{Logger: log.New(io.Discard, "", 0), MaxRenderDistance: 1, Generator: generator})
+	t.Cleanup(func() { srv.Close() })
+	return srv
+}
+
+// connectTo serves one end of an in-memory pipe and returns the client's end
+func connectTo(t *testing.T, srv *Server) net.Conn {
+	t.Helper()
+	serverConn, conn := net.Pipe()
+	go srv.ServeConn(serverConn)
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	return conn
+}
+
+// connect serves one end of an in-memory pipe with a new server and returns the client's end
+func connect(t *testing.T) net.Conn {
+	t.Helper()
+	return connectTo(t, newTestServer(t, nil))
+}
+
+// hello sends a Client Hello accepting the given capabilities with framing, and returns the
+// server's reply with a decoder for the framed packets after it
+func hello(t *testing.T, conn net.Conn, capabilities uint32) (*packet.ServerHello, *packet.Decoder) {
+	t.Helper()
+	hello := &packet.ClientHello{
+		Version:    packet.ProtocolVersion,
+		MinVersion: packet.MinProtocolVersion,
+		Flags:      capabilities | packet.CapabilityFramed,
+	}
+	if err := packet.Write(conn, hello); err != nil {
+		t.Fatal(err)
+	}
+	decoder := packet.NewDecoder(conn, packet.ClientBound)
+	p, err := decoder.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply, ok := p.(*packet.ServerHello)
+	if !ok {
+		t.Fatalf("got %T, want the Server Hello", p)
+	}
+	decoder.SetFramed(reply.Flags&packet.CapabilityFramed != 0)
+	return reply, decoder
+}
+
+// generatorFunc adapts a function to the Generator interface
+type generatorFunc func(coord voxel.ChunkCoord, chunkSize int) *voxel.Chunk
+
+func (f generatorFunc) Generate(coord voxel.ChunkCoord, chunkSize int) *voxel.Chunk {
+	return f(coord, chunkSize)
+}
+
+// testClient is a client joined with a render distance of 1, speaking the protocol by hand
+type testClient struct {
+	conn    net.Conn
+	decoder *packet.Decoder
+	sess    *session
+	pings   uint32
+}
+
+// join connects to srv accepting the capabilities plus pings, and returns once every chunk
+// in range was streamed and received
+func join(t *testing.T, srv *Server, capabilities uint32) *testClient {
+	t.Helper()
+	conn := connectTo(t, srv)
+	_, decoder := hello(t, conn, capabilities|packet.CapabilityPing)
+	p, err := decoder.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, ok := p.(*packet.Identification)
+	if !ok {
+		t.Fatalf("got %T, want Identification", p)
+	}
+	srv.mu.Lock()
+	c := &testClient{conn: conn, decoder: decoder, sess: srv.sessions[id.EntityID]}
+	srv.mu.Unlock()
+
+	c.send(t, &packet.ClientMetadata{RenderDistance: 1, Name: "test"})
+	c.waitStreamed(t)
+	c.drain(t)
+	return c
+}
+
+// send writes a framed packet
+func (c *testClient) send(t *testing.T, p packet.Packet) {
+	t.Helper()
+	if _, err := c.conn.Write(packet.EncodeFramed(p)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// waitStreamed waits until the streamer went through every chunk within the render distance
+func (c *testClient) waitStreamed(t *testing.T) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		c.sess.mu.Lock()
+		side := 2*c.sess.renderDistance + 1
+		streamed := 0
+		for coord := range c.sess.chunks {
+			if c.sess.inRange(coord) {
+				streamed++
+			}
+		}
+		done := streamed+len(c.sess.dropped) == side*side*side
+		c.sess.mu.Unlock()
+		if done {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("chunks in range not streamed in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// drain returns the packets received until the server answered a Ping, which are all
+// those queued for the client before the Ping was handled
+func (c *testClient) drain(t *testing.T) []packet.Packet {
+	t.Helper()
+	c.pings++
+	c.send(t, &packet.Ping{Token: c.pings})
+	var packets []packet.Packet
+	for {
+		p, err := c.decoder.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pong, ok := p.(*packet.Pong); ok && pong.Token == c.pings {
+			return packets
+		}
+		packets = append(packets, p)
+	}
+}
+
+// only returns the single packet received, failing unless there is exactly one, of type P
+func only[P packet.Packet](t *testing.T, packets []packet.Packet) P {
+	t.Helper()
+	if len(packets) != 1 {
+		t.Fatalf("received %d packets %v, want a single %T", len(packets), packets, *new(P))
+	}
+	p, ok := packets[0].(P)
+	if !ok {
+		t.Fatalf("received %T, want %T", packets[0], *new(P))
+	}
+	return p
+}
+
+// expectIdentification fails unless the next packet identifies the first entity
+func expectIdentification(t *testing.T, decoder *packet.Decoder) {
+	t.Helper()
+	p, err := decoder.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, ok := p.(*packet.Identification); !ok || id.EntityID != 1 {
+		t.Fatalf("got %+v, want Identification of entity 1", p)
+	}
+}
+
+func TestSessionHandshake(t *testing.T) {
+	conn := connect(t)
+	reply, decoder := hello(t, conn, packet.CapabilityPing|1<<31)
+	if reply.Version != packet.ProtocolVersion || reply.MinVersion != packet.MinProtocolVersion {
+		t.Errorf("server speaks versions %d to %d", reply.MinVersion, reply.Version)
+	}
+	// Unknown capabilities are not enabled
+	if want := packet.CapabilityPing | packet.CapabilityFramed; reply.Flags != want {
+		t.Errorf("flags %#x, want %#x", reply.Flags, want)
+	}
+	expectIdentification(t, decoder)
+}
+
+func TestSessionIncompatibleClient(t *testing.T) {
+	conn := connect(t)
+	future := &packet.ClientHello{Version: packet.ProtocolVersion + 2, MinVersion: packet.ProtocolVersion + 1}
+	if err := packet.Write(conn, future); err != nil {
+		t.Fatal(err)
+	}
+	p, err := packet.Read(conn, packet.ClientBound)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply, ok := p.(*packet.ServerHello)
+	if !ok {
+		t.Fatalf("got %T, want the Server Hello", p)
+	}
+	// The client can tell the server's versions are all older than its own
+	if reply.Version >= future.MinVersion {
+		t.Errorf("server announced versions %d to %d to a client speaking %d to %d",
+			reply.MinVersion, reply.Version, future.MinVersion, future.Version)
+	}
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("got %v, want the connection closed", err)
+	}
+}
+
+// legacyPayloadSizes are the payload sizes of the clientbound packets known before the
+// handshake, by ID. Clients of that time fail on any other ID.
+var legacyPayloadSizes = map[uint8]int{
+	packet.IDIdentification:       4,
+	packet.IDAddEntity:            4 + 5*4 + packet.NameLength,
+	packet.IDRemoveEntity:         4,
+	packet.IDUpdateEntityPosition: 4 + 5*4,
+	packet.IDSendChunk:            3*4 + packet.ChunkVolume,
+	packet.IDSendMonoTypeChunk:    3*4 + 1,
+	packet.IDChat:                 packet.MessageLength,
+	packet.IDUpdateEntityMetadata: 4 + packet.NameLength,
+}
+
+// readLegacy reads a packet the way clients predating the handshake do, returning its ID and payload
+func readLegacy(t *testing.T, conn net.Conn) (uint8, []byte) {
+	t.Helper()
+	var id [1]byte
+	if _, err := io.ReadFull(conn, id[:]); err != nil {
+		t.Fatal(err)
+	}
+	size, ok := legacyPayloadSizes[id[0]]
+	if !ok {
+		t.Fatalf("legacy client received unknown packet ID 0x%02x", id[0])
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		t.Fatal(err)
+	}
+	return id[0], payload
+}
+
+func TestSessionLegacyClient(t *testing.T) {
+	conn := connect(t)
+	// Clients predating the handshake start with their metadata, unframed
+	if err := packet.Write(conn, &packet.ClientMetadata{RenderDistance: 1, Name: "old"}); err != nil {
+		t.Fatal(err)
+	}
+	id, payload := readLegacy(t, conn)
+	if id != packet.IDIdentification || binary.BigEndian.Uint32(payload) != 1 {
+		t.Fatalf("first packet 0x%02x %v, want Identification of entity 1", id, payload)
+	}
+
+	// The metadata was handled: the client joined and gets chunks it understands
+	spawned, chunks := false, 0
+	for !spawned || chunks == 0 {
+		switch id, payload := readLegacy(t, conn); id {
+		case packet.IDUpdateEntityPosition:
+			spawned = spawned || binary.BigEndian.Uint32(payload) == 1
+		case packet.IDSendChunk, packet.IDSendMonoTypeChunk:
+			chunks++
+		}
+	}
+}
+
+func TestSessionLegacyClientChunkStates(t *testing.T) {
+	srv := New(Config{Logger: log.New(io.Discard, "", 0), MaxRenderDistance: 1})
+	defer srv.Close()
+	sess := newSession(srv, nil, 1)
+	sess.legacy.Store(true)
+
+	blocks := make([]voxel.BlockType, packet.ChunkVolume)
+	props := make([]uint8, packet.ChunkVolume)
+	blocks[0], props[0] = voxel.OakLog, uint8(voxel.AxisX)
+	p := sess.adaptChunkPacket(&packet.SendChunkStates{X: 1, Blocks: blocks, Props: props})
+	if chunk, ok := p.(*packet.SendChunk); !ok || chunk.X != 1 || chunk.Blocks[0] != voxel.OakLog {
+		t.Errorf("legacy client gets %+v, want a Send Chunk of the same blocks", p)
+	}
+}
+
+func TestSessionPing(t *testing.T) {
+	conn := connect(t)
+	_, decoder := hello(t, conn, packet.CapabilityPing)
+	expectIdentification(t, decoder)
+	if _, err := conn.Write(packet.EncodeFramed(&packet.Ping{Token: 42})); err != nil {
+		t.Fatal(err)
+	}
+	p, err := decoder.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pong, ok := p.(*packet.Pong); !ok || pong.Token != 42 {
+		t.Errorf("got %+v, want a Pong with token 42", p)
+	}
+}
+
+// stoneBelow generates mono stone chunks up to chunk height 4, which holds the spawn point,
+// and empty chunks above
+var stoneBelow = generatorFunc(func(coord voxel.ChunkCoord, chunkSize int) *voxel.Chunk {
+	if coord.Y > 4 {
+		return nil
+	}
+	chunk := voxel.NewChunk(coord.X, coord.Y, coord.Z, chunkSize)
+	chunk.FillWithBlockType(voxel.Stone)
+	return chunk
+})
+
+// settle returns the packets received until the client's last packets were handled and
+// the chunks now in range were streamed
+func (c *testClient) settle(t *testing.T) []packet.Packet {
+	t.Helper()
+	packets := c.drain(t)
+	c.waitStreamed(t)
+	return append(packets, c.drain(t)...)
+}
+
+// chunkPackets returns the coordinates of the chunks sent and unloaded, failing on other packets
+func chunkPackets(t *testing.T, packets []packet.Packet) (sent, unloaded map[voxel.ChunkCoord]packet.Packet) {
+	t.Helper()
+	sent = make(map[voxel.ChunkCoord]packet.Packet)
+	unloaded = make(map[voxel.ChunkCoord]packet.Packet)
+	for _, p := range packets {
+		switch p := p.(type) {
+		case *packet.SendMonoTypeChunk:
+			sent[voxel.ChunkCoord{X: p.X, Y: p.Y, Z: p.Z}] = p
+		case *packet.SendChunk:
+			sent[voxel.ChunkCoord{X: p.X, Y: p.Y, Z: p.Z}] = p
+		case *packet.UnloadChunk:
+			unloaded[voxel.ChunkCoord{X: p.X, Y: p.Y, Z: p.Z}] = p
+		default:
+			t.Fatalf("received %T, want chunks", p)
+		}
+	}
+	return sent, unloaded
+}
+
+// withoutMoves returns the packets except entity movements
+func withoutMoves(packets []packet.Packet) []packet.Packet {
+	return slices.DeleteFunc(packets, func(p packet.Packet) bool {
+		_, ok := p.(*packet.UpdateEntityPosition)
+		return ok
+	})
+}
+
+// box returns the chunk coordinates within the bounds, inclusive
+func box(minX, maxX, minY, maxY, minZ, maxZ int32) map[voxel.ChunkCoord]bool {
+	coords := make(map[voxel.ChunkCoord]bool)
+	for x := minX; x <= maxX; x++ {
+		for y := minY; y <= maxY; y++ {
+			for z := minZ; z <= maxZ; z++ {
+				coords[voxel.ChunkCoord{X: x, Y: y, Z: z}] = true
+			}
+		}
+	}
+	return coords
+}
+
+// checkCoords fails unless got holds exactly the coordinates of want
+func checkCoords[V any](t *testing.T, what string, got map[voxel.ChunkCoord]V, want map[voxel.ChunkCoord]bool) {
+	t.Helper()
+	for coord := range got {
+		if !want[coord] {
+			t.Errorf("%s %v, which it shouldn't", what, coord)
+		}
+	}
+	for coord := range want {
+		if _, ok := got[coord]; !ok {
+			t.Errorf("%s not %v", what, coord)
+		}
+	}
+}
+
+func TestChunkUnload(t *testing.T) {
+	srv := newTestServer(t, stoneBelow)
+	unloading := join(t, srv, packet.CapabilityChunkUnload)
+	plain := join(t, srv, 0)
+	unloading.drain(t) // Add Entity for the second client
+
+	// One chunk east: the slice at X = -1 leaves the render distance, the one at X = 2 enters it.
+	// Only chunks that were delivered are unloaded, the empty ones above Y = 4 never were.
+	east := &packet.UpdateEntity{Position: packet.Position{X: 16.5, Y: 64, Z: 0.5}}
+	for _, c := range []*testClient{unloading, plain} {
+		c.send(t, east)
+	}
+	sent, unloaded := chunkPackets(t, withoutMoves(unloading.settle(t)))
+	checkCoords(t, "unloaded", unloaded, box(-1, -1, 3, 4, -1, 1))
+	checkCoords(t, "sent", sent, box(2, 2, 3, 4, -1, 1))
+
+	sent, unloaded = chunkPackets(t, withoutMoves(plain.settle(t)))
+	checkCoords(t, "unloaded without the capability", unloaded, nil)
+	checkCoords(t, "sent", sent, box(2, 2, 3, 4, -1, 1))
+
+	// Lowering the render distance to 0 keeps the chunk holding the client
+	unloading.send(t, &packet.ClientMetadata{RenderDistance: 0, Name: "test"})
+	want := box(0, 2, 3, 4, -1, 1)
+	delete(want, voxel.ChunkCoord{X: 1, Y: 4, Z: 0})
+	sent, unloaded = chunkPackets(t, withoutMoves(unloading.settle(t)))
+	checkCoords(t, "unloaded", unloaded, want)
+	checkCoords(t, "sent", sent, nil)
+}
+
+func TestDropAndRequestChunk(t *testing.T) {
+	srv := newTestServer(t, stoneBelow)
+	c := join(t, srv, 0)
+	dropped := voxel.ChunkCoord{X: 1, Y: 4, Z: 0}
+
+	// A dropped chunk is neither streamed again nor updated while it stays in range
+	c.send(t, &packet.DropChunk{X: dropped.X, Y: dropped.Y, Z: dropped.Z})
+	c.send(t, &packet.UpdateEntity{Position: packet.Position{X: 0.5, Y: 64, Z: 16.5}})
+	c.send(t, &packet.UpdateBlock{BlockType: voxel.Glass, X: 20, Y: 70, Z: 5})
+	sent, _ := chunkPackets(t, withoutMoves(c.settle(t)))
+	checkCoords(t, "sent", sent, box(-1, 1, 3, 4, 2, 2))
+
+	request := func(coord voxel.ChunkCoord) []packet.Packet {
+		c.send(t, &packet.RequestChunk{X: coord.X, Y: coord.Y, Z: coord.Z})
+		return c.drain(t)
+	}
+
+	// Requested again, it arrives with the edit made meanwhile and gets updates again
+	chunk := only[*packet.SendChunk](t, request(dropped))
+	if chunk.X != 1 || chunk.Y != 4 || chunk.Z != 0 || chunkBlock(chunk, 20, 70, 5) != voxel.Glass {
+		t.Errorf("got chunk (%d, %d, %d), want the dropped chunk with the glass", chunk.X, chunk.Y, chunk.Z)
+	}
+	c.send(t, &packet.UpdateBlock{BlockType: voxel.Air, X: 20, Y: 70, Z: 5})
+	if chunkBlock(only[*packet.SendChunk](t, c.drain(t)), 20, 70, 5) != voxel.Air {
+		t.Error("chunk resent without the edit")
+	}
+
+	// Empty chunks are answered with air, chunks sent before are sent again
+	if mono := only[*packet.SendMonoTypeChunk](t, request(voxel.ChunkCoord{X: 0, Y: 5, Z: 1})); mono.BlockType != voxel.Air {
+		t.Errorf("empty chunk answered with %v, want air", mono.BlockType)
+	}
+	if mono := only[*packet.SendMonoTypeChunk](t, request(voxel.ChunkCoord{X: 0, Y: 4, Z: 1})); mono.BlockType != voxel.Stone {
+		t.Errorf("chunk answered with %v, want stone", mono.BlockType)
+	}
+	if packets := request(voxel.ChunkCoord{X: 0, Y: 4, Z: -1}); len(packets) != 0 {
+		t.Errorf("request outside the render distance answered with %v", packets)
+	}
+}
+
+func TestClientLoadedChunks(t *testing.T) {
+	srv := newTestServer(t, stoneBelow)
+	c, err := network.NewClientConn(connectTo(t, srv), packet.SupportedCapabilities)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	go c.Run(context.Background())
+
+	// waitLoaded fails unless the client ends up with exactly the chunks of want
+	waitLoaded := func(want map[voxel.ChunkCoord]bool) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			loaded := make(map[voxel.ChunkCoord]bool)
+			for _, coord := range c.LoadedChunks() {
+				loaded[coord] = true
+			}
+			if maps.Equal(loaded, want) {
+				return
+			}
+			if time.Now().After(deadline) {
+				checkCoords(t, "loaded", loaded, want)
+				t.FailNow()
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	c.SetRenderDistance(1)
+	if err := c.SendClientMetadata(); err != nil {
+		t.Fatal(err)
+	}
+	waitLoaded(box(-1, 1, 3, 4, -1, 1))
+
+	if err := c.DropChunk(1, 4, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SendUpdateEntity(16.5, 64, 0.5, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	want := box(0, 2, 3, 4, -1, 1)
+	delete(want, voxel.ChunkCoord{X: 1, Y: 4, Z: 0})
+	waitLoaded(want)
+
+	if err := c.RequestChunk(1, 4, 0); err != nil {
+		t.Fatal(err)
+	}
+	want[voxel.ChunkCoord{X: 1, Y: 4, Z: 0}] = true
+	waitLoaded(want)
+}
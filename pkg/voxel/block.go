@@ -1,5 +1,10 @@
 package voxel
 
+import (
+	"slices"
+	"strconv"
+)
+
 // BlockType represents the different types of blocks in the game
 type BlockType uint8
 
@@ -22,6 +27,13 @@ const (
 	Lava
 	Barrel
 	Bookshelf
+	OakSlab
+	StoneSlab
+	OakStairs
+	StoneBrickStairs
+	OakFence
+	TallGrass
+	Flower
 )
 
 // BlockProperties contains physical properties of a block
@@ -50,6 +62,15 @@ var blockProperties = map[BlockType]BlockProperties{
 	Lava:        {Solid: true, Transparent: true},
 	Barrel:      {Solid: true, Transparent: false},
 	Bookshelf:   {Solid: true, Transparent: false},
+
+	// Partial shapes let light and neighbouring faces show through
+	OakSlab:          {Solid: true, Transparent: true},
+	StoneSlab:        {Solid: true, Transparent: true},
+	OakStairs:        {Solid: true, Transparent: true},
+	StoneBrickStairs: {Solid: true, Transparent: true},
+	OakFence:         {Solid: true, Transparent: true},
+	TallGrass:        {Solid: false, Transparent: true},
+	Flower:           {Solid: false, Transparent: true},
 }
 
 // GetBlockProperties returns properties for a specific block type
@@ -71,3 +92,69 @@ func (b BlockType) IsSolid() bool {
 func (b BlockType) IsTransparent() bool {
 	return GetBlockProperties(b).Transparent
 }
+
+// Block names, used for asset file names and text input
+var blockNames = map[BlockType]string{
+	Air:              "air",
+	Grass:            "grass",
+	Dirt:             "dirt",
+	Stone:            "stone",
+	OakLog:           "oak_log",
+	OakLeaves:        "oak_leaves",
+	Glass:            "glass",
+	Water:            "water",
+	Sand:             "sand",
+	Snow:             "snow",
+	OakPlanks:        "oak_planks",
+	StoneBricks:      "stone_bricks",
+	Netherrack:       "netherrack",
+	GoldBlock:        "gold_block",
+	PackedIce:        "packed_ice",
+	Lava:             "lava",
+	Barrel:           "barrel",
+	Bookshelf:        "bookshelf",
+	OakSlab:          "oak_slab",
+	StoneSlab:        "stone_slab",
+	OakStairs:        "oak_stairs",
+	StoneBrickStairs: "stone_brick_stairs",
+	OakFence:         "oak_fence",
+	TallGrass:        "tall_grass",
+	Flower:           "flower",
+}
+
+// String returns the name of the block type, or its numeric ID if it has none
+func (b BlockType) String() string {
+	if name, exists := blockNames[b]; exists {
+		return name
+	}
+	return strconv.Itoa(int(b))
+}
+
+// BlockTypeNames returns the name of every named block type, sorted
+func BlockTypeNames() []string {
+	names := make([]string, 0, len(blockNames))
+	for _, name := range blockNames {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Valid reports whether the block type is one of the named types
+func (b BlockType) Valid() bool {
+	_, exists := blockNames[b]
+	return exists
+}
+
+// ParseBlockType returns the block type with the given name or numeric ID
+func ParseBlockType(name string) (BlockType, bool) {
+	for blockType, blockName := range blockNames {
+		if blockName == name {
+			return blockType, true
+		}
+	}
+	if id, err := strconv.ParseUint(name, 10, 8); err == nil && BlockType(id).Valid() {
+		return BlockType(id), true
+	}
+	return Air, false
+}
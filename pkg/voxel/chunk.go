@@ -12,6 +12,9 @@ type Chunk struct {
 	Size int
 	// Voxel data
 	Blocks []BlockType
+	// Packed block state properties, parallel to Blocks.
+	// Nil until a block with non-default properties is stored.
+	Props []uint8
 	// Mesh of the chunk for rendering
 	Mesh *Mesh
 }
@@ -39,11 +42,20 @@ func NewChunkFromBlocks(x, y, z int32, size int, blocks []BlockType) *Chunk {
 	}
 }
 
+// NewChunkFromStates creates a new chunk from existing block and property data.
+// props may be nil when every block is in its default state.
+func NewChunkFromStates(x, y, z int32, size int, blocks []BlockType, props []uint8) *Chunk {
+	chunk := NewChunkFromBlocks(x, y, z, size, blocks)
+	chunk.Props = props
+	return chunk
+}
+
 // FillWithBlockType fills the entire chunk with a single block type
 func (c *Chunk) FillWithBlockType(blockType BlockType) {
 	for i := range c.Blocks {
 		c.Blocks[i] = blockType
 	}
+	c.Props = nil
 }
 
 // isValidCoordinate checks if the given coordinates are within the chunk boundaries
@@ -69,7 +81,61 @@ func (c *Chunk) SetBlock(x, y, z int, blockType BlockType) {
 	if !c.isValidCoordinate(x, y, z) {
 		return // Ignore out-of-bounds coordinates
 	}
-	c.Blocks[c.getBlockIndex(x, y, z)] = blockType
+	index := c.getBlockIndex(x, y, z)
+	c.Blocks[index] = blockType
+	if c.Props != nil {
+		c.Props[index] = 0
+	}
+}
+
+// GetState returns the block state at the specified local coordinates
+func (c *Chunk) GetState(x, y, z int) BlockState {
+	if !c.isValidCoordinate(x, y, z) {
+		return BlockState(Air)
+	}
+	index := c.getBlockIndex(x, y, z)
+	if c.Props == nil {
+		return BlockState(c.Blocks[index])
+	}
+	return NewBlockState(c.Blocks[index], c.Props[index])
+}
+
+// SetState sets the block state at the specified local coordinates
+func (c *Chunk) SetState(x, y, z int, state BlockState) {
+	if !c.isValidCoordinate(x, y, z) {
+		return // Ignore out-of-bounds coordinates
+	}
+	index := c.getBlockIndex(x, y, z)
+	c.Blocks[index] = state.Type()
+	if c.Props == nil {
+		if state.Props() == 0 {
+			return
+		}
+		c.Props = make([]uint8, len(c.Blocks))
+	}
+	c.Props[index] = state.Props()
+}
+
+// States returns the block states of the chunk as a flat array
+func (c *Chunk) States() []BlockState {
+	states := make([]BlockState, len(c.Blocks))
+	for i, blockType := range c.Blocks {
+		states[i] = BlockState(blockType)
+		if c.Props != nil {
+			states[i] = NewBlockState(blockType, c.Props[i])
+		}
+	}
+	return states
+}
+
+// HasProps reports whether any block in the chunk has non-default properties
+func (c *Chunk) HasProps() bool {
+	for _, props := range c.Props {
+		if props != 0 {
+			return true
+		}
+	}
+	return false
 }
 
 // WorldPosition returns the world position of this chunk (corner)
@@ -80,10 +146,10 @@ func (c *Chunk) WorldPosition() mgl32.Vec3 {
 // GenerateMesh creates a mesh for this chunk using greedy meshing
 func (c *Chunk) GenerateMesh() *Mesh {
 	// Convert to our expected 3D format, with coordinate swap
-	blocks3D := ConvertTo3DArray(c.Blocks, c.Size, c.Size, c.Size, true)
+	states3D := ConvertStatesTo3DArray(c.States(), c.Size, c.Size, c.Size, true)
 
 	// Create a mesh using greedy meshing
-	c.Mesh = GreedyMeshChunk(blocks3D, c.WorldPosition())
+	c.Mesh = GreedyMeshStates(states3D, c.WorldPosition())
 	return c.Mesh
 }
 
@@ -119,13 +185,18 @@ func (c *Chunk) ForEachNeighbor(fn func(x, y, z int32)) {
 	}
 }
 
-// IsMono checks if the chunk contains only a single block type
+// IsMono checks if the chunk contains only a single block type in its default state
 // Returns true and the block type if mono, false and Air otherwise
 func (c *Chunk) IsMono() (bool, BlockType) {
 	if len(c.Blocks) == 0 {
 		return true, Air
 	}
 
+	// Blocks with properties can't be described by a single type
+	if c.HasProps() {
+		return false, Air
+	}
+
 	firstBlock := c.Blocks[0]
 
 	// If first block is Air, quickly check if any block is not Air
@@ -11,14 +11,23 @@ type ChunkCoord struct {
 
 // WorldToChunkCoord converts a world position to chunk coordinates
 func WorldToChunkCoord(worldX, worldY, worldZ int32, chunkSize int) ChunkCoord {
-	// Integer division to get chunk coordinate
+	// Floor division so negative positions map to the chunk below zero
 	return ChunkCoord{
-		X: int32(worldX) / int32(chunkSize),
-		Y: int32(worldY) / int32(chunkSize),
-		Z: int32(worldZ) / int32(chunkSize),
+		X: floorDiv(worldX, int32(chunkSize)),
+		Y: floorDiv(worldY, int32(chunkSize)),
+		Z: floorDiv(worldZ, int32(chunkSize)),
 	}
 }
 
+// floorDiv divides a by b, rounding towards negative infinity
+func floorDiv(a, b int32) int32 {
+	q := a / b
+	if (a%b != 0) && ((a < 0) != (b < 0)) {
+		q--
+	}
+	return q
+}
+
 // WorldToLocalCoord converts a world position to local coordinates within a chunk
 func WorldToLocalCoord(worldX, worldY, worldZ int32, chunkSize int) (int, int, int) {
 	// Get the remainder to find position within chunk
@@ -66,12 +75,23 @@ func IndexToLocal(index, chunkSize int) (x, y, z int) {
 // ConvertTo3DArray converts a flat 1D array of BlockType to a 3D array
 // swapCoords: if true, swaps X and Z coordinates to fix coordinate system mismatches
 func ConvertTo3DArray(flatBlocks []BlockType, sizeX, sizeY, sizeZ int, swapCoords bool) [][][]BlockType {
+	return convertTo3D(flatBlocks, sizeX, sizeY, sizeZ, swapCoords, Air)
+}
+
+// ConvertStatesTo3DArray converts a flat 1D array of BlockState to a 3D array
+// swapCoords: if true, swaps X and Z coordinates to fix coordinate system mismatches
+func ConvertStatesTo3DArray(flatStates []BlockState, sizeX, sizeY, sizeZ int, swapCoords bool) [][][]BlockState {
+	return convertTo3D(flatStates, sizeX, sizeY, sizeZ, swapCoords, BlockState(Air))
+}
+
+// convertTo3D converts a flat 1D array to a 3D array, filling missing entries with empty
+func convertTo3D[T any](flat []T, sizeX, sizeY, sizeZ int, swapCoords bool, empty T) [][][]T {
 	// Initialize the 3D array based on dimensions
-	blocks := make([][][]BlockType, sizeX)
+	blocks := make([][][]T, sizeX)
 	for i := range sizeX {
-		blocks[i] = make([][]BlockType, sizeY)
+		blocks[i] = make([][]T, sizeY)
 		for j := range sizeY {
-			blocks[i][j] = make([]BlockType, sizeZ)
+			blocks[i][j] = make([]T, sizeZ)
 		}
 	}
 
@@ -92,10 +112,10 @@ func ConvertTo3DArray(flatBlocks []BlockType, sizeX, sizeY, sizeZ int, swapCoord
 				}
 
 				// Set the block in the 3D array, with bounds checking
-				if index < len(flatBlocks) {
-					blocks[tx][ty][tz] = flatBlocks[index]
+				if index < len(flat) {
+					blocks[tx][ty][tz] = flat[index]
 				} else {
-					blocks[tx][ty][tz] = Air
+					blocks[tx][ty][tz] = empty
 				}
 			}
 		}
@@ -0,0 +1,49 @@
+package voxel
+
+import (
+	"math"
+	"testing"
+)
+
+func TestWorldToChunkCoord(t *testing.T) {
+	tests := []struct {
+		world int32
+		chunk int32
+		local int
+	}{
+		{0, 0, 0},
+		{15, 0, 15},
+		{16, 1, 0},
+		{-1, -1, 15},
+		{-15, -1, 1},
+		{-16, -1, 0},
+		{-17, -2, 15},
+		{-32, -2, 0},
+		{math.MaxInt32, math.MaxInt32 / 16, 15},
+		{math.MinInt32, math.MinInt32 / 16, 0},
+	}
+	for _, tt := range tests {
+		coord := WorldToChunkCoord(tt.world, tt.world, tt.world, 16)
+		if want := (ChunkCoord{X: tt.chunk, Y: tt.chunk, Z: tt.chunk}); coord != want {
+			t.Errorf("WorldToChunkCoord(%d) = %v, want %v", tt.world, coord, want)
+		}
+		x, y, z := WorldToLocalCoord(tt.world, tt.world, tt.world, 16)
+		if x != tt.local || y != tt.local || z != tt.local {
+			t.Errorf("WorldToLocalCoord(%d) = (%d, %d, %d), want %d", tt.world, x, y, z, tt.local)
+		}
+		// Chunk and local coordinates must add back up to the world coordinate
+		if back := int64(coord.X)*16 + int64(x); back != int64(tt.world) {
+			t.Errorf("%d maps to chunk %d, local %d, which is %d", tt.world, coord.X, x, back)
+		}
+	}
+}
+
+func TestFloorDiv(t *testing.T) {
+	for a := int32(-40); a <= 40; a++ {
+		for _, b := range []int32{-7, -1, 1, 3, 16} {
+			if got, want := floorDiv(a, b), int32(math.Floor(float64(a)/float64(b))); got != want {
+				t.Errorf("floorDiv(%d, %d) = %d, want %d", a, b, got, want)
+			}
+		}
+	}
+}
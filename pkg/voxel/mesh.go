@@ -72,6 +72,7 @@ type Vertex struct {
 	Position  mgl32.Vec3
 	Normal    mgl32.Vec3
 	TexCoords mgl32.Vec2
+	Material  BlockType // Block type the vertex was generated from
 }
 
 // Face represents a face consisting of two triangles
@@ -89,6 +90,9 @@ type Mesh struct {
 
 	// Packed data for efficient rendering
 	PackedVertices []uint32
+	// Packed data for partial block shapes, two words per vertex (see PackDetailVertex).
+	// They aren't part of PackedVertices and are drawn by a second pass with the shader's detail uniform set.
+	DetailVertices []uint32
 }
 
 // NewMesh creates a new empty mesh
@@ -99,6 +103,7 @@ func NewMesh() *Mesh {
 		Indices:         make([]uint32, 0),
 		GenerateIndices: false,
 		PackedVertices:  make([]uint32, 0),
+		DetailVertices:  make([]uint32, 0),
 	}
 }
 
@@ -132,6 +137,23 @@ func (m *Mesh) AddPackedFace(packedVertices [4]uint32) {
 // GreedyMeshChunk performs greedy meshing on a chunk of voxels
 // It takes a 3D array of voxel types and generates an optimized mesh
 func GreedyMeshChunk(voxels [][][]BlockType, chunkPos mgl32.Vec3) *Mesh {
+	// Every block is in its default state
+	states := make([][][]BlockState, len(voxels))
+	for x := range voxels {
+		states[x] = make([][]BlockState, len(voxels[x]))
+		for y := range voxels[x] {
+			states[x][y] = make([]BlockState, len(voxels[x][y]))
+			for z, blockType := range voxels[x][y] {
+				states[x][y][z] = BlockState(blockType)
+			}
+		}
+	}
+	return GreedyMeshStates(states, chunkPos)
+}
+
+// GreedyMeshStates performs greedy meshing on a chunk of block states
+// Only faces with identical states are merged, and partial shapes follow their state's model
+func GreedyMeshStates(voxels [][][]BlockState, chunkPos mgl32.Vec3) *Mesh {
 	mesh := NewMesh()
 
 	// Get dimensions
@@ -170,14 +192,14 @@ func GreedyMeshChunk(voxels [][][]BlockType, chunkPos mgl32.Vec3) *Mesh {
 			// Create masks for this slice
 			maskPos := make([][]bool, maskSize[uAxis])
 			maskNeg := make([][]bool, maskSize[uAxis])
-			idsPos := make([][]BlockType, maskSize[uAxis])
-			idsNeg := make([][]BlockType, maskSize[uAxis])
+			idsPos := make([][]BlockState, maskSize[uAxis])
+			idsNeg := make([][]BlockState, maskSize[uAxis])
 
 			for i := range maskSize[uAxis] {
 				maskPos[i] = make([]bool, maskSize[vAxis])
 				maskNeg[i] = make([]bool, maskSize[vAxis])
-				idsPos[i] = make([]BlockType, maskSize[vAxis])
-				idsNeg[i] = make([]BlockType, maskSize[vAxis])
+				idsPos[i] = make([]BlockState, maskSize[vAxis])
+				idsNeg[i] = make([]BlockState, maskSize[vAxis])
 			}
 
 			// Fill the masks based on voxel visibility
@@ -196,26 +218,26 @@ func GreedyMeshChunk(voxels [][][]BlockType, chunkPos mgl32.Vec3) *Mesh {
 
 						// Get voxel types
 						var posFilled, negFilled bool
-						var posID, negID BlockType
+						var posID, negID BlockState
 
 						// Safely get the voxel types
 						if pos[0] >= 0 && pos[0] < sizeX && pos[1] >= 0 && pos[1] < sizeY && pos[2] >= 0 && pos[2] < sizeZ {
 							posID = voxels[pos[0]][pos[1]][pos[2]]
-							posFilled = posID != Air
+							posFilled = posID.IsFullCube()
 						}
 
 						if neg[0] >= 0 && neg[0] < sizeX && neg[1] >= 0 && neg[1] < sizeY && neg[2] >= 0 && neg[2] < sizeZ {
 							negID = voxels[neg[0]][neg[1]][neg[2]]
-							negFilled = negID != Air
+							negFilled = negID.IsFullCube()
 						}
 
-						// Set masks and ids
-						if negFilled && !posFilled {
+						// Set masks and ids, skipping faces hidden behind a partial shape
+						if negFilled && !posFilled && !coversFace(posID, axis, -1) {
 							maskPos[u][v] = true
 							idsPos[u][v] = negID
 						}
 
-						if posFilled && !negFilled {
+						if posFilled && !negFilled && !coversFace(negID, axis, 1) {
 							maskNeg[u][v] = true
 							idsNeg[u][v] = posID
 						}
@@ -232,7 +254,7 @@ func GreedyMeshChunk(voxels [][][]BlockType, chunkPos mgl32.Vec3) *Mesh {
 
 						if pos[0] >= 0 && pos[0] < sizeX && pos[1] >= 0 && pos[1] < sizeY && pos[2] >= 0 && pos[2] < sizeZ {
 							posID := voxels[pos[0]][pos[1]][pos[2]]
-							if posID != Air {
+							if posID.IsFullCube() {
 								maskNeg[u][v] = true
 								idsNeg[u][v] = posID
 							}
@@ -250,7 +272,7 @@ func GreedyMeshChunk(voxels [][][]BlockType, chunkPos mgl32.Vec3) *Mesh {
 
 						if neg[0] >= 0 && neg[0] < sizeX && neg[1] >= 0 && neg[1] < sizeY && neg[2] >= 0 && neg[2] < sizeZ {
 							negID := voxels[neg[0]][neg[1]][neg[2]]
-							if negID != Air {
+							if negID.IsFullCube() {
 								maskPos[u][v] = true
 								idsPos[u][v] = negID
 							}
@@ -262,7 +284,7 @@ func GreedyMeshChunk(voxels [][][]BlockType, chunkPos mgl32.Vec3) *Mesh {
 			// Process both face directions
 			for maskDir := range 2 {
 				var mask [][]bool
-				var ids [][]BlockType
+				var ids [][]BlockState
 				var normalSign int
 
 				if maskDir == 0 {
@@ -306,12 +328,13 @@ func GreedyMeshChunk(voxels [][][]BlockType, chunkPos mgl32.Vec3) *Mesh {
 							continue
 						}
 
-						// Get the block type
-						blockType := ids[u][v]
+						// Get the block state
+						state := ids[u][v]
+						blockType := state.Type()
 
 						// Find width (along v-axis)
 						width := 1
-						for width+v < maskSize[vAxis] && mask[u][v+width] && !visited[u][v+width] && ids[u][v+width] == blockType {
+						for width+v < maskSize[vAxis] && mask[u][v+width] && !visited[u][v+width] && ids[u][v+width] == state {
 							width++
 						}
 
@@ -322,7 +345,7 @@ func GreedyMeshChunk(voxels [][][]BlockType, chunkPos mgl32.Vec3) *Mesh {
 						for height+u < maskSize[uAxis] && canExtend {
 							// Check if we can extend the entire row
 							for i := range width {
-								if !mask[u+height][v+i] || visited[u+height][v+i] || ids[u+height][v+i] != blockType {
+								if !mask[u+height][v+i] || visited[u+height][v+i] || ids[u+height][v+i] != state {
 									canExtend = false
 									break
 								}
@@ -348,49 +371,7 @@ func GreedyMeshChunk(voxels [][][]BlockType, chunkPos mgl32.Vec3) *Mesh {
 						pos[vAxis] = v
 
 						// Create vertices
-						var v0, v1, v2, v3 [3]int
-						v0[0] = pos[0]
-						v0[1] = pos[1]
-						v0[2] = pos[2]
-						v1[0] = pos[0]
-						v1[1] = pos[1]
-						v1[2] = pos[2]
-						v2[0] = pos[0]
-						v2[1] = pos[1]
-						v2[2] = pos[2]
-						v3[0] = pos[0]
-						v3[1] = pos[1]
-						v3[2] = pos[2]
-
-						// Adjust vertices based on direction and normal sign
-						// Match the Python logic for correct winding order
-						if axis == 1 { // Y-axis faces need special handling
-							if normalSign > 0 { // Top face (normal points up)
-								// Counter-clockwise when looking down from above
-								v1[vAxis] += width  // Forward
-								v2[uAxis] += height // Right + Forward
-								v2[vAxis] += width
-								v3[uAxis] += height // Right
-							} else { // Bottom face (normal points down)
-								// Counter-clockwise when looking up from below
-								v1[uAxis] += height // Right
-								v2[uAxis] += height // Right + Forward
-								v2[vAxis] += width
-								v3[vAxis] += width // Forward
-							}
-						} else { // X and Z axis faces
-							if normalSign > 0 { // Normal points positive
-								v1[uAxis] += height // Up
-								v2[uAxis] += height // Up + Right
-								v2[vAxis] += width
-								v3[vAxis] += width // Right
-							} else { // Normal points negative
-								v1[vAxis] += width  // Right
-								v2[uAxis] += height // Up + Right
-								v2[vAxis] += width
-								v3[uAxis] += height // Up
-							}
-						}
+						v0, v1, v2, v3 := faceCorners(axis, uAxis, vAxis, normalSign, pos, width, height)
 
 						// Calculate normal
 						normal := [3]int{0, 0, 0}
@@ -412,30 +393,10 @@ func GreedyMeshChunk(voxels [][][]BlockType, chunkPos mgl32.Vec3) *Mesh {
 						p3 := worldPos(v3)
 
 						// Determine orientation (0-5 for the 6 cardinal directions)
-						var orientation int
-						if axis == 0 {
-							if normalSign > 0 {
-								orientation = int(East)
-							} else {
-								orientation = int(West)
-							}
-						} else if axis == 1 {
-							if normalSign > 0 {
-								orientation = int(Up)
-							} else {
-								orientation = int(Down)
-							}
-						} else { // axis == 2
-							if normalSign > 0 {
-								orientation = int(South)
-							} else {
-								orientation = int(North)
-							}
-						}
+						orientation := faceOrientation(axis, normalSign)
 
-						// Get texture ID from block type (limit to 8 bits)
-						textureID := int(blockType)
-						textureID = min(textureID, 255)
+						// Get texture ID for this face (limit to 8 bits)
+						textureID := faceTextureID(state, axis, normalSign)
 
 						// Default ambient occlusion
 						ambientOcclusion := 7
@@ -465,10 +426,10 @@ func GreedyMeshChunk(voxels [][][]BlockType, chunkPos mgl32.Vec3) *Mesh {
 						face := Face{
 							BlockType: blockType,
 							Vertices: [4]Vertex{
-								{Position: p0, Normal: faceNormal, TexCoords: t0},
-								{Position: p1, Normal: faceNormal, TexCoords: t1},
-								{Position: p2, Normal: faceNormal, TexCoords: t2},
-								{Position: p3, Normal: faceNormal, TexCoords: t3},
+								{Position: p0, Normal: faceNormal, TexCoords: t0, Material: blockType},
+								{Position: p1, Normal: faceNormal, TexCoords: t1, Material: blockType},
+								{Position: p2, Normal: faceNormal, TexCoords: t2, Material: blockType},
+								{Position: p3, Normal: faceNormal, TexCoords: t3, Material: blockType},
 							},
 						}
 
@@ -480,9 +441,230 @@ func GreedyMeshChunk(voxels [][][]BlockType, chunkPos mgl32.Vec3) *Mesh {
 		}
 	}
 
+	// Partial shapes are emitted per block, outside of greedy merging
+	addModelFaces(mesh, voxels, chunkPos)
+
 	return mesh
 }
 
+// faceCorners returns the four corners of a width x height face starting at pos,
+// ordered counter-clockwise when viewed from the side the normal points to
+func faceCorners(axis, uAxis, vAxis, normalSign int, pos [3]int, width, height int) (v0, v1, v2, v3 [3]int) {
+	v0, v1, v2, v3 = pos, pos, pos, pos
+
+	// Adjust vertices based on direction and normal sign
+	// Match the Python logic for correct winding order
+	if axis == 1 { // Y-axis faces need special handling
+		if normalSign > 0 { // Top face (normal points up)
+			// Counter-clockwise when looking down from above
+			v1[vAxis] += width  // Forward
+			v2[uAxis] += height // Right + Forward
+			v2[vAxis] += width
+			v3[uAxis] += height // Right
+		} else { // Bottom face (normal points down)
+			// Counter-clockwise when looking up from below
+			v1[uAxis] += height // Right
+			v2[uAxis] += height // Right + Forward
+			v2[vAxis] += width
+			v3[vAxis] += width // Forward
+		}
+	} else { // X and Z axis faces
+		if normalSign > 0 { // Normal points positive
+			v1[uAxis] += height // Up
+			v2[uAxis] += height // Up + Right
+			v2[vAxis] += width
+			v3[vAxis] += width // Right
+		} else { // Normal points negative
+			v1[vAxis] += width  // Right
+			v2[uAxis] += height // Up + Right
+			v2[vAxis] += width
+			v3[uAxis] += height // Up
+		}
+	}
+	return
+}
+
+// faceOrientation returns the packed orientation (0-5) of a face on the given axis
+func faceOrientation(axis, normalSign int) int {
+	switch axis {
+	case 0:
+		if normalSign > 0 {
+			return int(East)
+		}
+		return int(West)
+	case 1:
+		if normalSign > 0 {
+			return int(Up)
+		}
+		return int(Down)
+	default:
+		if normalSign > 0 {
+			return int(South)
+		}
+		return int(North)
+	}
+}
+
+// PackDetailVertex packs a vertex of a partial block shape into two uint32 words.
+// Positions are in model units (1/16 block) so they need 9 bits each.
+// Word 0: x, y, z (9 bits each, 0-511), swapped like PackVertex
+// Word 1: u, v (5 bits each, 0-16), o (3 bits), t (8 bits), ao (3 bits)
+// The vertex shader reads word 0 as attribute 0 and word 1 as attribute 1.
+func PackDetailVertex(x, y, z, u, v, o, t, ao int) [2]uint32 {
+	return [2]uint32{
+		uint32(((z & 511) << 0) | ((y & 511) << 9) | ((x & 511) << 18)),
+		uint32(((u & 31) << 0) | ((v & 31) << 5) | ((o & 7) << 10) | ((t & 255) << 13) | ((ao & 7) << 21)),
+	}
+}
+
+// AddPackedDetailFace adds a partial-shape face with packed detail vertex data
+func (m *Mesh) AddPackedDetailFace(packedVertices [4][2]uint32) {
+	for _, pv := range packedVertices {
+		m.DetailVertices = append(m.DetailVertices, pv[0], pv[1])
+	}
+}
+
+// addModelFaces emits the quads of every non-cube block in the voxel array.
+// Box faces on the cell boundary are culled when the neighbor covers them.
+func addModelFaces(mesh *Mesh, voxels [][][]BlockState, chunkPos mgl32.Vec3) {
+	sizeX, sizeY, sizeZ := len(voxels), len(voxels[0]), len(voxels[0][0])
+
+	// Neighbor lookup that treats everything outside the array as Air
+	blockAt := func(p [3]int) BlockState {
+		if p[0] < 0 || p[1] < 0 || p[2] < 0 || p[0] >= sizeX || p[1] >= sizeY || p[2] >= sizeZ {
+			return BlockState(Air)
+		}
+		return voxels[p[0]][p[1]][p[2]]
+	}
+
+	for x := range sizeX {
+		for y := range sizeY {
+			for z := range sizeZ {
+				state := voxels[x][y][z]
+				blockType := state.Type()
+				if blockType == Air || blockType.IsFullCube() {
+					continue
+				}
+
+				cell := [3]int{x, y, z}
+				model := state.Model()
+				if model.Shape == ShapeCross {
+					addCrossFaces(mesh, cell, state, chunkPos)
+					continue
+				}
+
+				boxes := model.Boxes()
+				if model.Shape == ShapeFence {
+					for _, axis := range [2]int{0, 2} {
+						for _, sign := range [2]int{-1, 1} {
+							neighbor := cell
+							neighbor[axis] += sign
+							if connectsToFence(blockAt(neighbor)) {
+								boxes = append(boxes, fenceArm(axis, sign))
+							}
+						}
+					}
+				}
+
+				for _, box := range boxes {
+					addBoxFaces(mesh, cell, box, state, chunkPos, blockAt)
+				}
+			}
+		}
+	}
+}
+
+// addBoxFaces emits the visible faces of one model box
+func addBoxFaces(mesh *Mesh, cell [3]int, box Box, state BlockState, chunkPos mgl32.Vec3, blockAt func([3]int) BlockState) {
+	for axis := range 3 {
+		uAxis, vAxis := faceAxes(axis)
+		rect := faceRect{
+			uMin: box.Min[uAxis], uMax: box.Max[uAxis],
+			vMin: box.Min[vAxis], vMax: box.Max[vAxis],
+		}
+
+		for _, normalSign := range [2]int{1, -1} {
+			pos := box.Min
+			onBoundary := box.Min[axis] == 0
+			if normalSign > 0 {
+				pos[axis] = box.Max[axis]
+				onBoundary = box.Max[axis] == ModelUnits
+			}
+
+			// Faces on the cell boundary are hidden if the neighbor's touching face contains them
+			if onBoundary {
+				neighbor := cell
+				neighbor[axis] += normalSign
+				neighborState := blockAt(neighbor)
+				if neighborState.Type() != Air && faceCovered(neighborState.Model().Boxes(), axis, -normalSign, rect) {
+					continue
+				}
+			}
+
+			width := box.Max[vAxis] - box.Min[vAxis]
+			height := box.Max[uAxis] - box.Min[uAxis]
+			v0, v1, v2, v3 := faceCorners(axis, uAxis, vAxis, normalSign, pos, width, height)
+
+			normal := mgl32.Vec3{}
+			normal[axis] = float32(normalSign)
+			textureID := faceTextureID(state, axis, normalSign)
+			addDetailQuad(mesh, cell, [4][3]int{v0, v1, v2, v3}, normal, faceOrientation(axis, normalSign), textureID, state.Type(), chunkPos)
+		}
+	}
+}
+
+// addCrossFaces emits the two diagonal planes of a cross model, double sided
+func addCrossFaces(mesh *Mesh, cell [3]int, state BlockState, chunkPos mgl32.Vec3) {
+	n := ModelUnits
+	textureID := faceTextureID(state, 0, 1) // Plants use their side texture
+	planes := [2][4][3]int{
+		{{0, 0, 0}, {0, n, 0}, {n, n, n}, {n, 0, n}},
+		{{n, 0, 0}, {n, n, 0}, {0, n, n}, {0, 0, n}},
+	}
+
+	for _, plane := range planes {
+		// Normal of the quad as wound, then the back side with reversed winding
+		edge1 := mgl32.Vec3{float32(plane[1][0] - plane[0][0]), float32(plane[1][1] - plane[0][1]), float32(plane[1][2] - plane[0][2])}
+		edge2 := mgl32.Vec3{float32(plane[3][0] - plane[0][0]), float32(plane[3][1] - plane[0][1]), float32(plane[3][2] - plane[0][2])}
+		normal := edge2.Cross(edge1).Normalize()
+
+		// Plants are lit as if facing up so both sides shade alike
+		addDetailQuad(mesh, cell, plane, normal, int(Up), textureID, state.Type(), chunkPos)
+		addDetailQuad(mesh, cell, [4][3]int{plane[3], plane[2], plane[1], plane[0]}, normal.Mul(-1), int(Up), textureID, state.Type(), chunkPos)
+	}
+}
+
+// addDetailQuad adds a quad given in model units relative to its cell,
+// both as packed detail vertices and as a traditional face
+func addDetailQuad(mesh *Mesh, cell [3]int, corners [4][3]int, normal mgl32.Vec3, orientation, textureID int, blockType BlockType, chunkPos mgl32.Vec3) {
+	texCoords := [4][2]int{{0, 0}, {1, 0}, {1, 1}, {0, 1}}
+
+	var packed [4][2]uint32
+	var vertices [4]Vertex
+	for i, corner := range corners {
+		var local [3]int
+		for a := range 3 {
+			local[a] = cell[a]*ModelUnits + corner[a]
+		}
+		u, v := texCoords[i][0]*ModelUnits, texCoords[i][1]*ModelUnits
+
+		packed[i] = PackDetailVertex(local[0], local[1], local[2], u, v, orientation, textureID, 7)
+		vertices[i] = Vertex{
+			Position: mgl32.Vec3{
+				float32(local[0])/ModelUnits + chunkPos[0],
+				float32(local[1])/ModelUnits + chunkPos[1],
+				float32(local[2])/ModelUnits + chunkPos[2],
+			},
+			Normal:    normal,
+			TexCoords: mgl32.Vec2{float32(texCoords[i][0]), float32(texCoords[i][1])},
+			Material:  blockType,
+		}
+	}
+
+	mesh.AddPackedDetailFace(packed)
+	mesh.AddFace(Face{Vertices: vertices, BlockType: blockType})
+}
+
 // GreedyMesh processes a flat array of block types and returns a mesh
 func GreedyMesh(flatBlocks []BlockType, chunkX, chunkY, chunkZ int32, chunkSize int) *Mesh {
 	// Convert chunk position to world coordinates
@@ -498,9 +680,23 @@ func GreedyMesh(flatBlocks []BlockType, chunkX, chunkY, chunkZ int32, chunkSize
 // MonoChunkMesh generates a mesh for a chunk filled with a single block type
 // This is an optimization for chunks that contain only one type of block
 func MonoChunkMesh(chunk *Chunk, blockType BlockType) *Mesh {
+	size := chunk.Size
+
+	// Partial shapes can't be collapsed into six chunk-sized quads
+	if !blockType.IsFullCube() {
+		filled := NewChunk(chunk.X, chunk.Y, chunk.Z, size)
+		filled.FillWithBlockType(blockType)
+		return filled.GenerateMesh()
+	}
+
 	// Create a new mesh
 	mesh := NewMesh()
-	size := chunk.Size
+
+	// Texture IDs for the top, bottom and side faces
+	state := BlockState(blockType)
+	top := faceTextureID(state, 1, 1)
+	bottom := faceTextureID(state, 1, -1)
+	side := faceTextureID(state, 0, 1)
 
 	// Define the face orientations - one quad per side of the chunk
 	orientations := []struct {
@@ -508,50 +704,50 @@ func MonoChunkMesh(chunk *Chunk, blockType BlockType) *Mesh {
 	}{
 		{ // +X face (right)
 			vertices: [4]uint32{
-				PackVertex(size, 0, 0, 0, 0, 0, int(blockType), 7),
-				PackVertex(size, size, 0, 0, 1, 0, int(blockType), 7),
-				PackVertex(size, size, size, 1, 1, 0, int(blockType), 7),
-				PackVertex(size, 0, size, 1, 0, 0, int(blockType), 7),
+				PackVertex(size, 0, 0, 0, 0, 0, side, 7),
+				PackVertex(size, size, 0, 0, 1, 0, side, 7),
+				PackVertex(size, size, size, 1, 1, 0, side, 7),
+				PackVertex(size, 0, size, 1, 0, 0, side, 7),
 			},
 		},
 		{ // -X face (left)
 			vertices: [4]uint32{
-				PackVertex(0, 0, size, 0, 0, 1, int(blockType), 7),
-				PackVertex(0, size, size, 0, 1, 1, int(blockType), 7),
-				PackVertex(0, size, 0, 1, 1, 1, int(blockType), 7),
-				PackVertex(0, 0, 0, 1, 0, 1, int(blockType), 7),
+				PackVertex(0, 0, size, 0, 0, 1, side, 7),
+				PackVertex(0, size, size, 0, 1, 1, side, 7),
+				PackVertex(0, size, 0, 1, 1, 1, side, 7),
+				PackVertex(0, 0, 0, 1, 0, 1, side, 7),
 			},
 		},
 		{ // +Y face (top)
 			vertices: [4]uint32{
-				PackVertex(0, size, 0, 0, 0, 2, int(blockType), 7),
-				PackVertex(0, size, size, 0, 1, 2, int(blockType), 7),
-				PackVertex(size, size, size, 1, 1, 2, int(blockType), 7),
-				PackVertex(size, size, 0, 1, 0, 2, int(blockType), 7),
+				PackVertex(0, size, 0, 0, 0, 2, top, 7),
+				PackVertex(0, size, size, 0, 1, 2, top, 7),
+				PackVertex(size, size, size, 1, 1, 2, top, 7),
+				PackVertex(size, size, 0, 1, 0, 2, top, 7),
 			},
 		},
 		{ // -Y face (bottom)
 			vertices: [4]uint32{
-				PackVertex(0, 0, size, 0, 0, 3, int(blockType), 7),
-				PackVertex(0, 0, 0, 0, 1, 3, int(blockType), 7),
-				PackVertex(size, 0, 0, 1, 1, 3, int(blockType), 7),
-				PackVertex(size, 0, size, 1, 0, 3, int(blockType), 7),
+				PackVertex(0, 0, size, 0, 0, 3, bottom, 7),
+				PackVertex(0, 0, 0, 0, 1, 3, bottom, 7),
+				PackVertex(size, 0, 0, 1, 1, 3, bottom, 7),
+				PackVertex(size, 0, size, 1, 0, 3, bottom, 7),
 			},
 		},
 		{ // +Z face (front)
 			vertices: [4]uint32{
-				PackVertex(0, 0, size, 0, 0, 4, int(blockType), 7),
-				PackVertex(size, 0, size, 0, 1, 4, int(blockType), 7),
-				PackVertex(size, size, size, 1, 1, 4, int(blockType), 7),
-				PackVertex(0, size, size, 1, 0, 4, int(blockType), 7),
+				PackVertex(0, 0, size, 0, 0, 4, side, 7),
+				PackVertex(size, 0, size, 0, 1, 4, side, 7),
+				PackVertex(size, size, size, 1, 1, 4, side, 7),
+				PackVertex(0, size, size, 1, 0, 4, side, 7),
 			},
 		},
 		{ // -Z face (back)
 			vertices: [4]uint32{
-				PackVertex(0, size, 0, 0, 0, 5, int(blockType), 7),
-				PackVertex(size, size, 0, 0, 1, 5, int(blockType), 7),
-				PackVertex(size, 0, 0, 1, 1, 5, int(blockType), 7),
-				PackVertex(0, 0, 0, 1, 0, 5, int(blockType), 7),
+				PackVertex(0, size, 0, 0, 0, 5, side, 7),
+				PackVertex(size, size, 0, 0, 1, 5, side, 7),
+				PackVertex(size, 0, 0, 1, 1, 5, side, 7),
+				PackVertex(0, 0, 0, 1, 0, 5, side, 7),
 			},
 		},
 	}
@@ -0,0 +1,150 @@
+package voxel
+
+import (
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// detailVertex is a vertex unpacked from DetailVertices the way vert.glsl does
+type detailVertex struct {
+	pos         [3]float32 // In blocks, relative to the chunk
+	orientation int
+	texture     int
+}
+
+func unpackDetailVertices(words []uint32) []detailVertex {
+	var vertices []detailVertex
+	for i := 0; i+1 < len(words); i += 2 {
+		w0, w1 := words[i], words[i+1]
+		vertices = append(vertices, detailVertex{
+			pos: [3]float32{
+				float32(w0>>18&511) / ModelUnits,
+				float32(w0>>9&511) / ModelUnits,
+				float32(w0&511) / ModelUnits,
+			},
+			orientation: int(w1 >> 10 & 7),
+			texture:     int(w1 >> 13 & 255),
+		})
+	}
+	return vertices
+}
+
+// singleBlock returns a 3x3x3 array of air with state in the middle
+func singleBlock(state BlockState) [][][]BlockState {
+	voxels := ConvertStatesTo3DArray(make([]BlockState, 27), 3, 3, 3, false)
+	voxels[1][1][1] = state
+	return voxels
+}
+
+func TestMeshPartialShapesUseDetailVertices(t *testing.T) {
+	tests := []struct {
+		name  string
+		state BlockState
+		faces int
+		maxY  float32 // Highest vertex, relative to the block
+	}{
+		{"bottom slab", BlockState(OakSlab), 6, 0.5},
+		{"top slab", BlockState(OakSlab).With(PropertyHalf, 1), 6, 1},
+		{"stairs", BlockState(OakStairs), 12, 1},
+		{"fence post", BlockState(OakFence), 6, 1},
+		{"flower", BlockState(Flower), 4, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mesh := GreedyMeshStates(singleBlock(tt.state), mgl32.Vec3{})
+			if len(mesh.PackedVertices) != 0 {
+				t.Errorf("%d packed vertices, partial shapes belong in DetailVertices", len(mesh.PackedVertices))
+			}
+			vertices := unpackDetailVertices(mesh.DetailVertices)
+			if len(vertices) != tt.faces*4 {
+				t.Fatalf("%d detail vertices, want %d for %d faces", len(vertices), tt.faces*4, tt.faces)
+			}
+			if len(mesh.DetailVertices)%2 != 0 {
+				t.Fatalf("odd number of detail words %d", len(mesh.DetailVertices))
+			}
+
+			var maxY float32
+			for _, v := range vertices {
+				for axis, c := range v.pos {
+					if c < 1 || c > 2 {
+						t.Fatalf("vertex %v leaves the block on axis %d", v.pos, axis)
+					}
+				}
+				maxY = max(maxY, v.pos[1]-1)
+				if v.orientation > int(Down) {
+					t.Errorf("orientation %d out of range", v.orientation)
+				}
+				if v.texture == 0 {
+					t.Errorf("vertex %v has the air texture", v.pos)
+				}
+			}
+			if maxY != tt.maxY {
+				t.Errorf("top at %.2f, want %.2f", maxY, tt.maxY)
+			}
+		})
+	}
+}
+
+func TestMeshFullCubesUsePackedVertices(t *testing.T) {
+	mesh := GreedyMeshStates(singleBlock(BlockState(Stone)), mgl32.Vec3{})
+	if len(mesh.DetailVertices) != 0 {
+		t.Errorf("%d detail words for a full cube", len(mesh.DetailVertices))
+	}
+	if len(mesh.PackedVertices) != 6*4 {
+		t.Errorf("%d packed vertices, want %d for six faces", len(mesh.PackedVertices), 6*4)
+	}
+}
+
+func TestPackDetailVertex(t *testing.T) {
+	words := PackDetailVertex(256, 100, 3, 16, 0, int(Down), 200, 7)
+	got := unpackDetailVertices(words[:])[0]
+	if want := [3]float32{16, 100.0 / 16, 3.0 / 16}; got.pos != want {
+		t.Errorf("position %v, want %v", got.pos, want)
+	}
+	if got.orientation != int(Down) || got.texture != 200 {
+		t.Errorf("orientation %d, texture %d, want %d, 200", got.orientation, got.texture, Down)
+	}
+}
+
+func TestMeshOrientsFacesByState(t *testing.T) {
+	SetFaceTextures(map[BlockType]FaceTextures{
+		OakLog: {Top: 10, Side: 11, Bottom: 12},
+		Barrel: {Top: 20, Side: 21, Bottom: 22},
+	})
+	t.Cleanup(func() { SetFaceTextures(nil) })
+
+	tests := []struct {
+		name        string
+		state       BlockState
+		top, bottom Direction
+	}{
+		{"upright log", BlockState(OakLog), Up, Down},
+		{"log along X", BlockState(OakLog).With(PropertyAxis, int(AxisX)), East, West},
+		{"barrel facing east", BlockState(Barrel).With(PropertyFacing, int(East)), East, West},
+		{"barrel facing down", BlockState(Barrel).With(PropertyFacing, int(Down)), Down, Up},
+		{"barrel facing north", BlockState(Barrel), North, South},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			textures, _ := GetFaceTextures(tt.state.Type())
+			mesh := GreedyMeshStates(singleBlock(tt.state), mgl32.Vec3{})
+			if len(mesh.PackedVertices) != 6*4 {
+				t.Fatalf("%d packed vertices, want %d for six faces", len(mesh.PackedVertices), 6*4)
+			}
+			for _, v := range mesh.PackedVertices {
+				orientation := Direction(v >> 17 & 7)
+				want := textures.Side
+				switch orientation {
+				case tt.top:
+					want = textures.Top
+				case tt.bottom:
+					want = textures.Bottom
+				}
+				if texture := uint8(v >> 20 & 255); texture != want {
+					t.Errorf("face %d has texture %d, want %d", orientation, texture, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package voxel
+
+// ModelUnits is the number of model units along one edge of a block.
+// Model geometry is expressed on this grid so partial shapes stay integral.
+const ModelUnits = 16
+
+// BlockShape identifies the geometry used to render a block
+type BlockShape uint8
+
+const (
+	ShapeCube   BlockShape = iota // Full block, meshed greedily
+	ShapeSlab                     // Half-height block
+	ShapeStairs                   // Half slab with a quarter block step on top
+	ShapeFence                    // Center post with arms towards connectable neighbors
+	ShapeCross                    // Two diagonal quads, used for plants
+)
+
+// Box is an axis-aligned box inside a block, in model units (0-16).
+// Indices follow the mesher axes, the same order as GreedyMeshChunk's voxel array.
+type Box struct {
+	Min, Max [3]int
+}
+
+// fullBox covers the entire block
+var fullBox = Box{Max: [3]int{ModelUnits, ModelUnits, ModelUnits}}
+
+// BlockModel describes the geometry of a block type
+type BlockModel struct {
+	Shape  BlockShape
+	Facing Direction // Side of the tall step for stairs
+	Top    bool      // Whether slabs and stairs sit in the upper half of the block
+}
+
+// Block models for every non-cube block type
+var blockModels = map[BlockType]BlockModel{
+	OakSlab:          {Shape: ShapeSlab},
+	StoneSlab:        {Shape: ShapeSlab},
+	OakStairs:        {Shape: ShapeStairs, Facing: North},
+	StoneBrickStairs: {Shape: ShapeStairs, Facing: North},
+	OakFence:         {Shape: ShapeFence},
+	TallGrass:        {Shape: ShapeCross},
+	Flower:           {Shape: ShapeCross},
+}
+
+// GetBlockModel returns the model for a block type, a full cube if none is declared
+func GetBlockModel(blockType BlockType) BlockModel {
+	model, exists := blockModels[blockType]
+	if !exists {
+		return BlockModel{Shape: ShapeCube}
+	}
+	return model
+}
+
+// IsFullCube reports whether the block type fills its whole cell and can be meshed greedily
+func (b BlockType) IsFullCube() bool {
+	return b != Air && GetBlockModel(b).Shape == ShapeCube
+}
+
+// axisSign returns the mesher axis and sign a direction points along,
+// matching the orientations GreedyMeshChunk assigns to its faces
+func (d Direction) axisSign() (axis, sign int) {
+	switch d {
+	case East:
+		return 0, 1
+	case West:
+		return 0, -1
+	case Up:
+		return 1, 1
+	case Down:
+		return 1, -1
+	case South:
+		return 2, 1
+	default: // North
+		return 2, -1
+	}
+}
+
+// Boxes returns the static boxes making up the model.
+// Fences only return their post; arms depend on neighbors and are added while meshing.
+// Cross models have no boxes.
+func (m BlockModel) Boxes() []Box {
+	half := ModelUnits / 2
+
+	switch m.Shape {
+	case ShapeCube:
+		return []Box{fullBox}
+	case ShapeSlab:
+		return []Box{halfBox(m.Top)}
+	case ShapeStairs:
+		// Bottom (or top) half plus a quarter block on the facing side of the other half
+		step := halfBox(!m.Top)
+		axis, sign := m.Facing.axisSign()
+		if sign > 0 {
+			step.Min[axis] = half
+		} else {
+			step.Max[axis] = half
+		}
+		return []Box{halfBox(m.Top), step}
+	case ShapeFence:
+		return []Box{{Min: [3]int{6, 0, 6}, Max: [3]int{10, ModelUnits, 10}}}
+	default:
+		return nil
+	}
+}
+
+// halfBox returns the lower or upper half of a block
+func halfBox(top bool) Box {
+	box := fullBox
+	if top {
+		box.Min[1] = ModelUnits / 2
+	} else {
+		box.Max[1] = ModelUnits / 2
+	}
+	return box
+}
+
+// fenceArm returns the box connecting a fence post to the neighbor along axis/sign
+func fenceArm(axis, sign int) Box {
+	arm := Box{Min: [3]int{7, 6, 7}, Max: [3]int{9, 15, 9}}
+	if sign > 0 {
+		arm.Min[axis] = 9
+		arm.Max[axis] = ModelUnits
+	} else {
+		arm.Min[axis] = 0
+		arm.Max[axis] = 7
+	}
+	return arm
+}
+
+// connectsToFence reports whether a fence reaches out towards the given neighbor
+func connectsToFence(neighbor BlockState) bool {
+	return neighbor.IsFullCube() || GetBlockModel(neighbor.Type()).Shape == ShapeFence
+}
+
+// coversFace reports whether the block fully covers the face of its cell on the given side,
+// hiding whatever face touches it from the neighboring cell
+func coversFace(state BlockState, axis, sign int) bool {
+	if state.Type() == Air {
+		return false
+	}
+	return faceCovered(state.Model().Boxes(), axis, sign, fullFaceRect)
+}
+
+// faceRect is a rectangle on a block face, in model units along the two face axes
+type faceRect struct {
+	uMin, uMax, vMin, vMax int
+}
+
+// fullFaceRect spans a whole block face
+var fullFaceRect = faceRect{uMax: ModelUnits, vMax: ModelUnits}
+
+// faceAxes returns the two axes spanning faces perpendicular to axis, in the mesher's order
+func faceAxes(axis int) (uAxis, vAxis int) {
+	switch axis {
+	case 0:
+		return 1, 2
+	case 1:
+		return 0, 2
+	default:
+		return 0, 1
+	}
+}
+
+// faceCovered reports whether one of the boxes touches the cell face on the given side
+// and contains the rectangle within that face
+func faceCovered(boxes []Box, axis, sign int, rect faceRect) bool {
+	uAxis, vAxis := faceAxes(axis)
+	for _, box := range boxes {
+		if sign > 0 && box.Max[axis] != ModelUnits {
+			continue
+		}
+		if sign < 0 && box.Min[axis] != 0 {
+			continue
+		}
+		if box.Min[uAxis] <= rect.uMin && box.Max[uAxis] >= rect.uMax &&
+			box.Min[vAxis] <= rect.vMin && box.Max[vAxis] >= rect.vMax {
+			return true
+		}
+	}
+	return false
+}
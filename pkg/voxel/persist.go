@@ -0,0 +1,198 @@
+package voxel
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// Chunk serialization format, all values big endian:
+//
+//	magic(U8[4]) "VXCK" + version(U8) + flags(U8) + size(U8) + x(I32) + y(I32) + z(I32)
+//	+ blocks(U8[size^3]) + props(U8[size^3], only if flagHasProps)
+//
+// Mono chunks store a single block type instead of the block array.
+const (
+	chunkFormatVersion = 1
+
+	flagHasProps uint8 = 1 << 0
+	flagMono     uint8 = 1 << 1
+)
+
+var chunkMagic = [4]byte{'V', 'X', 'C', 'K'}
+
+// ErrInvalidChunkData is returned when serialized chunk data can't be decoded
+var ErrInvalidChunkData = errors.New("invalid chunk data")
+
+// chunkHeader is the fixed-size prefix of a serialized chunk
+type chunkHeader struct {
+	Magic   [4]byte
+	Version uint8
+	Flags   uint8
+	Size    uint8
+	X, Y, Z int32
+}
+
+// WriteTo writes the chunk's blocks and block states to w.
+// It implements io.WriterTo.
+func (c *Chunk) WriteTo(w io.Writer) (int64, error) {
+	if c.Size <= 0 || c.Size > 255 {
+		return 0, fmt.Errorf("chunk size %d can't be serialized", c.Size)
+	}
+
+	header := chunkHeader{
+		Magic:   chunkMagic,
+		Version: chunkFormatVersion,
+		Size:    uint8(c.Size),
+		X:       c.X,
+		Y:       c.Y,
+		Z:       c.Z,
+	}
+
+	hasProps := c.HasProps()
+	mono, monoType := c.IsMono()
+	switch {
+	case hasProps:
+		header.Flags |= flagHasProps
+	case mono:
+		header.Flags |= flagMono
+	}
+
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, binary.BigEndian, header); err != nil {
+		return 0, fmt.Errorf("failed to write chunk header: %w", err)
+	}
+
+	if header.Flags&flagMono != 0 {
+		buf.WriteByte(uint8(monoType))
+	} else {
+		for _, blockType := range c.Blocks {
+			buf.WriteByte(uint8(blockType))
+		}
+		if hasProps {
+			buf.Write(c.Props)
+		}
+	}
+
+	return buf.WriteTo(w)
+}
+
+// ReadChunk reads a chunk written by Chunk.WriteTo
+func ReadChunk(r io.Reader) (*Chunk, error) {
+	var header chunkHeader
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return nil, fmt.Errorf("failed to read chunk header: %w", err)
+	}
+	if header.Magic != chunkMagic {
+		return nil, fmt.Errorf("%w: bad magic %q", ErrInvalidChunkData, header.Magic[:])
+	}
+	if header.Version != chunkFormatVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidChunkData, header.Version)
+	}
+	if header.Size == 0 {
+		return nil, fmt.Errorf("%w: zero chunk size", ErrInvalidChunkData)
+	}
+
+	size := int(header.Size)
+	chunk := NewChunk(header.X, header.Y, header.Z, size)
+
+	if header.Flags&flagMono != 0 {
+		var blockType uint8
+		if err := binary.Read(r, binary.BigEndian, &blockType); err != nil {
+			return nil, fmt.Errorf("failed to read mono block type: %w", err)
+		}
+		chunk.FillWithBlockType(BlockType(blockType))
+		return chunk, nil
+	}
+
+	raw := make([]byte, len(chunk.Blocks))
+	if _, err := io.ReadFull(r, raw); err != nil {
+		return nil, fmt.Errorf("failed to read blocks: %w", err)
+	}
+	for i, b := range raw {
+		chunk.Blocks[i] = BlockType(b)
+	}
+
+	if header.Flags&flagHasProps != 0 {
+		chunk.Props = make([]uint8, len(chunk.Blocks))
+		if _, err := io.ReadFull(r, chunk.Props); err != nil {
+			return nil, fmt.Errorf("failed to read block properties: %w", err)
+		}
+	}
+
+	return chunk, nil
+}
+
+// MarshalBinary encodes the chunk in the format written by WriteTo
+func (c *Chunk) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := c.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary decodes a chunk encoded by MarshalBinary, replacing the receiver's contents
+func (c *Chunk) UnmarshalBinary(data []byte) error {
+	chunk, err := ReadChunk(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	*c = *chunk
+	return nil
+}
+
+// WriteTo writes every loaded chunk of the world to w, prefixed by the chunk count.
+// Chunks are ordered by coordinates, so the same world always gives the same bytes.
+// It implements io.WriterTo.
+func (w *World) WriteTo(out io.Writer) (int64, error) {
+	w.mu.RLock()
+	chunks := make([]*Chunk, 0, len(w.chunks))
+	for _, chunk := range w.chunks {
+		chunks = append(chunks, chunk)
+	}
+	w.mu.RUnlock()
+	slices.SortFunc(chunks, func(a, b *Chunk) int {
+		return cmp.Or(cmp.Compare(a.X, b.X), cmp.Compare(a.Y, b.Y), cmp.Compare(a.Z, b.Z))
+	})
+
+	var written int64
+	if err := binary.Write(out, binary.BigEndian, uint32(len(chunks))); err != nil {
+		return written, fmt.Errorf("failed to write chunk count: %w", err)
+	}
+	written += 4
+
+	for _, chunk := range chunks {
+		n, err := chunk.WriteTo(out)
+		written += n
+		if err != nil {
+			return written, fmt.Errorf("failed to write chunk (%d, %d, %d): %w", chunk.X, chunk.Y, chunk.Z, err)
+		}
+	}
+	return written, nil
+}
+
+// ReadWorld reads a world written by World.WriteTo
+func ReadWorld(r io.Reader, chunkSize int) (*World, error) {
+	var count uint32
+	if err := binary.Read(r, binary.BigEndian, &count); err != nil {
+		return nil, fmt.Errorf("failed to read chunk count: %w", err)
+	}
+
+	world := NewWorld(chunkSize)
+	for i := range count {
+		chunk, err := ReadChunk(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read chunk %d: %w", i, err)
+		}
+		if chunk.Size != chunkSize {
+			return nil, fmt.Errorf("%w: chunk size %d, world uses %d", ErrInvalidChunkData, chunk.Size, chunkSize)
+		}
+		world.SetChunk(chunk)
+	}
+	return world, nil
+}
@@ -0,0 +1,151 @@
+package voxel
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+// chunkHeaderSize is the encoded size of chunkHeader
+const chunkHeaderSize = 4 + 3 + 3*4
+
+// testStatesChunk returns a chunk of mixed blocks, some with properties
+func testStatesChunk(x, y, z int32) *Chunk {
+	chunk := NewChunk(x, y, z, 16)
+	for i := range chunk.Blocks {
+		chunk.Blocks[i] = BlockType(i % 4)
+	}
+	chunk.SetState(1, 2, 3, BlockState(OakLog).With(PropertyAxis, int(AxisZ)))
+	chunk.SetState(4, 5, 6, BlockState(Barrel).With(PropertyFacing, int(Up)))
+	return chunk
+}
+
+func TestChunkRoundTrip(t *testing.T) {
+	mono := NewChunk(1, -2, 3, 16)
+	mono.FillWithBlockType(Stone)
+	mixed := NewChunk(-1, 0, 7, 16)
+	for i := range mixed.Blocks {
+		mixed.Blocks[i] = BlockType(i % 4)
+	}
+
+	tests := []struct {
+		name  string
+		chunk *Chunk
+		size  int
+	}{
+		{"mono", mono, chunkHeaderSize + 1},
+		{"empty", NewChunk(0, 0, 0, 16), chunkHeaderSize + 1},
+		{"mixed", mixed, chunkHeaderSize + 16*16*16},
+		{"states", testStatesChunk(5, 6, 7), chunkHeaderSize + 2*16*16*16},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := tt.chunk.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(data) != tt.size {
+				t.Errorf("encoded in %d bytes, want %d", len(data), tt.size)
+			}
+
+			var got Chunk
+			if err := got.UnmarshalBinary(data); err != nil {
+				t.Fatal(err)
+			}
+			if got.X != tt.chunk.X || got.Y != tt.chunk.Y || got.Z != tt.chunk.Z || got.Size != tt.chunk.Size {
+				t.Errorf("chunk (%d, %d, %d) of size %d, want (%d, %d, %d) of size %d",
+					got.X, got.Y, got.Z, got.Size, tt.chunk.X, tt.chunk.Y, tt.chunk.Z, tt.chunk.Size)
+			}
+			if !reflect.DeepEqual(got.States(), tt.chunk.States()) {
+				t.Error("block states changed in the round trip")
+			}
+		})
+	}
+}
+
+func TestReadChunkRejectsBadData(t *testing.T) {
+	valid, err := testStatesChunk(0, 0, 0).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupt := func(offset int, value byte) []byte {
+		data := bytes.Clone(valid)
+		data[offset] = value
+		return data
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"bad magic", corrupt(0, 'X'), ErrInvalidChunkData},
+		{"bad version", corrupt(4, chunkFormatVersion+1), ErrInvalidChunkData},
+		{"zero size", corrupt(6, 0), ErrInvalidChunkData},
+		{"truncated header", valid[:chunkHeaderSize-1], io.ErrUnexpectedEOF},
+		{"truncated blocks", valid[:chunkHeaderSize+100], io.ErrUnexpectedEOF},
+		{"truncated properties", valid[:len(valid)-1], io.ErrUnexpectedEOF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ReadChunk(bytes.NewReader(tt.data)); !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestWorldRoundTrip(t *testing.T) {
+	chunks := []*Chunk{testStatesChunk(0, 0, 0), testStatesChunk(-1, 2, 0), testStatesChunk(0, 0, -3)}
+	mono := NewChunk(3, 3, 3, 16)
+	mono.FillWithBlockType(Water)
+	chunks = append(chunks, mono)
+
+	world := NewWorld(16)
+	for _, chunk := range chunks {
+		world.SetChunk(chunk)
+	}
+	var first bytes.Buffer
+	n, err := world.WriteTo(&first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(first.Len()) {
+		t.Errorf("WriteTo returned %d, wrote %d bytes", n, first.Len())
+	}
+
+	// The same chunks give the same bytes, whatever the order they were stored in
+	reversed := NewWorld(16)
+	for i := len(chunks) - 1; i >= 0; i-- {
+		reversed.SetChunk(chunks[i])
+	}
+	for range 5 {
+		var again bytes.Buffer
+		if _, err := reversed.WriteTo(&again); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(again.Bytes(), first.Bytes()) {
+			t.Fatal("saving the same world twice gave different bytes")
+		}
+	}
+
+	got, err := ReadWorld(bytes.NewReader(first.Bytes()), 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.ChunkCoords()) != len(chunks) {
+		t.Fatalf("read %d chunks, want %d", len(got.ChunkCoords()), len(chunks))
+	}
+	for _, chunk := range chunks {
+		read := got.Chunk(ChunkCoord{X: chunk.X, Y: chunk.Y, Z: chunk.Z})
+		if read == nil || !reflect.DeepEqual(read.States(), chunk.States()) {
+			t.Errorf("chunk (%d, %d, %d) changed in the round trip", chunk.X, chunk.Y, chunk.Z)
+		}
+	}
+
+	if _, err := ReadWorld(bytes.NewReader(first.Bytes()), 32); !errors.Is(err, ErrInvalidChunkData) {
+		t.Errorf("reading with another chunk size: got %v, want ErrInvalidChunkData", err)
+	}
+}
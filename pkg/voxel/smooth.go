@@ -0,0 +1,220 @@
+package voxel
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// SurfaceNetsMesh builds a smooth, indexed triangle mesh for a chunk using naive surface nets.
+//
+// Density is 1 inside solid blocks and 0 elsewhere, sampled at block centers.
+// One vertex is placed per grid cell crossing the surface, at the average of its edge
+// crossings, and one quad is emitted per crossing edge. A chunk owns the edges starting
+// inside it and samples one block beyond its borders through neighbors, so adjacent
+// chunks produce identical border vertices and join without seams.
+//
+// neighbors provides blocks outside the chunk in world coordinates; nil treats them as Air.
+// Positions are in world coordinates (no X/Z swap), normals come from the density
+// gradient, and each vertex carries the material of its topmost solid sample.
+func SurfaceNetsMesh(chunk *Chunk, neighbors BlockGetter) *Mesh {
+	mesh := NewMesh()
+	mesh.GenerateIndices = true
+
+	size := chunk.Size
+	origin := [3]int32{chunk.X * int32(size), chunk.Y * int32(size), chunk.Z * int32(size)}
+
+	// Sample the chunk plus a one block margin on every side
+	samples := size + 2
+	blocks := make([]BlockType, samples*samples*samples)
+	sampleIndex := func(x, y, z int) int {
+		return ((x+1)*samples+(y+1))*samples + (z + 1)
+	}
+	for x := -1; x <= size; x++ {
+		for y := -1; y <= size; y++ {
+			for z := -1; z <= size; z++ {
+				var blockType BlockType
+				if x >= 0 && y >= 0 && z >= 0 && x < size && y < size && z < size {
+					blockType = chunk.GetBlock(x, y, z)
+				} else if neighbors != nil {
+					blockType = neighbors.GetBlock(origin[0]+int32(x), origin[1]+int32(y), origin[2]+int32(z))
+				}
+				blocks[sampleIndex(x, y, z)] = blockType
+			}
+		}
+	}
+	density := func(x, y, z int) float32 {
+		if blocks[sampleIndex(x, y, z)].IsSolid() {
+			return 1
+		}
+		return 0
+	}
+
+	// Place one vertex per surface cell; cells span samples [i, i+1] for i in [-1, size-1]
+	cells := size + 1
+	cellVertex := make([]int32, cells*cells*cells)
+	cellIndex := func(x, y, z int) int {
+		return ((x+1)*cells+(y+1))*cells + (z + 1)
+	}
+	for x := -1; x < size; x++ {
+		for y := -1; y < size; y++ {
+			for z := -1; z < size; z++ {
+				cellVertex[cellIndex(x, y, z)] = -1
+				if vertex, ok := surfaceNetsVertex(x, y, z, density, blocks, sampleIndex); ok {
+					vertex.Position = vertex.Position.Add(mgl32.Vec3{
+						float32(origin[0]), float32(origin[1]), float32(origin[2]),
+					})
+					cellVertex[cellIndex(x, y, z)] = int32(len(mesh.Vertices))
+					mesh.Vertices = append(mesh.Vertices, vertex)
+				}
+			}
+		}
+	}
+
+	// Emit a quad for every owned edge whose endpoints straddle the surface
+	for x := range size {
+		for y := range size {
+			for z := range size {
+				p := [3]int{x, y, z}
+				inside := density(x, y, z) > 0.5
+
+				for axis := range 3 {
+					next := p
+					next[axis]++
+					if inside == (density(next[0], next[1], next[2]) > 0.5) {
+						continue
+					}
+
+					// The four cells around the edge, wound so the normal points along +axis
+					b, c := (axis+1)%3, (axis+2)%3
+					corners := [4][3]int{p, p, p, p}
+					corners[1][b]--
+					corners[2][b]--
+					corners[2][c]--
+					corners[3][c]--
+
+					var quad [4]uint32
+					valid := true
+					for i, cell := range corners {
+						index := cellVertex[cellIndex(cell[0], cell[1], cell[2])]
+						if index < 0 {
+							valid = false
+							break
+						}
+						quad[i] = uint32(index)
+					}
+					if !valid {
+						continue
+					}
+
+					// Flip the winding when the solid side is on +axis
+					if !inside {
+						quad[1], quad[3] = quad[3], quad[1]
+					}
+					mesh.Indices = append(mesh.Indices, quad[0], quad[1], quad[2], quad[0], quad[2], quad[3])
+				}
+			}
+		}
+	}
+
+	return mesh
+}
+
+// GenerateSmoothMesh creates a smooth mesh for this chunk using surface nets
+// neighbors provides the blocks around the chunk, see SurfaceNetsMesh
+func (c *Chunk) GenerateSmoothMesh(neighbors BlockGetter) *Mesh {
+	c.Mesh = SurfaceNetsMesh(c, neighbors)
+	return c.Mesh
+}
+
+// cubeCorners lists the corner offsets of a cell, topmost corners first
+var cubeCorners = [8][3]int{
+	{0, 1, 0}, {1, 1, 0}, {0, 1, 1}, {1, 1, 1},
+	{0, 0, 0}, {1, 0, 0}, {0, 0, 1}, {1, 0, 1},
+}
+
+// surfaceNetsVertex computes the vertex of the cell whose minimum sample is (x, y, z),
+// relative to the chunk origin. It reports false if the surface doesn't cross the cell.
+func surfaceNetsVertex(x, y, z int, density func(x, y, z int) float32, blocks []BlockType, sampleIndex func(x, y, z int) int) (Vertex, bool) {
+	var values [8]float32
+	solidCount := 0
+	for i, corner := range cubeCorners {
+		values[i] = density(x+corner[0], y+corner[1], z+corner[2])
+		if values[i] > 0.5 {
+			solidCount++
+		}
+	}
+	if solidCount == 0 || solidCount == 8 {
+		return Vertex{}, false
+	}
+
+	// Average the crossing points of the twelve cell edges
+	var sum mgl32.Vec3
+	crossings := 0
+	for i := range cubeCorners {
+		for j := i + 1; j < len(cubeCorners); j++ {
+			a, b := cubeCorners[i], cubeCorners[j]
+			if manhattan(a, b) != 1 || (values[i] > 0.5) == (values[j] > 0.5) {
+				continue
+			}
+			t := (0.5 - values[i]) / (values[j] - values[i])
+			sum = sum.Add(mgl32.Vec3{
+				float32(a[0]) + t*float32(b[0]-a[0]),
+				float32(a[1]) + t*float32(b[1]-a[1]),
+				float32(a[2]) + t*float32(b[2]-a[2]),
+			})
+			crossings++
+		}
+	}
+	offset := sum.Mul(1 / float32(crossings))
+
+	// The gradient points into the solid, so the surface normal is its negation
+	var gradient mgl32.Vec3
+	for i, corner := range cubeCorners {
+		for axis := range 3 {
+			if corner[axis] == 1 {
+				gradient[axis] += values[i]
+			} else {
+				gradient[axis] -= values[i]
+			}
+		}
+	}
+	normal := mgl32.Vec3{0, 1, 0}
+	if gradient.Len() > 0 {
+		normal = gradient.Mul(-1).Normalize()
+	}
+
+	// Samples sit at block centers, half a block from the block corner
+	position := mgl32.Vec3{
+		float32(x) + 0.5 + offset[0],
+		float32(y) + 0.5 + offset[1],
+		float32(z) + 0.5 + offset[2],
+	}
+
+	// Use the topmost solid sample as the material so surfaces show their top blocks
+	var material BlockType
+	for i, corner := range cubeCorners {
+		if values[i] > 0.5 {
+			material = blocks[sampleIndex(x+corner[0], y+corner[1], z+corner[2])]
+			break
+		}
+	}
+
+	return Vertex{
+		Position:  position,
+		Normal:    normal,
+		TexCoords: mgl32.Vec2{offset[0] + offset[2], offset[1]},
+		Material:  material,
+	}, true
+}
+
+// manhattan returns the Manhattan distance between two cell corners
+func manhattan(a, b [3]int) int {
+	d := 0
+	for i := range 3 {
+		if a[i] > b[i] {
+			d += a[i] - b[i]
+		} else {
+			d += b[i] - a[i]
+		}
+	}
+	return d
+}
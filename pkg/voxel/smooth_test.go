@@ -0,0 +1,100 @@
+package voxel
+
+import (
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// terrainWorld fills the columns from (-16, -16) to (47, 31) with stone from y = -16 up to
+// the height of each column
+func terrainWorld(height func(x, z int32) int32) *World {
+	world := NewWorld(16)
+	for x := int32(-16); x < 48; x++ {
+		for z := int32(-16); z < 32; z++ {
+			for y := int32(-16); y < height(x, z); y++ {
+				world.SetBlock(x, y, z, Stone)
+			}
+		}
+	}
+	return world
+}
+
+// checkWinding fails unless every triangle faces the side its vertex normals point to
+func checkWinding(t *testing.T, mesh *Mesh) {
+	t.Helper()
+	if len(mesh.Indices) == 0 || len(mesh.Indices)%3 != 0 {
+		t.Fatalf("%d indices, want whole triangles", len(mesh.Indices))
+	}
+	for i := 0; i < len(mesh.Indices); i += 3 {
+		a, b, c := mesh.Vertices[mesh.Indices[i]], mesh.Vertices[mesh.Indices[i+1]], mesh.Vertices[mesh.Indices[i+2]]
+		face := b.Position.Sub(a.Position).Cross(c.Position.Sub(a.Position))
+		normal := a.Normal.Add(b.Normal).Add(c.Normal)
+		if face.Dot(normal) <= 0 {
+			t.Fatalf("triangle %v %v %v wound against its normals %v", a.Position, b.Position, c.Position, normal)
+		}
+	}
+}
+
+func TestSurfaceNetsFlatGround(t *testing.T) {
+	world := terrainWorld(func(x, z int32) int32 { return 8 })
+	mesh := SurfaceNetsMesh(world.Chunk(ChunkCoord{}), world)
+
+	// One quad per column, on the boundary between the stone and the air above it
+	if len(mesh.Indices) != 16*16*6 {
+		t.Errorf("%d indices, want a quad for each of the 256 columns", len(mesh.Indices))
+	}
+	up := mgl32.Vec3{0, 1, 0}
+	for _, v := range mesh.Vertices {
+		if v.Position.Y() != 8 {
+			t.Fatalf("vertex at %v, want it on the ground at height 8", v.Position)
+		}
+		if !v.Normal.ApproxEqual(up) {
+			t.Fatalf("vertex normal %v, want %v", v.Normal, up)
+		}
+		if v.Material != Stone {
+			t.Fatalf("vertex material %v, want stone", v.Material)
+		}
+	}
+	checkWinding(t, mesh)
+
+	// Without neighbors the chunk is surrounded by air and closed by walls
+	closed := SurfaceNetsMesh(world.Chunk(ChunkCoord{}), nil)
+	if len(closed.Indices) <= len(mesh.Indices) {
+		t.Errorf("%d indices without neighbors, want walls besides the ground", len(closed.Indices))
+	}
+	checkWinding(t, closed)
+}
+
+func TestSurfaceNetsChunksJoin(t *testing.T) {
+	// Ridges and slopes crossing the border between chunks (0, 0, 0) and (1, 0, 0) at x = 16
+	world := terrainWorld(func(x, z int32) int32 { return 6 + (x+16)%3 + (z+16)/3 })
+	left := SurfaceNetsMesh(world.Chunk(ChunkCoord{X: 0}), world)
+	right := SurfaceNetsMesh(world.Chunk(ChunkCoord{X: 1}), world)
+	checkWinding(t, left)
+	checkWinding(t, right)
+
+	// Both chunks place a vertex in the cells between block centers 15.5 and 16.5
+	border := func(mesh *Mesh) []Vertex {
+		var vertices []Vertex
+		for _, v := range mesh.Vertices {
+			if v.Position.X() >= 15.5 && v.Position.X() <= 16.5 {
+				vertices = append(vertices, v)
+			}
+		}
+		return vertices
+	}
+	fromLeft, fromRight := border(left), border(right)
+	if len(fromLeft) == 0 {
+		t.Fatal("no vertices on the border")
+	}
+	if len(fromLeft) != len(fromRight) {
+		t.Fatalf("%d border vertices on the left, %d on the right", len(fromLeft), len(fromRight))
+	}
+	for i, l := range fromLeft {
+		r := fromRight[i]
+		if !l.Position.ApproxEqualThreshold(r.Position, 1e-5) || !l.Normal.ApproxEqualThreshold(r.Normal, 1e-5) || l.Material != r.Material {
+			t.Errorf("border vertex %d is %+v on the left and %+v on the right", i, l, r)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package voxel
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// BlockState pairs a block type with its property values.
+// The low byte holds the BlockType and the high byte the packed properties,
+// laid out in the order declared for that block type.
+// A BlockType converts directly to its default state with BlockState(blockType).
+type BlockState uint16
+
+// Property identifies a block state property
+type Property uint8
+
+const (
+	PropertyAxis   Property = iota // Axis the block is aligned to (Axis)
+	PropertyFacing                 // Direction the block faces (Direction)
+	PropertyLevel                  // Fluid level, 0 for a source block (0-15)
+	PropertyHalf                   // Whether the block occupies the top half (0 or 1)
+)
+
+// Axis is the value of PropertyAxis
+type Axis uint8
+
+const (
+	AxisY Axis = iota // Upright, the default
+	AxisX
+	AxisZ
+)
+
+// bits returns the number of bits a property occupies in the packed state
+func (p Property) bits() uint {
+	switch p {
+	case PropertyAxis:
+		return 2
+	case PropertyFacing:
+		return 3
+	case PropertyLevel:
+		return 4
+	default:
+		return 1
+	}
+}
+
+// maxValue returns the largest valid value of a property
+func (p Property) maxValue() int {
+	switch p {
+	case PropertyAxis:
+		return int(AxisZ)
+	case PropertyFacing:
+		return int(Down)
+	case PropertyLevel:
+		return 15
+	default:
+		return 1
+	}
+}
+
+// Properties declared per block type, packed in this order.
+// The bits of each list must not exceed 8.
+var blockStateProperties = map[BlockType][]Property{
+	OakLog:           {PropertyAxis},
+	Barrel:           {PropertyFacing},
+	Water:            {PropertyLevel},
+	Lava:             {PropertyLevel},
+	OakSlab:          {PropertyHalf},
+	StoneSlab:        {PropertyHalf},
+	OakStairs:        {PropertyFacing, PropertyHalf},
+	StoneBrickStairs: {PropertyFacing, PropertyHalf},
+}
+
+// GetStateProperties returns the properties a block type carries in its state
+func GetStateProperties(blockType BlockType) []Property {
+	return blockStateProperties[blockType]
+}
+
+// NewBlockState creates a state from a block type and its packed property byte
+func NewBlockState(blockType BlockType, props uint8) BlockState {
+	return BlockState(blockType) | BlockState(props)<<8
+}
+
+// ParseBlockState parses a block name or ID, optionally followed by :props with the packed property byte
+func ParseBlockState(text string) (BlockState, error) {
+	name, propsText, hasProps := strings.Cut(text, ":")
+	blockType, ok := ParseBlockType(name)
+	if !ok {
+		return 0, fmt.Errorf("unknown block %q", name)
+	}
+	var props uint64
+	if hasProps {
+		var err error
+		if props, err = strconv.ParseUint(propsText, 10, 8); err != nil {
+			return 0, fmt.Errorf("invalid block properties %q", propsText)
+		}
+	}
+	state := NewBlockState(blockType, uint8(props))
+	if !state.Valid() {
+		return 0, fmt.Errorf("invalid properties %d for %s", props, blockType)
+	}
+	return state, nil
+}
+
+// Type returns the base block type of the state
+func (s BlockState) Type() BlockType {
+	return BlockType(s & 0xFF)
+}
+
+// Props returns the packed property byte of the state
+func (s BlockState) Props() uint8 {
+	return uint8(s >> 8)
+}
+
+// propertyShift returns the bit offset of a property within the property byte,
+// and false if the block type does not carry the property
+func (s BlockState) propertyShift(p Property) (uint, bool) {
+	var shift uint
+	for _, declared := range blockStateProperties[s.Type()] {
+		if declared == p {
+			return shift, true
+		}
+		shift += declared.bits()
+	}
+	return 0, false
+}
+
+// Valid reports whether the state's block type is valid, and its property byte only sets
+// the properties declared for that type, each within its range
+func (s BlockState) Valid() bool {
+	if !s.Type().Valid() {
+		return false
+	}
+	var used uint
+	for _, p := range blockStateProperties[s.Type()] {
+		if s.Get(p) > p.maxValue() {
+			return false
+		}
+		used += p.bits()
+	}
+	return s.Props()>>used == 0
+}
+
+// Has reports whether the state's block type carries the property
+func (s BlockState) Has(p Property) bool {
+	_, ok := s.propertyShift(p)
+	return ok
+}
+
+// Get returns the value of a property, or 0 if the block type doesn't carry it
+func (s BlockState) Get(p Property) int {
+	shift, ok := s.propertyShift(p)
+	if !ok {
+		return 0
+	}
+	mask := uint8(1)<<p.bits() - 1
+	return int(s.Props() >> shift & mask)
+}
+
+// With returns a copy of the state with the property set to value.
+// Properties the block type doesn't carry are ignored and out-of-range values are truncated.
+func (s BlockState) With(p Property, value int) BlockState {
+	shift, ok := s.propertyShift(p)
+	if !ok {
+		return s
+	}
+	mask := uint8(1)<<p.bits() - 1
+	props := s.Props()&^(mask<<shift) | (uint8(value)&mask)<<shift
+	return NewBlockState(s.Type(), props)
+}
+
+// Axis returns the axis property of the state
+func (s BlockState) Axis() Axis {
+	return Axis(s.Get(PropertyAxis))
+}
+
+// Facing returns the facing property of the state
+func (s BlockState) Facing() Direction {
+	return Direction(s.Get(PropertyFacing))
+}
+
+// Level returns the fluid level property of the state
+func (s BlockState) Level() int {
+	return s.Get(PropertyLevel)
+}
+
+// Top reports whether the state's half property is the top half
+func (s BlockState) Top() bool {
+	return s.Get(PropertyHalf) == 1
+}
+
+// Model returns the block model for the state, with facing and half taken from its properties
+func (s BlockState) Model() BlockModel {
+	model := GetBlockModel(s.Type())
+	if s.Has(PropertyFacing) {
+		model.Facing = s.Facing()
+	}
+	if s.Has(PropertyHalf) {
+		model.Top = s.Top()
+	}
+	return model
+}
+
+// IsFullCube reports whether the state fills its whole cell and can be meshed greedily
+func (s BlockState) IsFullCube() bool {
+	return s.Type().IsFullCube()
+}
@@ -0,0 +1,69 @@
+package voxel
+
+import (
+	"sync/atomic"
+)
+
+// FaceTextures holds the texture IDs used for the faces of a block type
+type FaceTextures struct {
+	Top    uint8 // Face pointing along the block's axis (up for upright blocks)
+	Side   uint8 // Faces perpendicular to the block's axis
+	Bottom uint8 // Face pointing against the block's axis
+}
+
+// faceTextures is the active texture table; nil means texture IDs are block IDs
+var faceTextures atomic.Pointer[map[BlockType]FaceTextures]
+
+// SetFaceTextures replaces the texture table used by the meshers.
+// Block types missing from the table, or a nil table, fall back to using the block ID.
+// The table must not be modified after it is set.
+func SetFaceTextures(table map[BlockType]FaceTextures) {
+	if table == nil {
+		faceTextures.Store(nil)
+		return
+	}
+	faceTextures.Store(&table)
+}
+
+// GetFaceTextures returns the texture IDs registered for a block type
+func GetFaceTextures(blockType BlockType) (FaceTextures, bool) {
+	table := faceTextures.Load()
+	if table == nil {
+		return FaceTextures{}, false
+	}
+	textures, exists := (*table)[blockType]
+	return textures, exists
+}
+
+// faceTextureID returns the 8-bit texture ID for the face of a block on the given mesher axis.
+// Blocks with an axis property are rotated so their ends follow that axis, and full cubes
+// with a facing property so their top points that way.
+func faceTextureID(state BlockState, axis, normalSign int) int {
+	textures, exists := GetFaceTextures(state.Type())
+	if !exists {
+		return min(int(state.Type()), 255)
+	}
+
+	endAxis, endSign := 1, 1
+	switch {
+	case state.Has(PropertyAxis):
+		switch state.Axis() {
+		case AxisX:
+			endAxis = 0
+		case AxisZ:
+			endAxis = 2
+		}
+	case state.Has(PropertyFacing) && state.IsFullCube():
+		// Stairs keep their top up, facing only turns their step
+		endAxis, endSign = state.Facing().axisSign()
+	}
+
+	switch {
+	case axis != endAxis:
+		return int(textures.Side)
+	case normalSign == endSign:
+		return int(textures.Top)
+	default:
+		return int(textures.Bottom)
+	}
+}
@@ -0,0 +1,115 @@
+package voxel
+
+import (
+	"sync"
+)
+
+// BlockGetter provides read access to blocks by world coordinates
+type BlockGetter interface {
+	GetBlock(x, y, z int32) BlockType
+}
+
+// World is a sparse collection of chunks addressed by chunk coordinates.
+// The chunk map is safe for concurrent use; the chunks themselves are not.
+type World struct {
+	chunkSize int
+	mu        sync.RWMutex
+	chunks    map[ChunkCoord]*Chunk
+}
+
+// NewWorld creates an empty world made of cubic chunks of the given size
+func NewWorld(chunkSize int) *World {
+	return &World{
+		chunkSize: chunkSize,
+		chunks:    make(map[ChunkCoord]*Chunk),
+	}
+}
+
+// ChunkSize returns the edge length of the world's chunks
+func (w *World) ChunkSize() int {
+	return w.chunkSize
+}
+
+// Chunk returns the chunk at the given chunk coordinates, or nil if it is not loaded
+func (w *World) Chunk(coord ChunkCoord) *Chunk {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.chunks[coord]
+}
+
+// SetChunk stores a chunk, replacing any chunk already at its coordinates
+func (w *World) SetChunk(chunk *Chunk) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.chunks[ChunkCoord{X: chunk.X, Y: chunk.Y, Z: chunk.Z}] = chunk
+}
+
+// RemoveChunk drops the chunk at the given chunk coordinates
+func (w *World) RemoveChunk(coord ChunkCoord) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.chunks, coord)
+}
+
+// ChunkCoords returns the coordinates of every loaded chunk
+func (w *World) ChunkCoords() []ChunkCoord {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	coords := make([]ChunkCoord, 0, len(w.chunks))
+	for coord := range w.chunks {
+		coords = append(coords, coord)
+	}
+	return coords
+}
+
+// GetBlock returns the block at the given world coordinates.
+// Blocks in chunks that are not loaded are reported as Air.
+func (w *World) GetBlock(x, y, z int32) BlockType {
+	chunk := w.Chunk(WorldToChunkCoord(x, y, z, w.chunkSize))
+	if chunk == nil {
+		return Air
+	}
+	lx, ly, lz := WorldToLocalCoord(x, y, z, w.chunkSize)
+	return chunk.GetBlock(lx, ly, lz)
+}
+
+// SetBlock sets the block at the given world coordinates,
+// creating an empty chunk first if none is loaded there
+func (w *World) SetBlock(x, y, z int32, blockType BlockType) {
+	chunk := w.chunkOrCreate(WorldToChunkCoord(x, y, z, w.chunkSize))
+	lx, ly, lz := WorldToLocalCoord(x, y, z, w.chunkSize)
+	chunk.SetBlock(lx, ly, lz, blockType)
+}
+
+// GetState returns the block state at the given world coordinates.
+// Blocks in chunks that are not loaded are reported as Air.
+func (w *World) GetState(x, y, z int32) BlockState {
+	chunk := w.Chunk(WorldToChunkCoord(x, y, z, w.chunkSize))
+	if chunk == nil {
+		return BlockState(Air)
+	}
+	lx, ly, lz := WorldToLocalCoord(x, y, z, w.chunkSize)
+	return chunk.GetState(lx, ly, lz)
+}
+
+// SetState sets the block state at the given world coordinates,
+// creating an empty chunk first if none is loaded there
+func (w *World) SetState(x, y, z int32, state BlockState) {
+	chunk := w.chunkOrCreate(WorldToChunkCoord(x, y, z, w.chunkSize))
+	lx, ly, lz := WorldToLocalCoord(x, y, z, w.chunkSize)
+	chunk.SetState(lx, ly, lz, state)
+}
+
+// chunkOrCreate returns the chunk at the given chunk coordinates, creating an empty one if needed
+func (w *World) chunkOrCreate(coord ChunkCoord) *Chunk {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	chunk, exists := w.chunks[coord]
+	if !exists {
+		chunk = NewChunk(coord.X, coord.Y, coord.Z, w.chunkSize)
+		w.chunks[coord] = chunk
+	}
+	return chunk
+}